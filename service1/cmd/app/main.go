@@ -4,22 +4,46 @@ import (
 	"context"
 	"errors"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"service1/config"
 	"service1/internal/adapter/broker/kafkaa"
+	"service1/internal/adapter/storage/filelog"
+	"service1/internal/adapter/storage/idempotency"
 	"service1/internal/adapter/storage/inmemory"
+	"service1/internal/adapter/storage/traced"
 	"service1/internal/controller/httprouter"
+	"service1/internal/controller/kafkarouter"
+	"service1/internal/pkg/admission"
+	"service1/internal/pkg/auth"
+	"service1/internal/pkg/avro/avroevent"
+	"service1/internal/pkg/id/snowflake"
+	"service1/internal/pkg/id/ulidgen"
 	"service1/internal/pkg/id/uuidgen"
 	"service1/internal/pkg/json/standartjson"
+	"service1/internal/pkg/logger"
+	"service1/internal/pkg/metrics"
+	"service1/internal/pkg/protobuf/protoevent"
+	"service1/internal/pkg/ratelimit"
+	"service1/internal/pkg/registry/confluent"
+	"service1/internal/pkg/registry/fileregistry"
+	"service1/internal/pkg/registry/serde"
 	"service1/internal/pkg/server/httpserver"
 	"service1/internal/pkg/timestamp/standarttime"
+	"service1/internal/pkg/tracing"
 	"service1/internal/usecase/create"
+	"service1/internal/usecase/health"
 	"service1/internal/usecase/list"
 	"service1/internal/usecase/listid"
+	"service1/internal/usecase/patch"
+	"service1/internal/usecase/relay"
+	"service1/internal/usecase/remove"
+	"service1/internal/usecase/status"
 
 	"golang.org/x/sync/errgroup"
 )
@@ -27,6 +51,8 @@ import (
 // ENVIRONMENT VARIABLES:
 // - CONFIG_PATH - SPECIFIES CONFIG .YAML FILE TO USE : DEFAULTS TO "service1/config.yaml"
 // - KAFKA_ADDR - SPECIFIES KAFKA ADDRESS (EG. "0.0.0.0:9092") : DEFAULTS TO "[]string{"0.0.0.0:9092"}"
+// - AUTH_JWT_SECRET - HS256 SIGNING SECRET, AT LEAST 32 BYTES : REQUIRED WHEN AUTH USES HS256
+// - AUTH_API_KEYS - JSON ARRAY OF {"key", "subject", "roles"} : DEFAULTS TO NO API KEYS
 
 func main() {
 	if err := run(); err != nil {
@@ -42,41 +68,159 @@ func run() error {
 		return cnewErr
 	}
 	config.Kafka.Address = brokers
+	config.Consumer.Brokers = brokers
 
-	generator := uuidgen.New()
+	slogger, lnewErr := logger.New(config.Logger, os.Stdout)
+	if lnewErr != nil {
+		return lnewErr
+	}
+	slog.SetDefault(slogger)
+
+	tracer, tnewErr := tracing.New(context.Background(), config.Tracing)
+	if tnewErr != nil {
+		return tnewErr
+	}
+
+	generator, gnewErr := newGenerator(config.ID)
+	if gnewErr != nil {
+		return gnewErr
+	}
 	timer := standarttime.New()
 	json := standartjson.New()
+	proto := protoevent.New()
+	avro := avroevent.New()
 
-	storage := inmemory.New()
+	config.Storage.File.Encoder = json
+	config.Storage.File.Decoder = json
+	config.Storage.File.Logger = slogger
+	storage, snewErr := newStorage(config.Storage)
+	if snewErr != nil {
+		return snewErr
+	}
 
-	config.Kafka.Encoder = json
+	codecs := []serde.Codec{json, proto, avro}
+	config.Kafka.Encoder = newEncoder(config.Codec, json, proto, avro)
+	serdes, snewErr := newSerdes(config.Registry, config.Kafka.Topic+"-value", proto, avro)
+	if snewErr != nil {
+		return snewErr
+	}
+	for _, s := range serdes {
+		codecs = append(codecs, s)
+	}
+	if encoder, ok := serdes[config.Codec]; ok {
+		rregCtx, rregCancel := context.WithTimeout(context.Background(), config.Registry.Timeout)
+		defer rregCancel()
+		if rregErr := encoder.Register(rregCtx); rregErr != nil {
+			return rregErr
+		}
+		config.Kafka.Encoder = encoder
+	}
 	broker := kafkaa.New(config.Kafka)
+	controller := admission.New(config.Admission, broker, storage, json, slogger)
+
+	metric := metrics.New()
+
+	config.Consumer.Handler = kafkarouter.New(&kafkarouter.Config{
+		Status: &status.Usecase{
+			Config:  config.Events.Status,
+			Updater: storage,
+			Logger:  slogger,
+		},
+		Decoders: newDecoders(codecs),
+		Fallback: json,
+		Logger:   slogger,
+	})
+	config.Consumer.Logger = slogger
+	consumer, cnewErr := kafkaa.NewConsumer(config.Consumer)
+	if cnewErr != nil {
+		return cnewErr
+	}
+
+	healthUsecase := &health.Usecase{
+		Config: config.Health,
+		Checks: map[string]health.Pinger{
+			"storage":  storage,
+			"kafka":    broker,
+			"consumer": consumer,
+		},
+		Encoder: json,
+	}
+
+	authenticator, anewErr := auth.New(config.Auth, json, slogger)
+	if anewErr != nil {
+		return anewErr
+	}
+	limiter, rnewErr := ratelimit.New(config.Router.RateLimit, json, slogger)
+	if rnewErr != nil {
+		return rnewErr
+	}
 
 	router := httprouter.New(&httprouter.Config{
 		Create: &create.Usecase{
-			Config:    config.Router.Create,
-			Creator:   storage,
-			Publisher: broker,
-			Generator: generator,
-			Timer:     timer,
-			Encoder:   json,
-			Decoder:   json,
+			Config:      config.Router.Create,
+			Creator:     storage,
+			Idempotency: idempotency.New(timer),
+			Generator:   generator,
+			Timer:       timer,
+			Encoder:     json,
+			Decoder:     json,
+			Logger:      slogger,
 		},
 		List: &list.Usecase{
 			Config:  config.Router.List,
 			Getter:  storage,
 			Encoder: json,
+			Logger:  slogger,
 		},
 		ListID: &listid.Usecase{
 			Config:  config.Router.ListID,
 			Getter:  storage,
 			Encoder: json,
+			Logger:  slogger,
+		},
+		Patch: &patch.Usecase{
+			Config:  config.Router.Patch,
+			Editor:  storage,
+			Timer:   timer,
+			Encoder: json,
+			Decoder: json,
+			Logger:  slogger,
 		},
+		Remove: &remove.Usecase{
+			Config:  config.Router.Remove,
+			Remover: storage,
+			Timer:   timer,
+			Encoder: json,
+			Logger:  slogger,
+		},
+		Health:    healthUsecase,
+		Auth:      authenticator,
+		Limiter:   limiter,
+		Admission: controller,
+		Metrics:   metric,
+		Logger:    slogger,
 	})
 
 	config.Server.Handler = router
 	server := httpserver.New(config.Server)
 
+	if mregErr := metric.Register(
+		metrics.NewWriterCollector(config.Kafka.Topic, controller),
+		metrics.NewReaderCollector(config.Consumer.Topic, consumer),
+		metrics.NewTaskCollector(storage, config.Metrics.TasksTimeout, slogger),
+	); mregErr != nil {
+		return mregErr
+	}
+
+	outbox := &relay.Usecase{
+		Config:    config.Events.Relay,
+		Outbox:    storage,
+		Updater:   storage,
+		Publisher: broker,
+		Timer:     timer,
+		Logger:    slogger,
+	}
+
 	egCtx, egCancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	defer egCancel()
 	ewith, ewithCtx := errgroup.WithContext(egCtx)
@@ -86,17 +230,45 @@ func run() error {
 		}
 		return nil
 	})
+	ewith.Go(func() error {
+		if orunErr := outbox.Run(ewithCtx); orunErr != nil && !errors.Is(orunErr, relay.ErrOperationCanceled) {
+			return orunErr
+		}
+		return nil
+	})
+	ewith.Go(func() error {
+		if arunErr := controller.Run(ewithCtx); arunErr != nil && !errors.Is(arunErr, admission.ErrOperationCanceled) {
+			return arunErr
+		}
+		return nil
+	})
+	ewith.Go(func() error {
+		if crunErr := consumer.Run(ewithCtx); crunErr != nil && !errors.Is(crunErr, kafkaa.ErrOperationCanceled) {
+			return crunErr
+		}
+		return nil
+	})
 	ewith.Go(func() error {
 		<-ewithCtx.Done()
+		healthUsecase.Drain()
+		time.Sleep(config.Health.DrainDelay)
 		sshutCtx, sshutCancel := context.WithTimeout(context.Background(), config.Server.ShutdownTimeout)
 		defer sshutCancel()
 		if sshutErr := server.Shutdown(sshutCtx); sshutErr != nil {
-			log.Println(sshutErr)
+			slogger.Error("shutdown", slog.Any("error", sshutErr))
+		}
+		if cshutErr := consumer.Shutdown(); cshutErr != nil {
+			slogger.Error("shutdown", slog.Any("error", cshutErr))
 		}
 		if bcloseErr := broker.Close(); bcloseErr != nil {
-			log.Println(bcloseErr)
+			slogger.Error("shutdown", slog.Any("error", bcloseErr))
+		}
+		if scloseErr := storage.Close(); scloseErr != nil {
+			slogger.Error("shutdown", slog.Any("error", scloseErr))
+		}
+		if tshutErr := tracer.Shutdown(sshutCtx); tshutErr != nil {
+			slogger.Error("shutdown", slog.Any("error", tshutErr))
 		}
-		storage.Close()
 		return nil
 	})
 	if ewaitErr := ewith.Wait(); ewaitErr != nil && !errors.Is(ewaitErr, context.Canceled) {
@@ -106,6 +278,95 @@ func run() error {
 	return nil
 }
 
+type storage interface {
+	create.Creator
+	list.Getter
+	listid.Getter
+	status.Updater
+	patch.Editor
+	remove.Remover
+	relay.Outbox
+	metrics.TaskCounter
+	health.Pinger
+	Close() error
+}
+
+func newStorage(c config.Storage) (storage, error) {
+	switch c.Driver {
+	case config.StorageFile:
+		backend, err := filelog.New(c.File)
+		if err != nil {
+			return nil, err
+		}
+		return traced.New(backend, c.Driver), nil
+	default:
+		return traced.New(inmemory.New(), c.Driver), nil
+	}
+}
+
+func newGenerator(c config.ID) (create.Generator, error) {
+	switch c.Generator {
+	case config.IDULID:
+		return ulidgen.New(), nil
+	case config.IDUUID:
+		return uuidgen.New(), nil
+	default:
+		return snowflake.New(c.Snowflake)
+	}
+}
+
+func newEncoder(codec string, json *standartjson.JSON, proto *protoevent.Protobuf, avro *avroevent.Avro) kafkaa.Encoder {
+	switch codec {
+	case config.CodecProtobuf:
+		return proto
+	case config.CodecAvro:
+		return avro
+	default:
+		return json
+	}
+}
+
+func newDecoders(codecs []serde.Codec) map[string]kafkarouter.Decoder {
+	decoders := make(map[string]kafkarouter.Decoder, len(codecs))
+	for _, c := range codecs {
+		decoders[c.ContentType()] = c
+	}
+	return decoders
+}
+
+func newSerdes(c config.Registry, subject string, proto *protoevent.Protobuf, avro *avroevent.Avro) (map[string]*serde.Serde, error) {
+	var registry serde.Registry
+	switch c.Driver {
+	case config.RegistryNone:
+		return nil, nil
+	case config.RegistryConfluent:
+		registry = confluent.New(c.Confluent)
+	default:
+		file, err := fileregistry.New(c.File)
+		if err != nil {
+			return nil, err
+		}
+		registry = file
+	}
+	return map[string]*serde.Serde{
+		config.CodecProtobuf: serde.New(serde.Config{
+			Registry: registry,
+			Codec:    proto,
+			Subject:  subject,
+			Schema:   serde.Schema{Type: serde.TypeProtobuf, Schema: protoevent.Schema},
+			Indexes:  []int{protoevent.EnvelopeIndex},
+			Timeout:  c.Timeout,
+		}),
+		config.CodecAvro: serde.New(serde.Config{
+			Registry: registry,
+			Codec:    avro,
+			Subject:  subject,
+			Schema:   serde.Schema{Type: serde.TypeAvro, Schema: avroevent.Schema},
+			Timeout:  c.Timeout,
+		}),
+	}, nil
+}
+
 func loadEnvs() (string, []string) {
 	configPath := os.Getenv("CONFIG_PATH")
 	if configPath == "" {
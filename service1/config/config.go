@@ -3,30 +3,113 @@ package config
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"service1/internal/adapter/broker/kafkaa"
+	"service1/internal/adapter/storage/filelog"
+	"service1/internal/pkg/admission"
+	"service1/internal/pkg/auth"
+	"service1/internal/pkg/id/snowflake"
+	"service1/internal/pkg/logger"
+	"service1/internal/pkg/metrics"
+	"service1/internal/pkg/ratelimit"
+	"service1/internal/pkg/registry/confluent"
+	"service1/internal/pkg/registry/fileregistry"
 	"service1/internal/pkg/server/httpserver"
+	"service1/internal/pkg/tracing"
 	"service1/internal/usecase/create"
+	"service1/internal/usecase/health"
 	"service1/internal/usecase/list"
 	"service1/internal/usecase/listid"
+	"service1/internal/usecase/patch"
+	"service1/internal/usecase/relay"
+	"service1/internal/usecase/remove"
+	"service1/internal/usecase/status"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
 var (
-	ErrReadingConfig = errors.New("config: failed to load config")
+	ErrReadingConfig     = errors.New("config: failed to load config")
+	ErrUnknownStorage    = errors.New("config: unknown storage driver")
+	ErrUnknownGenerator  = errors.New("config: unknown id generator")
+	ErrUnknownCodec      = errors.New("config: unknown event codec")
+	ErrUnknownRegistry   = errors.New("config: unknown schema registry driver")
+	ErrUnknownMessageKey = errors.New("config: unknown kafka message key")
+	ErrRegistryCodec     = errors.New("config: schema registry requires protobuf or avro codec")
+)
+
+const (
+	StorageInMemory = "inmemory"
+	StorageFile     = "file"
+)
+
+const (
+	IDSnowflake = "snowflake"
+	IDULID      = "ulid"
+	IDUUID      = "uuid"
+)
+
+const (
+	CodecJSON     = "json"
+	CodecProtobuf = "protobuf"
+	CodecAvro     = "avro"
+)
+
+const (
+	RegistryNone      = "none"
+	RegistryFile      = "file"
+	RegistryConfluent = "confluent"
 )
 
 type Config struct {
-	Server httpserver.Config `yaml:"server"`
-	Router Router            `yaml:"router"`
-	Kafka  kafkaa.Config     `yaml:"kafka"`
+	Server    httpserver.Config     `yaml:"server"`
+	Storage   Storage               `yaml:"storage"`
+	ID        ID                    `yaml:"id"`
+	Router    Router                `yaml:"router"`
+	Auth      auth.Config           `yaml:"auth"`
+	Kafka     kafkaa.Config         `yaml:"kafka"`
+	Admission admission.Config      `yaml:"admission"`
+	Codec     string                `yaml:"codec"`
+	Registry  Registry              `yaml:"registry"`
+	Consumer  kafkaa.ConsumerConfig `yaml:"consumer"`
+	Events    Events                `yaml:"events"`
+	Metrics   metrics.Config        `yaml:"metrics"`
+	Health    health.Config         `yaml:"health"`
+	Logger    logger.Config         `yaml:"logger"`
+	Tracing   tracing.Config        `yaml:"tracing"`
+}
+
+type Storage struct {
+	Driver string         `yaml:"driver"`
+	File   filelog.Config `yaml:"file"`
+}
+
+type ID struct {
+	Generator string           `yaml:"generator"`
+	Snowflake snowflake.Config `yaml:"snowflake"`
+}
+
+type Registry struct {
+	Driver    string              `yaml:"driver"`
+	Timeout   time.Duration       `yaml:"timeout"`
+	File      fileregistry.Config `yaml:"file"`
+	Confluent confluent.Config    `yaml:"confluent"`
 }
 
 type Router struct {
 	Create create.Config `yaml:"create"`
 	List   list.Config   `yaml:"list"`
 	ListID listid.Config `yaml:"list_id"`
+	Patch  patch.Config  `yaml:"patch"`
+	Remove remove.Config `yaml:"remove"`
+
+	RateLimit ratelimit.Config `yaml:"rate_limit"`
+}
+
+type Events struct {
+	Status status.Config `yaml:"status"`
+	Relay  relay.Config  `yaml:"relay"`
 }
 
 func New(path string) (Config, error) {
@@ -35,5 +118,34 @@ func New(path string) (Config, error) {
 	if err != nil {
 		return Config{}, fmt.Errorf("%w: %v", ErrReadingConfig, err)
 	}
+	switch c.Storage.Driver {
+	case StorageInMemory, StorageFile:
+	default:
+		return Config{}, fmt.Errorf("%w: %q", ErrUnknownStorage, c.Storage.Driver)
+	}
+	switch c.ID.Generator {
+	case IDSnowflake, IDULID, IDUUID:
+	default:
+		return Config{}, fmt.Errorf("%w: %q", ErrUnknownGenerator, c.ID.Generator)
+	}
+	switch c.Codec {
+	case CodecJSON, CodecProtobuf, CodecAvro:
+	default:
+		return Config{}, fmt.Errorf("%w: %q", ErrUnknownCodec, c.Codec)
+	}
+	switch c.Registry.Driver {
+	case RegistryNone:
+	case RegistryFile, RegistryConfluent:
+		if c.Codec == CodecJSON {
+			return Config{}, fmt.Errorf("%w: %q", ErrRegistryCodec, c.Codec)
+		}
+	default:
+		return Config{}, fmt.Errorf("%w: %q", ErrUnknownRegistry, c.Registry.Driver)
+	}
+	switch c.Kafka.Key {
+	case "", kafkaa.KeyTaskID, kafkaa.KeyAction:
+	default:
+		return Config{}, fmt.Errorf("%w: %q", ErrUnknownMessageKey, c.Kafka.Key)
+	}
 	return c, nil
 }
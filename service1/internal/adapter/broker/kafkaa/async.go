@@ -0,0 +1,171 @@
+package kafkaa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"service1/internal/domain"
+	"service1/internal/pkg/tracing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type Async struct {
+	Enabled      bool          `yaml:"enabled"`
+	BatchSize    int           `yaml:"batch_size"`
+	Linger       time.Duration `yaml:"linger"`
+	QueueSize    int           `yaml:"queue_size"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+}
+
+type Future struct {
+	done      chan struct{}
+	err       error
+	callbacks []func(error)
+}
+
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+func (f *Future) Err() error {
+	<-f.done
+	return f.err
+}
+
+func (f *Future) Wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
+	case <-f.done:
+		return f.err
+	}
+}
+
+func (f *Future) resolve(err error) {
+	f.err = err
+	for _, callback := range f.callbacks {
+		callback(err)
+	}
+	close(f.done)
+}
+
+type queued struct {
+	message kafka.Message
+	future  *Future
+}
+
+type batcher struct {
+	config Async
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan queued
+	done   chan struct{}
+}
+
+func newBatcher(c Async) *batcher {
+	return &batcher{
+		config: c,
+		queue:  make(chan queued, c.QueueSize),
+		done:   make(chan struct{}),
+	}
+}
+
+func (b *batcher) close() {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.queue)
+	}
+	b.mu.Unlock()
+	<-b.done
+}
+
+// Callbacks run on the batcher goroutine, so they must not block.
+func (p *Producer) Enqueue(ctx context.Context, envelope domain.Envelope, callbacks ...func(error)) *Future {
+	ctx, span := p.span(ctx, envelope)
+	future := &Future{
+		done: make(chan struct{}),
+		callbacks: append([]func(error){func(err error) {
+			tracing.End(span, err)
+		}}, callbacks...),
+	}
+	if p.batcher == nil {
+		future.resolve(p.publish(ctx, envelope))
+		return future
+	}
+	message, err := p.message(ctx, envelope)
+	if err != nil {
+		future.resolve(err)
+		return future
+	}
+	p.batcher.mu.RLock()
+	defer p.batcher.mu.RUnlock()
+	if p.batcher.closed {
+		future.resolve(fmt.Errorf("%w: producer is closed", ErrClosed))
+		return future
+	}
+	p.inflight.Add(1)
+	select {
+	case p.batcher.queue <- queued{message: message, future: future}:
+	case <-ctx.Done():
+		p.inflight.Add(-1)
+		future.resolve(fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err()))
+	}
+	return future
+}
+
+func (p *Producer) runBatcher() {
+	defer close(p.batcher.done)
+	batch := make([]queued, 0, p.batcher.config.BatchSize)
+	linger := time.NewTimer(p.batcher.config.Linger)
+	linger.Stop()
+	for {
+		select {
+		case q, ok := <-p.batcher.queue:
+			if !ok {
+				p.flush(batch)
+				return
+			}
+			if len(batch) == 0 {
+				linger.Reset(p.batcher.config.Linger)
+			}
+			batch = append(batch, q)
+			if len(batch) < p.batcher.config.BatchSize {
+				continue
+			}
+			linger.Stop()
+		case <-linger.C:
+		}
+		p.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+func (p *Producer) flush(batch []queued) {
+	if len(batch) == 0 {
+		return
+	}
+	defer p.inflight.Add(-int64(len(batch)))
+	messages := make([]kafka.Message, len(batch))
+	for i, q := range batch {
+		messages[i] = q.message
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.batcher.config.WriteTimeout)
+	defer cancel()
+	err := p.producer.WriteMessages(ctx, messages...)
+	var perMessage kafka.WriteErrors
+	if errors.As(err, &perMessage) && len(perMessage) == len(batch) {
+		for i, q := range batch {
+			q.future.resolve(mapWriteError(perMessage[i]))
+		}
+		return
+	}
+	for _, q := range batch {
+		q.future.resolve(mapWriteError(err))
+	}
+}
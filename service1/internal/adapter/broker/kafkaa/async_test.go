@@ -0,0 +1,92 @@
+package kafkaa
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"service1/internal/domain"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAsyncProducer(publisher *mockPublisher, batchSize int) *Producer {
+	p := &Producer{
+		producer: publisher,
+		key:      TaskID,
+		encoder:  &mockEncoder{},
+		batcher:  newBatcher(Async{BatchSize: batchSize, Linger: 10 * time.Millisecond, QueueSize: 8, WriteTimeout: time.Second}),
+	}
+	go p.runBatcher()
+	return p
+}
+
+func Test_Enqueue_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name      string
+		publisher *mockPublisher
+		batchSize int
+		events    int
+		sizes     []int
+		errs      []error
+	}{
+		{
+			name:      "full batch and linger",
+			publisher: &mockPublisher{},
+			batchSize: 2,
+			events:    3,
+			sizes:     []int{2, 1},
+			errs:      []error{nil, nil, nil},
+		},
+		{
+			name:      "batch failure",
+			publisher: &mockPublisher{wm: mockWriteMessages{err: errors.New("")}},
+			batchSize: 2,
+			events:    2,
+			sizes:     []int{2},
+			errs:      []error{ErrProducingEvent, ErrProducingEvent},
+		},
+		{
+			name:      "per message failure",
+			publisher: &mockPublisher{wm: mockWriteMessages{err: kafka.WriteErrors{nil, errors.New("")}}},
+			batchSize: 2,
+			events:    2,
+			sizes:     []int{2},
+			errs:      []error{nil, ErrProducingEvent},
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			p := newAsyncProducer(cs.publisher, cs.batchSize)
+			var called int
+			futures := make([]*Future, cs.events)
+			for i := range futures {
+				futures[i] = p.Enqueue(context.Background(), domain.Envelope{Type: domain.ActionUpdate}, func(error) {
+					called++
+				})
+			}
+			for i, f := range futures {
+				assert.ErrorIs(t, f.Wait(context.Background()), cs.errs[i])
+			}
+			assert.NoError(t, p.Close())
+			assert.Equal(t, cs.events, called)
+			assert.Equal(t, cs.sizes, cs.publisher.wm.sizes)
+			assert.Equal(t, int64(0), p.InFlight())
+		})
+	}
+}
+
+func Test_EnqueueClosed_Unit(t *testing.T) {
+	t.Parallel()
+	p := newAsyncProducer(&mockPublisher{}, 10)
+	f := p.Enqueue(context.Background(), domain.Envelope{})
+	assert.NoError(t, p.Close())
+	assert.NoError(t, f.Err())
+	assert.ErrorIs(t, p.Enqueue(context.Background(), domain.Envelope{}).Err(), ErrClosed)
+
+	sync := &Producer{producer: &mockPublisher{wm: mockWriteMessages{err: kafka.ErrGroupClosed}}, key: TaskID, encoder: &mockEncoder{}}
+	assert.ErrorIs(t, sync.Enqueue(context.Background(), domain.Envelope{}).Err(), ErrClosed)
+}
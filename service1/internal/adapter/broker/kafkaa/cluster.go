@@ -0,0 +1,53 @@
+package kafkaa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/segmentio/kafka-go"
+)
+
+var (
+	ErrUnreachable = errors.New("kafka: brokers unreachable")
+	ErrNotStarted  = errors.New("kafka: consumer is not started")
+	ErrNotJoined   = errors.New("kafka: consumer group not joined")
+)
+
+const groupStable = "Stable"
+
+type Cluster interface {
+	Metadata(ctx context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error)
+	DescribeGroups(ctx context.Context, req *kafka.DescribeGroupsRequest) (*kafka.DescribeGroupsResponse, error)
+}
+
+func newCluster(brokers []string) Cluster {
+	return &kafka.Client{Addr: kafka.TCP(brokers...)}
+}
+
+func ping(ctx context.Context, cluster Cluster) error {
+	if _, err := cluster.Metadata(ctx, &kafka.MetadataRequest{}); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnreachable, err)
+	}
+	return nil
+}
+
+func joined(ctx context.Context, cluster Cluster, groupID string) error {
+	resp, err := cluster.DescribeGroups(ctx, &kafka.DescribeGroupsRequest{GroupIDs: []string{groupID}})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnreachable, err)
+	}
+	for _, group := range resp.Groups {
+		if group.GroupID != groupID {
+			continue
+		}
+		if group.Error != nil {
+			return fmt.Errorf("%w: %v", ErrNotJoined, group.Error)
+		}
+		if group.GroupState != groupStable || len(group.Members) == 0 {
+			return fmt.Errorf("%w: group %q is %s with %d members", ErrNotJoined, groupID, group.GroupState, len(group.Members))
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: group %q not described", ErrNotJoined, groupID)
+}
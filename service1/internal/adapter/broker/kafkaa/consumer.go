@@ -0,0 +1,277 @@
+package kafkaa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"service1/internal/pkg/tracing"
+
+	"github.com/segmentio/kafka-go"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrFetchingMessages = errors.New("kafka: failed while trying to fetch new messages")
+	ErrCommitting       = errors.New("kafka: failed to commit offset")
+	ErrClosingConsumer  = errors.New("kafka: failed to close consumer")
+	ErrTooManyRetries   = errors.New("kafka: too many retries")
+	ErrInvalidBackoff   = errors.New("kafka: handler backoff must be positive")
+	ErrDeadLettering    = errors.New("kafka: failed to forward message to dead-letter topic")
+)
+
+const (
+	HeaderFailureReason     = "x-failure-reason"
+	HeaderAttempts          = "x-attempts"
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+)
+
+type ConsumerConfig struct {
+	Brokers        []string
+	Topic          string        `yaml:"topic"`
+	GroupID        string        `yaml:"group_id"`
+	CommitInterval time.Duration `yaml:"commit_interval"`
+	SessionTimeout time.Duration `yaml:"session_timeout"`
+	StartOffset    int           `yaml:"start_offset"`
+
+	RetryAmount int `yaml:"retry_amount"`
+
+	HandlerRetryAmount int           `yaml:"handler_retry_amount"`
+	HandlerBackoff     time.Duration `yaml:"handler_backoff"`
+	HandlerMaxBackoff  time.Duration `yaml:"handler_max_backoff"`
+	DeadLetterTopic    string        `yaml:"dead_letter_topic"`
+
+	Handler Handler
+	Logger  *slog.Logger
+}
+
+type Reader interface {
+	Close() error
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	Lag() int64
+	Stats() kafka.ReaderStats
+}
+
+// Handler returns an error only for failures worth retrying; the consumer
+// redelivers the message up to HandlerRetryAmount times, then forwards it to
+// the dead-letter topic and moves on.
+type Handler interface {
+	Route(ctx context.Context, message kafka.Message) error
+}
+
+type Consumer struct {
+	config ConsumerConfig
+
+	reader     Reader
+	deadLetter Publisher
+	cluster    Cluster
+	handler    Handler
+	logger     *slog.Logger
+
+	running atomic.Bool
+}
+
+func NewConsumer(c ConsumerConfig) (*Consumer, error) {
+	if c.HandlerBackoff <= 0 || c.HandlerMaxBackoff <= 0 {
+		return nil, fmt.Errorf("%w: backoff %s, max backoff %s", ErrInvalidBackoff, c.HandlerBackoff, c.HandlerMaxBackoff)
+	}
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:        c.Brokers,
+		Topic:          c.Topic,
+		GroupID:        c.GroupID,
+		CommitInterval: c.CommitInterval,
+		SessionTimeout: c.SessionTimeout,
+		StartOffset:    int64(c.StartOffset),
+	})
+	deadLetter := &kafka.Writer{
+		Addr:                   kafka.TCP(c.Brokers...),
+		Topic:                  c.DeadLetterTopic,
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+	}
+	return &Consumer{
+		config:     c,
+		reader:     reader,
+		deadLetter: deadLetter,
+		cluster:    newCluster(c.Brokers),
+		handler:    c.Handler,
+		logger:     c.Logger,
+	}, nil
+}
+
+func (c *Consumer) Run(ctx context.Context) error {
+	c.running.Store(true)
+	defer c.running.Store(false)
+
+	backoff := time.Second * 0
+	for a := 0; a <= c.config.RetryAmount; a++ {
+		if consErr := c.consume(ctx, backoff); consErr != nil {
+			if errors.Is(consErr, ErrOperationCanceled) {
+				return consErr
+			}
+			if a == c.config.RetryAmount {
+				return fmt.Errorf("%w: %v", ErrTooManyRetries, consErr)
+			}
+			switch backoff {
+			case 0:
+				backoff = time.Second * 2
+			default:
+				backoff *= 2
+			}
+			c.logger.WarnContext(ctx, "consume", slog.Int("attempt", a+1), slog.Duration("backoff", backoff), slog.Any("error", consErr))
+			continue
+		}
+		a = 0
+		backoff = 0
+	}
+
+	return nil
+}
+
+func (c *Consumer) consume(ctx context.Context, backoff time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
+	case <-time.After(backoff):
+		message, fetchErr := c.reader.FetchMessage(ctx)
+		if fetchErr != nil {
+			switch {
+			case errors.Is(fetchErr, context.Canceled):
+				return fmt.Errorf("%w: %v", ErrOperationCanceled, fetchErr)
+			default:
+				return fmt.Errorf("%w: %v", ErrFetchingMessages, fetchErr)
+			}
+		}
+		if procErr := c.process(ctx, message); procErr != nil {
+			return procErr
+		}
+		if commitErr := c.reader.CommitMessages(ctx, message); commitErr != nil {
+			switch {
+			case errors.Is(commitErr, context.Canceled):
+				return fmt.Errorf("%w: %v", ErrOperationCanceled, commitErr)
+			default:
+				return fmt.Errorf("%w: %v", ErrCommitting, commitErr)
+			}
+		}
+		return nil
+	}
+}
+
+func (c *Consumer) process(ctx context.Context, message kafka.Message) error {
+	var routeErr error
+	var attempts int
+	for attempts < c.config.HandlerRetryAmount+1 {
+		if attempts > 0 {
+			backoff := c.backoff(attempts)
+			c.logger.WarnContext(ctx, "route", slog.Int("attempt", attempts), slog.Duration("backoff", backoff), slog.Any("error", routeErr))
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
+			case <-time.After(backoff):
+			}
+		}
+		attempts++
+		routeErr = c.route(ctx, message)
+		if routeErr == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
+		}
+	}
+	return c.forward(ctx, message, routeErr, attempts)
+}
+
+func (c *Consumer) forward(ctx context.Context, message kafka.Message, reason error, attempts int) error {
+	headers := append(slices.Clone(message.Headers),
+		kafka.Header{Key: HeaderFailureReason, Value: []byte(reason.Error())},
+		kafka.Header{Key: HeaderAttempts, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: HeaderOriginalTopic, Value: []byte(message.Topic)},
+		kafka.Header{Key: HeaderOriginalPartition, Value: []byte(strconv.Itoa(message.Partition))},
+		kafka.Header{Key: HeaderOriginalOffset, Value: []byte(strconv.FormatInt(message.Offset, 10))},
+	)
+	dead := kafka.Message{
+		Key:     message.Key,
+		Value:   message.Value,
+		Headers: headers,
+		Time:    time.Now(),
+	}
+	for a := 1; ; a++ {
+		writeErr := c.deadLetter.WriteMessages(ctx, dead)
+		if writeErr == nil {
+			c.logger.WarnContext(ctx, "message dead-lettered",
+				slog.String("topic", message.Topic),
+				slog.Int("partition", message.Partition),
+				slog.Int64("offset", message.Offset),
+				slog.Int("attempts", attempts),
+				slog.Any("error", reason),
+			)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
+		}
+		c.logger.ErrorContext(ctx, "dead-letter", slog.Int64("offset", message.Offset), slog.Any("error", fmt.Errorf("%w: %v", ErrDeadLettering, writeErr)))
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
+		case <-time.After(c.backoff(a)):
+		}
+	}
+}
+
+func (c *Consumer) backoff(attempts int) time.Duration {
+	backoff := c.config.HandlerBackoff
+	for a := 1; a < attempts && backoff < c.config.HandlerMaxBackoff; a++ {
+		backoff *= 2
+	}
+	return min(backoff, c.config.HandlerMaxBackoff)
+}
+
+func (c *Consumer) route(ctx context.Context, message kafka.Message) error {
+	ctx = tracing.ExtractHeaders(ctx, message.Headers)
+	ctx, span := tracing.Start(ctx, message.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeProcess,
+			semconv.MessagingDestinationName(message.Topic),
+			semconv.MessagingDestinationPartitionID(strconv.Itoa(message.Partition)),
+			semconv.MessagingKafkaOffset(int(message.Offset)),
+			semconv.MessagingKafkaMessageKey(string(message.Key)),
+		),
+	)
+	err := c.handler.Route(ctx, message)
+	tracing.End(span, err)
+	return err
+}
+
+func (c *Consumer) Stats() kafka.ReaderStats {
+	return c.reader.Stats()
+}
+
+func (c *Consumer) Ping(ctx context.Context) error {
+	if !c.running.Load() {
+		return fmt.Errorf("%w", ErrNotStarted)
+	}
+	return joined(ctx, c.cluster, c.config.GroupID)
+}
+
+func (c *Consumer) Shutdown() error {
+	if err := c.reader.Close(); err != nil {
+		return fmt.Errorf("%w: %v", ErrClosingConsumer, err)
+	}
+	if err := c.deadLetter.Close(); err != nil {
+		return fmt.Errorf("%w: %v", ErrClosingConsumer, err)
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+package kafkaa
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+var discard = slog.New(slog.DiscardHandler)
+
+type mockReader struct {
+	c  mockClose
+	cm mockCommitMessages
+	fm mockFetchMessage
+}
+
+type mockCommitMessages struct {
+	committed int
+	err       error
+}
+
+type mockFetchMessage struct {
+	message kafka.Message
+	err     error
+}
+
+func (m *mockReader) Close() error {
+	return m.c.err
+}
+
+func (m *mockReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	m.cm.committed++
+	return m.cm.err
+}
+
+func (m *mockReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	return m.fm.message, m.fm.err
+}
+
+func (m *mockReader) Lag() int64 {
+	return 0
+}
+
+func (m *mockReader) Stats() kafka.ReaderStats {
+	return kafka.ReaderStats{}
+}
+
+type mockHandler struct {
+	routed   int
+	failures int
+	cancel   context.CancelFunc
+}
+
+func (m *mockHandler) Route(ctx context.Context, message kafka.Message) error {
+	m.routed++
+	if m.routed > m.failures {
+		return nil
+	}
+	if m.cancel != nil {
+		m.cancel()
+	}
+	return errors.New("storage failed")
+}
+
+func Test_consume_Unit(t *testing.T) {
+	t.Parallel()
+	canceled, cancel := context.WithCancel(context.Background())
+	cases := []struct {
+		name         string
+		ctx          context.Context
+		consumer     *Consumer
+		routed       int
+		committed    int
+		deadLettered int
+		err          error
+	}{
+		{
+			name: "success",
+			ctx:  context.Background(),
+			consumer: &Consumer{
+				reader:  &mockReader{},
+				handler: &mockHandler{},
+			},
+			routed:    1,
+			committed: 1,
+			err:       nil,
+		},
+		{
+			name: "route retried until it succeeds",
+			ctx:  context.Background(),
+			consumer: &Consumer{
+				config:  ConsumerConfig{HandlerRetryAmount: 3},
+				reader:  &mockReader{},
+				handler: &mockHandler{failures: 2},
+				logger:  discard,
+			},
+			routed:    3,
+			committed: 1,
+			err:       nil,
+		},
+		{
+			name: "dead-lettered once retries run out",
+			ctx:  context.Background(),
+			consumer: &Consumer{
+				config:     ConsumerConfig{HandlerRetryAmount: 2},
+				reader:     &mockReader{},
+				deadLetter: &mockPublisher{},
+				handler:    &mockHandler{failures: 10},
+				logger:     discard,
+			},
+			routed:       3,
+			committed:    1,
+			deadLettered: 1,
+			err:          nil,
+		},
+		{
+			name: "context closed while retrying",
+			ctx:  canceled,
+			consumer: &Consumer{
+				config:  ConsumerConfig{HandlerRetryAmount: 1, HandlerBackoff: time.Minute, HandlerMaxBackoff: time.Minute},
+				reader:  &mockReader{},
+				handler: &mockHandler{failures: 1, cancel: cancel},
+				logger:  discard,
+			},
+			routed:    1,
+			committed: 0,
+			err:       ErrOperationCanceled,
+		},
+		{
+			name: "failed to fetch",
+			ctx:  context.Background(),
+			consumer: &Consumer{
+				reader:  &mockReader{fm: mockFetchMessage{err: errors.New("")}},
+				handler: &mockHandler{},
+			},
+			routed: 0,
+			err:    ErrFetchingMessages,
+		},
+		{
+			name: "context closed mid fetch",
+			ctx:  context.Background(),
+			consumer: &Consumer{
+				reader:  &mockReader{fm: mockFetchMessage{err: context.Canceled}},
+				handler: &mockHandler{},
+			},
+			routed: 0,
+			err:    ErrOperationCanceled,
+		},
+		{
+			name: "failed to commit",
+			ctx:  context.Background(),
+			consumer: &Consumer{
+				reader:  &mockReader{cm: mockCommitMessages{err: errors.New("")}},
+				handler: &mockHandler{},
+			},
+			routed:    1,
+			committed: 1,
+			err:       ErrCommitting,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			err := cs.consumer.consume(cs.ctx, 0)
+			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.routed, cs.consumer.handler.(*mockHandler).routed)
+			assert.Equal(t, cs.committed, cs.consumer.reader.(*mockReader).cm.committed)
+			if dl, ok := cs.consumer.deadLetter.(*mockPublisher); ok {
+				assert.Len(t, dl.wm.messages, cs.deadLettered)
+				for _, m := range dl.wm.messages {
+					assert.Equal(t, "3", Header(m, HeaderAttempts))
+					assert.Equal(t, "storage failed", Header(m, HeaderFailureReason))
+				}
+			}
+		})
+	}
+}
+
+func Test_NewConsumer_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name   string
+		config ConsumerConfig
+		err    error
+	}{
+		{
+			name:   "success",
+			config: ConsumerConfig{Brokers: []string{"localhost:9092"}, Topic: "t", GroupID: "g", HandlerBackoff: time.Second, HandlerMaxBackoff: time.Minute},
+			err:    nil,
+		},
+		{
+			name:   "unset backoff",
+			config: ConsumerConfig{HandlerMaxBackoff: time.Minute},
+			err:    ErrInvalidBackoff,
+		},
+		{
+			name:   "unset max backoff",
+			config: ConsumerConfig{HandlerBackoff: time.Second},
+			err:    ErrInvalidBackoff,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			consumer, err := NewConsumer(cs.config)
+			assert.ErrorIs(t, err, cs.err)
+			if err == nil {
+				assert.NoError(t, consumer.Shutdown())
+			}
+		})
+	}
+}
+
+func Test_Shutdown_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		consumer *Consumer
+		err      error
+	}{
+		{
+			name:     "success",
+			consumer: &Consumer{reader: &mockReader{}, deadLetter: &mockPublisher{}},
+			err:      nil,
+		},
+		{
+			name:     "failed to close",
+			consumer: &Consumer{reader: &mockReader{c: mockClose{err: errors.New("")}}, deadLetter: &mockPublisher{}},
+			err:      ErrClosingConsumer,
+		},
+		{
+			name:     "failed to close dead-letter writer",
+			consumer: &Consumer{reader: &mockReader{}, deadLetter: &mockPublisher{c: mockClose{err: errors.New("")}}},
+			err:      ErrClosingConsumer,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			err := cs.consumer.Shutdown()
+			assert.ErrorIs(t, err, cs.err)
+		})
+	}
+}
+
+type mockCluster struct {
+	metadataErr error
+	groups      *kafka.DescribeGroupsResponse
+	groupsErr   error
+}
+
+func (m *mockCluster) Metadata(ctx context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error) {
+	return &kafka.MetadataResponse{}, m.metadataErr
+}
+
+func (m *mockCluster) DescribeGroups(ctx context.Context, req *kafka.DescribeGroupsRequest) (*kafka.DescribeGroupsResponse, error) {
+	return m.groups, m.groupsErr
+}
+
+func Test_Ping_Unit(t *testing.T) {
+	t.Parallel()
+	stable := &kafka.DescribeGroupsResponse{Groups: []kafka.DescribeGroupsResponseGroup{{
+		GroupID:    "group",
+		GroupState: "Stable",
+		Members:    []kafka.DescribeGroupsResponseMember{{MemberID: "member"}},
+	}}}
+	cases := []struct {
+		name    string
+		running bool
+		cluster *mockCluster
+		err     error
+	}{
+		{
+			name:    "success",
+			running: true,
+			cluster: &mockCluster{groups: stable},
+			err:     nil,
+		},
+		{
+			name:    "not started",
+			running: false,
+			cluster: &mockCluster{groups: stable},
+			err:     ErrNotStarted,
+		},
+		{
+			name:    "brokers unreachable",
+			running: true,
+			cluster: &mockCluster{groupsErr: errors.New("")},
+			err:     ErrUnreachable,
+		},
+		{
+			name:    "group rebalancing",
+			running: true,
+			cluster: &mockCluster{groups: &kafka.DescribeGroupsResponse{Groups: []kafka.DescribeGroupsResponseGroup{{
+				GroupID:    "group",
+				GroupState: "PreparingRebalance",
+			}}}},
+			err: ErrNotJoined,
+		},
+		{
+			name:    "group missing",
+			running: true,
+			cluster: &mockCluster{groups: &kafka.DescribeGroupsResponse{}},
+			err:     ErrNotJoined,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			consumer := &Consumer{config: ConsumerConfig{GroupID: "group"}, cluster: cs.cluster}
+			consumer.running.Store(cs.running)
+			err := consumer.Ping(context.Background())
+			assert.ErrorIs(t, err, cs.err)
+		})
+	}
+}
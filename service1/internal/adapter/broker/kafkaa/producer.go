@@ -4,11 +4,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"service1/internal/domain"
+	"service1/internal/pkg/logger"
+	"service1/internal/pkg/tracing"
 
 	"github.com/segmentio/kafka-go"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	HeaderRequestID   = "x-request-id"
+	HeaderContentType = "content-type"
+	HeaderAction      = "action"
 )
 
 var (
@@ -22,12 +33,16 @@ var (
 
 type Config struct {
 	Address            []string
+	Name               string        `yaml:"name"`
 	Topic              string        `yaml:"topic"`
 	BatchTimeout       time.Duration `yaml:"batch_timeout"`
 	RequiredAcks       int           `yaml:"required_acks"`
 	AllowTopicCreation bool          `yaml:"allow_topic_creation"`
+	Key                string        `yaml:"key"`
+	Async              Async         `yaml:"async"`
 
-	Encoder Encoder
+	Encoder   Encoder
+	Extractor KeyExtractor
 }
 
 type Publisher interface {
@@ -38,53 +53,144 @@ type Publisher interface {
 
 type Encoder interface {
 	Marshal(data any) ([]byte, error)
+	ContentType() string
 }
 
 type Producer struct {
 	producer Publisher
+	cluster  Cluster
+	topic    string
+	name     string
+	inflight atomic.Int64
+	batcher  *batcher
 
+	key     KeyExtractor
 	encoder Encoder
 }
 
 func New(c Config) *Producer {
-	return &Producer{
-		producer: &kafka.Writer{
-			Addr:                   kafka.TCP(c.Address...),
-			Topic:                  c.Topic,
-			BatchTimeout:           c.BatchTimeout,
-			RequiredAcks:           kafka.RequiredAcks(c.RequiredAcks),
-			AllowAutoTopicCreation: c.AllowTopicCreation,
-		},
+	writer := &kafka.Writer{
+		Addr:                   kafka.TCP(c.Address...),
+		Topic:                  c.Topic,
+		BatchTimeout:           c.BatchTimeout,
+		RequiredAcks:           kafka.RequiredAcks(c.RequiredAcks),
+		AllowAutoTopicCreation: c.AllowTopicCreation,
+		Balancer:               &kafka.Hash{},
+	}
+	p := &Producer{
+		producer: writer,
+		cluster:  newCluster(c.Address),
+		topic:    c.Topic,
+		name:     c.Name,
 
+		key:     extractor(c.Extractor, c.Key),
 		encoder: c.Encoder,
 	}
+	if c.Async.Enabled {
+		writer.BatchSize = c.Async.BatchSize
+		p.batcher = newBatcher(c.Async)
+		go p.runBatcher()
+	}
+	return p
 }
 
 func (p *Producer) Close() error {
+	if p.batcher != nil {
+		p.batcher.close()
+	}
 	if err := p.producer.Close(); err != nil {
 		return fmt.Errorf("%w: %v", ErrClosingConnection, err)
 	}
 	return nil
 }
 
-func (p *Producer) PublishEvent(ctx context.Context, event domain.Event) error {
-	eventByte, marshalErr := p.encoder.Marshal(event)
+func (p *Producer) Stats() kafka.WriterStats {
+	return p.producer.Stats()
+}
+
+func (p *Producer) InFlight() int64 {
+	return p.inflight.Load()
+}
+
+func (p *Producer) Ping(ctx context.Context) error {
+	return ping(ctx, p.cluster)
+}
+
+func (p *Producer) PublishEvent(ctx context.Context, envelope domain.Envelope) error {
+	ctx, span := p.span(ctx, envelope)
+	err := p.publish(ctx, envelope)
+	tracing.End(span, err)
+	return err
+}
+
+func (p *Producer) span(ctx context.Context, envelope domain.Envelope) (context.Context, trace.Span) {
+	return tracing.Start(ctx, p.topic+" send",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeSend,
+			semconv.MessagingDestinationName(p.topic),
+			semconv.MessagingKafkaMessageKey(string(p.key(envelope))),
+			semconv.MessagingMessageID(envelope.ID),
+		),
+	)
+}
+
+func (p *Producer) publish(ctx context.Context, envelope domain.Envelope) error {
+	message, err := p.message(ctx, envelope)
+	if err != nil {
+		return err
+	}
+	p.inflight.Add(1)
+	defer p.inflight.Add(-1)
+	return mapWriteError(p.producer.WriteMessages(ctx, message))
+}
+
+func (p *Producer) message(ctx context.Context, envelope domain.Envelope) (kafka.Message, error) {
+	envelope.Producer = p.name
+	eventByte, marshalErr := p.encoder.Marshal(envelope)
 	if marshalErr != nil {
-		return fmt.Errorf("%w: %v", ErrMarshalingEvent, marshalErr)
+		return kafka.Message{}, fmt.Errorf("%w: %v", ErrMarshalingEvent, marshalErr)
+	}
+	message := kafka.Message{
+		Key:     p.key(envelope),
+		Value:   eventByte,
+		Headers: headers(ctx, p.encoder.ContentType(), envelope.Type),
+		Time:    time.Now(),
+	}
+	tracing.InjectHeaders(ctx, &message.Headers)
+	return message, nil
+}
+
+func mapWriteError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.Canceled):
+		return fmt.Errorf("%w: %v", ErrOperationCanceled, err)
+	case errors.Is(err, kafka.ErrGroupClosed):
+		return fmt.Errorf("%w: %v", ErrClosed, err)
+	default:
+		return fmt.Errorf("%w: %v", ErrProducingEvent, err)
 	}
-	if writeErr := p.producer.WriteMessages(ctx, kafka.Message{
-		Key:   []byte(domain.ActionUpdate),
-		Value: eventByte,
-		Time:  time.Now(),
-	}); writeErr != nil {
-		switch {
-		case errors.Is(writeErr, context.Canceled):
-			return fmt.Errorf("%w: %v", ErrOperationCanceled, writeErr)
-		case errors.Is(writeErr, kafka.ErrGroupClosed):
-			return fmt.Errorf("%w: %v", ErrClosed, writeErr)
-		default:
-			return fmt.Errorf("%w: %v", ErrProducingEvent, writeErr)
+}
+
+func headers(ctx context.Context, contentType string, action domain.Action) []kafka.Header {
+	h := []kafka.Header{
+		{Key: HeaderContentType, Value: []byte(contentType)},
+		{Key: HeaderAction, Value: []byte(action)},
+	}
+	if id := logger.RequestID(ctx); id != "" {
+		h = append(h, kafka.Header{Key: HeaderRequestID, Value: []byte(id)})
+	}
+	return h
+}
+
+func Header(message kafka.Message, key string) string {
+	for _, h := range message.Headers {
+		if h.Key == key {
+			return string(h.Value)
 		}
 	}
-	return nil
+	return ""
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"service1/internal/domain"
+	"service1/internal/pkg/logger"
 
 	"github.com/segmentio/kafka-go"
 	"github.com/stretchr/testify/assert"
@@ -26,7 +27,9 @@ type mockStats struct {
 }
 
 type mockWriteMessages struct {
-	err error
+	sizes    []int
+	messages []kafka.Message
+	err      error
 }
 
 func (m *mockPublisher) Close() error {
@@ -38,6 +41,8 @@ func (m *mockPublisher) Stats() kafka.WriterStats {
 }
 
 func (m *mockPublisher) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	m.wm.sizes = append(m.wm.sizes, len(msgs))
+	m.wm.messages = append(m.wm.messages, msgs...)
 	return m.wm.err
 }
 
@@ -50,6 +55,10 @@ func (m *mockEncoder) Marshal(data any) ([]byte, error) {
 	return m.b, m.err
 }
 
+func (m *mockEncoder) ContentType() string {
+	return "application/json"
+}
+
 func Test_Close_Unit(t *testing.T) {
 	t.Parallel()
 	cases := []struct {
@@ -85,85 +94,161 @@ func Test_PublishEvent_Unit(t *testing.T) {
 	cases := []struct {
 		name     string
 		ctx      context.Context
+		action   domain.Action
 		event    domain.Event
 		producer *Producer
 		err      error
 	}{
 		{
-			name: "success",
-			ctx:  context.Background(),
+			name:   "success",
+			ctx:    context.Background(),
+			action: domain.ActionUpdate,
 			event: domain.Event{
 				Record: domain.Record{
-					ID: 0,
+					ID: "0",
 				},
 			},
 			producer: &Producer{
 				producer: &mockPublisher{
 					wm: mockWriteMessages{}},
+				key:     TaskID,
 				encoder: &mockEncoder{}},
 			err: nil,
 		},
 		{
-			name: "failed to marshal event",
-			ctx:  context.Background(),
+			name:   "failed to marshal event",
+			ctx:    context.Background(),
+			action: domain.ActionUpdate,
 			event: domain.Event{
 				Record: domain.Record{
-					ID: 0,
+					ID: "0",
 				},
 			},
 			producer: &Producer{
 				producer: &mockPublisher{
 					wm: mockWriteMessages{}},
+				key:     TaskID,
 				encoder: &mockEncoder{err: errors.New("")}},
 			err: ErrMarshalingEvent,
 		},
 		{
-			name: "group closed",
-			ctx:  context.Background(),
+			name:   "group closed",
+			ctx:    context.Background(),
+			action: domain.ActionUpdate,
 			event: domain.Event{
 				Record: domain.Record{
-					ID: 0,
+					ID: "0",
 				},
 			},
 			producer: &Producer{
 				producer: &mockPublisher{
 					wm: mockWriteMessages{err: kafka.ErrGroupClosed}},
+				key:     TaskID,
 				encoder: &mockEncoder{}},
 			err: ErrClosed,
 		},
 		{
-			name: "failed to produce event",
-			ctx:  context.Background(),
+			name:   "failed to produce event",
+			ctx:    context.Background(),
+			action: domain.ActionUpdate,
 			event: domain.Event{
 				Record: domain.Record{
-					ID: 0,
+					ID: "0",
 				},
 			},
 			producer: &Producer{
 				producer: &mockPublisher{
 					wm: mockWriteMessages{err: errors.New("")}},
+				key:     TaskID,
 				encoder: &mockEncoder{}},
 			err: ErrProducingEvent,
 		},
 		{
-			name: "context closed mid kafka call",
-			ctx:  context.Background(),
+			name:   "context closed mid kafka call",
+			ctx:    context.Background(),
+			action: domain.ActionUpdate,
 			event: domain.Event{
 				Record: domain.Record{
-					ID: 0,
+					ID: "0",
 				},
 			},
 			producer: &Producer{
 				producer: &mockPublisher{
 					wm: mockWriteMessages{err: context.Canceled}},
+				key:     TaskID,
 				encoder: &mockEncoder{}},
 			err: ErrOperationCanceled,
 		},
 	}
 	for _, cs := range cases {
 		t.Run(cs.name, func(t *testing.T) {
-			err := cs.producer.PublishEvent(cs.ctx, cs.event)
+			err := cs.producer.PublishEvent(cs.ctx, domain.Envelope{Type: cs.action, Payload: cs.event})
 			assert.ErrorIs(t, err, cs.err)
 		})
 	}
 }
+
+func Test_headers_Unit(t *testing.T) {
+	t.Parallel()
+	message := kafka.Message{Headers: headers(context.Background(), "application/json", domain.ActionUpdate)}
+	assert.Equal(t, "application/json", Header(message, HeaderContentType))
+	assert.Equal(t, string(domain.ActionUpdate), Header(message, HeaderAction))
+	assert.Equal(t, "", Header(message, HeaderRequestID))
+
+	message = kafka.Message{Headers: headers(logger.WithRequestID(context.Background(), "req-1"), "application/avro", domain.ActionStatus)}
+	assert.Equal(t, "application/avro", Header(message, HeaderContentType))
+	assert.Equal(t, "req-1", Header(message, HeaderRequestID))
+	assert.Equal(t, "", Header(message, "missing"))
+}
+
+func Test_extractor_Unit(t *testing.T) {
+	t.Parallel()
+	envelope := domain.Envelope{Type: domain.ActionUpdate, Payload: domain.Event{Record: domain.Record{ID: "42"}}}
+	custom := func(envelope domain.Envelope) []byte {
+		return []byte("custom")
+	}
+	cases := []struct {
+		name     string
+		override KeyExtractor
+		key      string
+		result   string
+	}{
+		{
+			name:   "default",
+			key:    "",
+			result: "42",
+		},
+		{
+			name:   "task id",
+			key:    KeyTaskID,
+			result: "42",
+		},
+		{
+			name:   "action",
+			key:    KeyAction,
+			result: string(domain.ActionUpdate),
+		},
+		{
+			name:     "override",
+			override: custom,
+			key:      KeyAction,
+			result:   "custom",
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			assert.Equal(t, cs.result, string(extractor(cs.override, cs.key)(envelope)))
+		})
+	}
+}
+
+func Test_PublishEvent_Key_Unit(t *testing.T) {
+	t.Parallel()
+	publisher := &mockPublisher{}
+	p := &Producer{producer: publisher, key: TaskID, encoder: &mockEncoder{}}
+	err := p.PublishEvent(context.Background(), domain.Envelope{Type: domain.ActionUpdate, Payload: domain.Event{Record: domain.Record{ID: "42"}}})
+	assert.NoError(t, err)
+	assert.Len(t, publisher.wm.messages, 1)
+	assert.Equal(t, "42", string(publisher.wm.messages[0].Key))
+	assert.Equal(t, string(domain.ActionUpdate), Header(publisher.wm.messages[0], HeaderAction))
+}
@@ -0,0 +1,511 @@
+package filelog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"service1/internal/adapter/storage/inmemory"
+	"service1/internal/domain"
+)
+
+var (
+	ErrOpening    = errors.New("filelog: failed to open log")
+	ErrRecovering = errors.New("filelog: failed to recover from log")
+	ErrCorrupted  = errors.New("filelog: log corrupted")
+	ErrWriting    = errors.New("filelog: failed to write log")
+	ErrClosing    = errors.New("filelog: failed to close log")
+	ErrCompacting = errors.New("filelog: failed to compact log")
+)
+
+type Config struct {
+	Path string `yaml:"path"`
+	Sync bool   `yaml:"sync"`
+	// CompactSize is the log size in bytes past which the log is rewritten
+	// as a snapshot of its live state; 0 disables compaction. It is compacted
+	// again only once it doubles the previous snapshot, so a large live set
+	// doesn't trigger a rewrite on every write.
+	CompactSize int64 `yaml:"compact_size"`
+
+	Encoder Encoder
+	Decoder Decoder
+	Logger  *slog.Logger
+}
+
+type Index interface {
+	Close() error
+	CreateTask(ctx context.Context, task domain.Record) (domain.ID, error)
+	UpdateOrCreateTask(ctx context.Context, task domain.Record) error
+	TransitionTask(ctx context.Context, id domain.ID, from domain.Status, to domain.Status) (domain.Record, error)
+	GetTaskByID(ctx context.Context, id domain.ID) (domain.Record, error)
+	GetTasks(ctx context.Context, query domain.Query) (domain.Page, error)
+	CountTasks(ctx context.Context) (map[domain.Status]int, error)
+	Ping(ctx context.Context) error
+	CreateTaskWithEvent(ctx context.Context, task domain.Record, entry domain.Outbox) (domain.ID, error)
+	UpdateTaskWithEvent(ctx context.Context, from domain.Status, task domain.Record, entry domain.Outbox) error
+	DeleteTaskWithEvent(ctx context.Context, id domain.ID, entry domain.Outbox) error
+	PendingEvents(ctx context.Context, due int64, limit int) ([]domain.Outbox, error)
+	GetEvent(ctx context.Context, id int) (domain.Outbox, error)
+	Events(ctx context.Context) ([]domain.Outbox, error)
+	RestoreEvent(ctx context.Context, entry domain.Outbox) error
+	UpdateEvent(ctx context.Context, entry domain.Outbox) error
+}
+
+type File interface {
+	io.ReadWriteCloser
+	Sync() error
+	Truncate(size int64) error
+	Seek(offset int64, whence int) (int64, error)
+}
+
+type Encoder interface {
+	Marshal(data any) ([]byte, error)
+}
+
+type Decoder interface {
+	Unmarshal(data []byte, v any) error
+}
+
+type op string
+
+var (
+	opPut    op = "put"
+	opCreate op = "create"
+	opUpdate op = "update"
+	opDelete op = "delete"
+	opEvent  op = "event"
+
+	opSnapshot op = "snapshot"
+	opOutbox   op = "outbox"
+)
+
+type logEntry struct {
+	Op     op             `json:"op"`
+	Record domain.Record  `json:"record"`
+	Outbox *domain.Outbox `json:"outbox,omitempty"`
+	Seq    int            `json:"seq,omitempty"`
+}
+
+type Storage struct {
+	mu sync.Mutex
+
+	path string
+	file File
+	size int64
+	sync bool
+	seq  int
+
+	compactSize int64
+	snapshot    int64
+
+	closed bool
+
+	index Index
+
+	encoder Encoder
+	decoder Decoder
+	logger  *slog.Logger
+}
+
+func New(c Config) (*Storage, error) {
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOpening, err)
+	}
+	file, err := os.OpenFile(c.Path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOpening, err)
+	}
+	s := &Storage{
+		path:        c.Path,
+		file:        file,
+		sync:        c.Sync,
+		compactSize: c.CompactSize,
+		index:       inmemory.New(),
+		encoder:     c.Encoder,
+		decoder:     c.Decoder,
+		logger:      c.Logger,
+	}
+	if err := s.recover(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Storage) recover() error {
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("%w: %v", ErrRecovering, err)
+	}
+	ctx := context.Background()
+	reader := bufio.NewReader(s.file)
+	var offset int64
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return fmt.Errorf("%w: %v", ErrRecovering, readErr)
+		}
+		torn := errors.Is(readErr, io.EOF) && len(line) > 0
+		if len(line) == 0 || torn {
+			break
+		}
+		var e logEntry
+		if err := s.decoder.Unmarshal(bytes.TrimSpace(line), &e); err != nil {
+			if _, peekErr := reader.Peek(1); errors.Is(peekErr, io.EOF) {
+				break
+			}
+			return fmt.Errorf("%w: offset %d: %v", ErrCorrupted, offset, err)
+		}
+		if err := s.apply(ctx, e); err != nil {
+			return fmt.Errorf("%w: %v", ErrRecovering, err)
+		}
+		offset += int64(len(line))
+	}
+	if err := s.file.Truncate(offset); err != nil {
+		return fmt.Errorf("%w: %v", ErrRecovering, err)
+	}
+	if _, err := s.file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("%w: %v", ErrRecovering, err)
+	}
+	s.size = offset
+	return nil
+}
+
+func (s *Storage) apply(ctx context.Context, e logEntry) error {
+	switch e.Op {
+	case opPut:
+		return s.index.UpdateOrCreateTask(ctx, e.Record)
+	case opCreate:
+		if e.Outbox == nil {
+			return fmt.Errorf("%w: create without outbox entry", ErrCorrupted)
+		}
+		s.seq = max(s.seq, e.Outbox.ID)
+		_, err := s.index.CreateTaskWithEvent(ctx, e.Record, *e.Outbox)
+		return err
+	case opUpdate:
+		if e.Outbox == nil {
+			return fmt.Errorf("%w: update without outbox entry", ErrCorrupted)
+		}
+		s.seq = max(s.seq, e.Outbox.ID)
+		current, err := s.index.GetTaskByID(ctx, e.Record.ID)
+		if err != nil {
+			return err
+		}
+		return s.index.UpdateTaskWithEvent(ctx, current.Status, e.Record, *e.Outbox)
+	case opDelete:
+		if e.Outbox == nil {
+			return fmt.Errorf("%w: delete without outbox entry", ErrCorrupted)
+		}
+		s.seq = max(s.seq, e.Outbox.ID)
+		return s.index.DeleteTaskWithEvent(ctx, e.Record.ID, *e.Outbox)
+	case opEvent:
+		if e.Outbox == nil {
+			return fmt.Errorf("%w: event without outbox entry", ErrCorrupted)
+		}
+		err := s.index.UpdateEvent(ctx, *e.Outbox)
+		if errors.Is(err, inmemory.ErrNotFound) {
+			return nil
+		}
+		return err
+	case opSnapshot:
+		s.seq = max(s.seq, e.Seq)
+		return nil
+	case opOutbox:
+		if e.Outbox == nil {
+			return fmt.Errorf("%w: outbox without entry", ErrCorrupted)
+		}
+		s.seq = max(s.seq, e.Outbox.ID)
+		return s.index.RestoreEvent(ctx, *e.Outbox)
+	default:
+		return fmt.Errorf("%w: unknown operation %q", ErrCorrupted, e.Op)
+	}
+}
+
+func (s *Storage) append(ctx context.Context, e logEntry) error {
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", inmemory.ErrOperationCanceled, ctx.Err())
+	default:
+	}
+	data, err := s.encoder.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrWriting, err)
+	}
+	data = append(data, '\n')
+	if _, err := s.file.Write(data); err != nil {
+		s.rollback()
+		return fmt.Errorf("%w: %v", ErrWriting, err)
+	}
+	if s.sync {
+		if err := s.file.Sync(); err != nil {
+			s.rollback()
+			return fmt.Errorf("%w: %v", ErrWriting, err)
+		}
+	}
+	s.size += int64(len(data))
+	return nil
+}
+
+// compact rewrites the log as one snapshot line carrying the outbox sequence,
+// a put per live task and an outbox line per undelivered entry. The snapshot
+// is synced to a temporary file and renamed over the log, so a crash leaves
+// either the old log or the new one.
+func (s *Storage) compact(ctx context.Context) {
+	if s.compactSize <= 0 || s.size < max(s.compactSize, 2*s.snapshot) {
+		return
+	}
+	if err := s.rewrite(context.WithoutCancel(ctx)); err != nil {
+		s.logger.WarnContext(ctx, "compact", slog.Int64("size", s.size), slog.Any("error", err))
+	}
+}
+
+func (s *Storage) rewrite(ctx context.Context) error {
+	page, err := s.index.GetTasks(ctx, domain.Query{})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCompacting, err)
+	}
+	events, err := s.index.Events(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCompacting, err)
+	}
+	entries := make([]logEntry, 0, 1+len(page.Records)+len(events))
+	entries = append(entries, logEntry{Op: opSnapshot, Seq: s.seq})
+	for _, task := range page.Records {
+		entries = append(entries, logEntry{Op: opPut, Record: task})
+	}
+	for _, event := range events {
+		if event.State == domain.OutboxDelivered {
+			continue
+		}
+		entries = append(entries, logEntry{Op: opOutbox, Outbox: &event})
+	}
+	var buf bytes.Buffer
+	for _, e := range entries {
+		data, err := s.encoder.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrCompacting, err)
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	tmp := s.path + ".compact"
+	file, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCompacting, err)
+	}
+	if err := replace(file, tmp, s.path, buf.Bytes()); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("%w: %v", ErrCompacting, err)
+	}
+	s.file.Close()
+	s.file = file
+	s.size = int64(buf.Len())
+	s.snapshot = s.size
+	return nil
+}
+
+func replace(file *os.File, from, to string, data []byte) error {
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := os.Rename(from, to); err != nil {
+		return err
+	}
+	dir, err := os.Open(filepath.Dir(to))
+	if err != nil {
+		return nil
+	}
+	defer dir.Close()
+	dir.Sync()
+	return nil
+}
+
+func (s *Storage) rollback() {
+	s.file.Truncate(s.size)
+	s.file.Seek(s.size, io.SeekStart)
+}
+
+func (s *Storage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.index.Close()
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("%w: %v", ErrClosing, err)
+	}
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("%w: %v", ErrClosing, err)
+	}
+	return nil
+}
+
+func (s *Storage) CreateTask(ctx context.Context, task domain.Record) (domain.ID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, getErr := s.index.GetTaskByID(ctx, task.ID)
+	switch {
+	case getErr == nil:
+		return "", fmt.Errorf("%w", inmemory.ErrAlreadyExists)
+	case !errors.Is(getErr, inmemory.ErrNotFound):
+		return "", getErr
+	}
+	if err := s.append(ctx, logEntry{Op: opPut, Record: task}); err != nil {
+		return "", err
+	}
+	id, err := s.index.CreateTask(context.WithoutCancel(ctx), task)
+	if err != nil {
+		return "", err
+	}
+	s.compact(ctx)
+	return id, nil
+}
+
+func (s *Storage) UpdateOrCreateTask(ctx context.Context, task domain.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.append(ctx, logEntry{Op: opPut, Record: task}); err != nil {
+		return err
+	}
+	if err := s.index.UpdateOrCreateTask(context.WithoutCancel(ctx), task); err != nil {
+		return err
+	}
+	s.compact(ctx)
+	return nil
+}
+
+func (s *Storage) TransitionTask(ctx context.Context, id domain.ID, from domain.Status, to domain.Status) (domain.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, err := s.index.GetTaskByID(ctx, id)
+	if err != nil {
+		return domain.Record{}, err
+	}
+	if task.Status != from {
+		return domain.Record{}, fmt.Errorf("%w: task %s is %s, not %s", inmemory.ErrConflict, id, task.Status, from)
+	}
+	task.Status = to
+	if err := s.append(ctx, logEntry{Op: opPut, Record: task}); err != nil {
+		return domain.Record{}, err
+	}
+	task, err = s.index.TransitionTask(context.WithoutCancel(ctx), id, from, to)
+	if err != nil {
+		return domain.Record{}, err
+	}
+	s.compact(ctx)
+	return task, nil
+}
+
+func (s *Storage) GetTaskByID(ctx context.Context, id domain.ID) (domain.Record, error) {
+	return s.index.GetTaskByID(ctx, id)
+}
+
+func (s *Storage) GetTasks(ctx context.Context, query domain.Query) (domain.Page, error) {
+	return s.index.GetTasks(ctx, query)
+}
+
+func (s *Storage) Ping(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fmt.Errorf("%w: %v", inmemory.ErrClosed, os.ErrClosed)
+	}
+	return s.index.Ping(ctx)
+}
+
+func (s *Storage) CountTasks(ctx context.Context) (map[domain.Status]int, error) {
+	return s.index.CountTasks(ctx)
+}
+
+func (s *Storage) CreateTaskWithEvent(ctx context.Context, task domain.Record, entry domain.Outbox) (domain.ID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, getErr := s.index.GetTaskByID(ctx, task.ID)
+	switch {
+	case getErr == nil:
+		return "", fmt.Errorf("%w", inmemory.ErrAlreadyExists)
+	case !errors.Is(getErr, inmemory.ErrNotFound):
+		return "", getErr
+	}
+	entry.ID = s.seq + 1
+	if err := s.append(ctx, logEntry{Op: opCreate, Record: task, Outbox: &entry}); err != nil {
+		return "", err
+	}
+	s.seq = entry.ID
+	id, err := s.index.CreateTaskWithEvent(context.WithoutCancel(ctx), task, entry)
+	if err != nil {
+		return "", err
+	}
+	s.compact(ctx)
+	return id, nil
+}
+
+func (s *Storage) UpdateTaskWithEvent(ctx context.Context, from domain.Status, task domain.Record, entry domain.Outbox) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, err := s.index.GetTaskByID(ctx, task.ID)
+	if err != nil {
+		return err
+	}
+	if stored.Status != from {
+		return fmt.Errorf("%w: task %s is %s, not %s", inmemory.ErrConflict, task.ID, stored.Status, from)
+	}
+	entry.ID = s.seq + 1
+	if err := s.append(ctx, logEntry{Op: opUpdate, Record: task, Outbox: &entry}); err != nil {
+		return err
+	}
+	s.seq = entry.ID
+	if err := s.index.UpdateTaskWithEvent(context.WithoutCancel(ctx), from, task, entry); err != nil {
+		return err
+	}
+	s.compact(ctx)
+	return nil
+}
+
+func (s *Storage) DeleteTaskWithEvent(ctx context.Context, id domain.ID, entry domain.Outbox) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.index.GetTaskByID(ctx, id); err != nil {
+		return err
+	}
+	entry.ID = s.seq + 1
+	if err := s.append(ctx, logEntry{Op: opDelete, Record: domain.Record{ID: id}, Outbox: &entry}); err != nil {
+		return err
+	}
+	s.seq = entry.ID
+	if err := s.index.DeleteTaskWithEvent(context.WithoutCancel(ctx), id, entry); err != nil {
+		return err
+	}
+	s.compact(ctx)
+	return nil
+}
+
+func (s *Storage) PendingEvents(ctx context.Context, due int64, limit int) ([]domain.Outbox, error) {
+	return s.index.PendingEvents(ctx, due, limit)
+}
+
+func (s *Storage) UpdateEvent(ctx context.Context, entry domain.Outbox) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.index.GetEvent(ctx, entry.ID); err != nil {
+		return err
+	}
+	if err := s.append(ctx, logEntry{Op: opEvent, Outbox: &entry}); err != nil {
+		return err
+	}
+	if err := s.index.UpdateEvent(context.WithoutCancel(ctx), entry); err != nil {
+		return err
+	}
+	s.compact(ctx)
+	return nil
+}
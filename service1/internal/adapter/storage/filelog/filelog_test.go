@@ -0,0 +1,260 @@
+package filelog
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"service1/internal/adapter/storage/inmemory"
+	"service1/internal/domain"
+	"service1/internal/pkg/json/standartjson"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newConfig(t *testing.T, content string) Config {
+	path := filepath.Join(t.TempDir(), "tasks.log")
+	if content != "" {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	json := standartjson.New()
+	return Config{Path: path, Encoder: json, Decoder: json}
+}
+
+func Test_New_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		content string
+		result  []domain.Record
+		size    int64
+		err     error
+	}{
+		{
+			name:    "empty log",
+			content: "",
+			result:  []domain.Record{},
+			size:    0,
+			err:     nil,
+		},
+		{
+			name: "replays entries",
+			content: `{"op":"put","record":{"id":1,"title":"a","status":"new"}}` + "\n" +
+				`{"op":"put","record":{"id":1,"title":"a","status":"completed"}}` + "\n",
+			result: []domain.Record{{ID: "1", Title: "a", Status: domain.StatusCompleted}},
+			size:   122,
+			err:    nil,
+		},
+		{
+			name: "truncates torn tail",
+			content: `{"op":"put","record":{"id":1,"title":"a","status":"new"}}` + "\n" +
+				`{"op":"put","record":{"id":2,"ti`,
+			result: []domain.Record{{ID: "1", Title: "a", Status: domain.StatusNew}},
+			size:   58,
+			err:    nil,
+		},
+		{
+			name: "corrupted entry",
+			content: `{"op":"put","record":{"id":1,"ti` + "\n" +
+				`{"op":"put","record":{"id":2,"title":"b","status":"new"}}` + "\n",
+			result: nil,
+			err:    ErrCorrupted,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			c := newConfig(t, cs.content)
+			storage, err := New(c)
+			assert.ErrorIs(t, err, cs.err)
+			if err != nil {
+				return
+			}
+			defer storage.Close()
+			page, err := storage.GetTasks(context.Background(), domain.Query{})
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, cs.result, page.Records)
+			info, err := os.Stat(c.Path)
+			assert.NoError(t, err)
+			assert.Equal(t, cs.size, info.Size())
+		})
+	}
+}
+
+func Test_CreateTask_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name  string
+		ctx   context.Context
+		tasks []domain.Record
+		err   error
+	}{
+		{
+			name:  "success",
+			ctx:   context.Background(),
+			tasks: []domain.Record{{ID: "1"}},
+			err:   nil,
+		},
+		{
+			name:  "already exists",
+			ctx:   context.Background(),
+			tasks: []domain.Record{{ID: "1"}, {ID: "1"}},
+			err:   inmemory.ErrAlreadyExists,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			storage, err := New(newConfig(t, ""))
+			assert.NoError(t, err)
+			defer storage.Close()
+			for _, task := range cs.tasks {
+				_, err = storage.CreateTask(cs.ctx, task)
+			}
+			assert.ErrorIs(t, err, cs.err)
+		})
+	}
+}
+
+func Test_Recovery_Unit(t *testing.T) {
+	t.Parallel()
+	c := newConfig(t, "")
+	storage, err := New(c)
+	require.NoError(t, err)
+	_, err = storage.CreateTask(context.Background(), domain.Record{ID: "1", Status: domain.StatusNew})
+	require.NoError(t, err)
+	err = storage.UpdateOrCreateTask(context.Background(), domain.Record{ID: "1", Status: domain.StatusPending})
+	require.NoError(t, err)
+	_, err = storage.TransitionTask(context.Background(), "1", domain.StatusPending, domain.StatusProcessing)
+	require.NoError(t, err)
+	_, err = storage.TransitionTask(context.Background(), "1", domain.StatusPending, domain.StatusFailed)
+	assert.ErrorIs(t, err, inmemory.ErrConflict)
+	_, err = storage.TransitionTask(context.Background(), "2", domain.StatusNew, domain.StatusPending)
+	assert.ErrorIs(t, err, inmemory.ErrNotFound)
+	require.NoError(t, storage.Close())
+
+	reopened, err := New(c)
+	require.NoError(t, err)
+	defer reopened.Close()
+	record, err := reopened.GetTaskByID(context.Background(), "1")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.StatusProcessing, record.Status)
+}
+
+func Test_OutboxRecovery_Unit(t *testing.T) {
+	t.Parallel()
+	c := newConfig(t, "")
+	storage, err := New(c)
+	require.NoError(t, err)
+	_, err = storage.CreateTaskWithEvent(context.Background(), domain.Record{ID: "1"}, domain.Outbox{State: domain.OutboxPending})
+	require.NoError(t, err)
+	_, err = storage.CreateTaskWithEvent(context.Background(), domain.Record{ID: "2"}, domain.Outbox{State: domain.OutboxPending})
+	require.NoError(t, err)
+	err = storage.UpdateEvent(context.Background(), domain.Outbox{ID: 1, State: domain.OutboxDelivered})
+	require.NoError(t, err)
+	require.NoError(t, storage.Close())
+
+	reopened, err := New(c)
+	require.NoError(t, err)
+	defer reopened.Close()
+	pending, err := reopened.PendingEvents(context.Background(), 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, 2, pending[0].ID)
+	_, err = reopened.CreateTaskWithEvent(context.Background(), domain.Record{ID: "3"}, domain.Outbox{State: domain.OutboxPending})
+	assert.NoError(t, err)
+	pending, err = reopened.PendingEvents(context.Background(), 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, pending[1].ID)
+}
+
+func Test_DeleteRecovery_Unit(t *testing.T) {
+	t.Parallel()
+	c := newConfig(t, "")
+	storage, err := New(c)
+	require.NoError(t, err)
+	_, err = storage.CreateTaskWithEvent(context.Background(), domain.Record{ID: "1", Title: "a"}, domain.Outbox{State: domain.OutboxPending})
+	require.NoError(t, err)
+	_, err = storage.CreateTaskWithEvent(context.Background(), domain.Record{ID: "2", Title: "b"}, domain.Outbox{State: domain.OutboxPending})
+	require.NoError(t, err)
+	err = storage.UpdateTaskWithEvent(context.Background(), "", domain.Record{ID: "1", Title: "c"}, domain.Outbox{State: domain.OutboxPending})
+	require.NoError(t, err)
+	err = storage.DeleteTaskWithEvent(context.Background(), "2", domain.Outbox{State: domain.OutboxPending})
+	require.NoError(t, err)
+	err = storage.DeleteTaskWithEvent(context.Background(), "2", domain.Outbox{State: domain.OutboxPending})
+	assert.ErrorIs(t, err, inmemory.ErrNotFound)
+	require.NoError(t, storage.Close())
+
+	reopened, err := New(c)
+	require.NoError(t, err)
+	defer reopened.Close()
+	page, err := reopened.GetTasks(context.Background(), domain.Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Record{{ID: "1", Title: "c"}}, page.Records)
+	pending, err := reopened.PendingEvents(context.Background(), 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 4)
+}
+
+func Test_UpdateEvent_Unit(t *testing.T) {
+	t.Parallel()
+	c := newConfig(t, "")
+	storage, err := New(c)
+	require.NoError(t, err)
+	defer storage.Close()
+	_, err = storage.CreateTaskWithEvent(context.Background(), domain.Record{ID: "1"}, domain.Outbox{State: domain.OutboxPending})
+	require.NoError(t, err)
+	before, err := os.Stat(c.Path)
+	require.NoError(t, err)
+
+	err = storage.UpdateEvent(context.Background(), domain.Outbox{ID: 2, State: domain.OutboxDelivered})
+	assert.ErrorIs(t, err, inmemory.ErrNotFound)
+	after, err := os.Stat(c.Path)
+	require.NoError(t, err)
+	assert.Equal(t, before.Size(), after.Size())
+}
+
+func Test_Compact_Unit(t *testing.T) {
+	t.Parallel()
+	c := newConfig(t, "")
+	c.CompactSize = 1024
+	c.Logger = slog.New(slog.DiscardHandler)
+	storage, err := New(c)
+	require.NoError(t, err)
+	_, err = storage.CreateTaskWithEvent(context.Background(), domain.Record{ID: "1", Title: "a"}, domain.Outbox{State: domain.OutboxPending})
+	require.NoError(t, err)
+	_, err = storage.CreateTaskWithEvent(context.Background(), domain.Record{ID: "2", Title: "b"}, domain.Outbox{State: domain.OutboxPending})
+	require.NoError(t, err)
+	for attempt := range 20 {
+		err = storage.UpdateEvent(context.Background(), domain.Outbox{ID: 1, State: domain.OutboxPending, Attempts: attempt + 1})
+		require.NoError(t, err)
+	}
+	require.NoError(t, storage.UpdateEvent(context.Background(), domain.Outbox{ID: 2, State: domain.OutboxDelivered}))
+	require.NoError(t, storage.Close())
+
+	content, err := os.ReadFile(c.Path)
+	require.NoError(t, err)
+	assert.Less(t, len(content), 2048)
+	assert.Less(t, bytes.Count(content, []byte("\n")), 10)
+	_, err = os.Stat(c.Path + ".compact")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	reopened, err := New(c)
+	require.NoError(t, err)
+	defer reopened.Close()
+	page, err := reopened.GetTasks(context.Background(), domain.Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Record{{ID: "1", Title: "a"}, {ID: "2", Title: "b"}}, page.Records)
+	pending, err := reopened.PendingEvents(context.Background(), 0, 0)
+	assert.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 1, pending[0].ID)
+	assert.Equal(t, 20, pending[0].Attempts)
+	_, err = reopened.CreateTaskWithEvent(context.Background(), domain.Record{ID: "3"}, domain.Outbox{State: domain.OutboxPending})
+	assert.NoError(t, err)
+	pending, err = reopened.PendingEvents(context.Background(), 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, pending[1].ID)
+}
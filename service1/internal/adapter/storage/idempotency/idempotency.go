@@ -0,0 +1,119 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"service1/internal/domain"
+)
+
+var (
+	ErrInProgress = errors.New("idempotency: request with this key is still in progress")
+	ErrMismatch   = errors.New("idempotency: key was used with a different request")
+	ErrNotFound   = errors.New("idempotency: no reservation for key")
+)
+
+type Timer interface {
+	TimeNow() int64
+}
+
+type entry struct {
+	replay    domain.Replay
+	done      chan struct{}
+	completed bool
+	expiresAt int64
+}
+
+type Store struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+	swept   int64
+
+	timer Timer
+}
+
+func New(timer Timer) *Store {
+	return &Store{
+		entries: make(map[string]*entry),
+		timer:   timer,
+	}
+}
+
+func (s *Store) Acquire(ctx context.Context, key string, fingerprint string) (domain.Replay, bool, error) {
+	for {
+		s.mu.Lock()
+		now := s.timer.TimeNow()
+		s.sweep(now)
+		e, ok := s.entries[key]
+		if !ok || s.expired(e, now) {
+			s.entries[key] = &entry{
+				replay: domain.Replay{Fingerprint: fingerprint},
+				done:   make(chan struct{}),
+			}
+			s.mu.Unlock()
+			return domain.Replay{}, false, nil
+		}
+		if e.replay.Fingerprint != fingerprint {
+			s.mu.Unlock()
+			return domain.Replay{}, false, fmt.Errorf("%w: %q", ErrMismatch, key)
+		}
+		select {
+		case <-e.done:
+			s.mu.Unlock()
+			return e.replay, true, nil
+		default:
+		}
+		s.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return domain.Replay{}, false, fmt.Errorf("%w: %q: %v", ErrInProgress, key, ctx.Err())
+		case <-e.done:
+		}
+	}
+}
+
+func (s *Store) Complete(ctx context.Context, key string, replay domain.Replay, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok || e.completed {
+		return fmt.Errorf("%w: %q", ErrNotFound, key)
+	}
+	replay.Fingerprint = e.replay.Fingerprint
+	e.replay = replay
+	e.completed = true
+	e.expiresAt = s.timer.TimeNow() + int64(ttl.Seconds())
+	close(e.done)
+	return nil
+}
+
+func (s *Store) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok || e.completed {
+		return fmt.Errorf("%w: %q", ErrNotFound, key)
+	}
+	delete(s.entries, key)
+	close(e.done)
+	return nil
+}
+
+func (s *Store) expired(e *entry, now int64) bool {
+	return e.completed && e.expiresAt <= now
+}
+
+func (s *Store) sweep(now int64) {
+	if now == s.swept {
+		return
+	}
+	s.swept = now
+	for key, e := range s.entries {
+		if s.expired(e, now) {
+			delete(s.entries, key)
+		}
+	}
+}
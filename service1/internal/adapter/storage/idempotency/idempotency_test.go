@@ -0,0 +1,117 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"service1/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockTimer struct {
+	time int64
+}
+
+func (m *mockTimer) TimeNow() int64 {
+	return m.time
+}
+
+func Test_Acquire_Unit(t *testing.T) {
+	t.Parallel()
+	stored := domain.Replay{Fingerprint: "a", Code: 200, Body: []byte("1")}
+	cases := []struct {
+		name        string
+		fingerprint string
+		complete    bool
+		now         int64
+		result      domain.Replay
+		found       bool
+		err         error
+	}{
+		{
+			name:        "replayed",
+			fingerprint: "a",
+			complete:    true,
+			now:         1,
+			result:      stored,
+			found:       true,
+			err:         nil,
+		},
+		{
+			name:        "different request",
+			fingerprint: "b",
+			complete:    true,
+			now:         1,
+			result:      domain.Replay{},
+			found:       false,
+			err:         ErrMismatch,
+		},
+		{
+			name:        "expired",
+			fingerprint: "b",
+			complete:    true,
+			now:         61,
+			result:      domain.Replay{},
+			found:       false,
+			err:         nil,
+		},
+		{
+			name:        "still in progress",
+			fingerprint: "a",
+			complete:    false,
+			now:         1,
+			result:      domain.Replay{},
+			found:       false,
+			err:         ErrInProgress,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			timer := &mockTimer{time: 1}
+			store := New(timer)
+			_, found, err := store.Acquire(context.Background(), "key", "a")
+			require.NoError(t, err)
+			require.False(t, found)
+			if cs.complete {
+				require.NoError(t, store.Complete(context.Background(), "key", stored, time.Minute))
+			}
+			timer.time = cs.now
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+			defer cancel()
+			replay, found, err := store.Acquire(ctx, "key", cs.fingerprint)
+			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.found, found)
+			assert.Equal(t, cs.result, replay)
+		})
+	}
+}
+
+func Test_AcquireWaits_Unit(t *testing.T) {
+	t.Parallel()
+	store := New(&mockTimer{time: 1})
+	_, _, err := store.Acquire(context.Background(), "key", "a")
+	require.NoError(t, err)
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		store.Complete(context.Background(), "key", domain.Replay{Code: 200}, time.Minute)
+	}()
+	replay, found, err := store.Acquire(context.Background(), "key", "a")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 200, replay.Code)
+}
+
+func Test_Release_Unit(t *testing.T) {
+	t.Parallel()
+	store := New(&mockTimer{time: 1})
+	assert.ErrorIs(t, store.Release(context.Background(), "key"), ErrNotFound)
+	_, _, err := store.Acquire(context.Background(), "key", "a")
+	require.NoError(t, err)
+	assert.NoError(t, store.Release(context.Background(), "key"))
+	_, found, err := store.Acquire(context.Background(), "key", "b")
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
@@ -1,9 +1,16 @@
 package inmemory
 
 import (
+	"cmp"
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"service1/internal/domain"
 	inmemory "service1/pkg/in_memory"
@@ -15,45 +22,90 @@ var (
 	ErrExecuting         = errors.New("inmemory: failed to execute")
 	ErrIncompatible      = errors.New("inmemory: data incompatible: memory stores different type")
 	ErrNotFound          = errors.New("inmemory: no records found")
+	ErrMalformedCursor   = errors.New("inmemory: malformed cursor")
+	ErrClosed            = errors.New("inmemory: storage closed")
+	ErrConflict          = errors.New("inmemory: record changed concurrently")
 )
 
+// ConflictAttempts bounds how often Retry re-runs a read-modify-write that
+// lost a race with another writer.
+const ConflictAttempts = 3
+
+// Retry runs write, which should re-read whatever it is about to change,
+// until it returns anything but ErrConflict or has lost ConflictAttempts
+// races in a row; the last error is returned as is.
+func Retry(write func() error) error {
+	var err error
+	for range ConflictAttempts {
+		if err = write(); !errors.Is(err, ErrConflict) {
+			return err
+		}
+	}
+	return err
+}
+
 type Keeper interface {
 	AllContext(ctx context.Context) ([]any, error)
 	Close()
 	CreateContext(ctx context.Context, key any, value any) error
+	DeleteContext(ctx context.Context, key any) error
 	LoadContext(ctx context.Context, key any) (any, error)
 	UpdateOrCreateContext(ctx context.Context, key any, value any) error
 }
 
 type Storage struct {
-	store Keeper
+	mu  sync.Mutex
+	seq int
+
+	store  Keeper
+	outbox Keeper
+
+	closed atomic.Bool
 }
 
 func New() *Storage {
 	return &Storage{
-		store: inmemory.New(),
+		store:  inmemory.New(),
+		outbox: inmemory.New(),
 	}
 }
 
-func (s *Storage) Close() {
+func (s *Storage) Close() error {
+	s.closed.Store(true)
 	s.store.Close()
+	s.outbox.Close()
+	return nil
 }
 
-func (s *Storage) CreateTask(ctx context.Context, task domain.Record) (int, error) {
+func (s *Storage) Ping(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
+	default:
+	}
+	if s.closed.Load() {
+		return fmt.Errorf("%w", ErrClosed)
+	}
+	return nil
+}
+
+func (s *Storage) CreateTask(ctx context.Context, task domain.Record) (domain.ID, error) {
 	if err := s.store.CreateContext(ctx, task.ID, task); err != nil {
 		switch {
 		case errors.Is(err, inmemory.ErrOperationCanceled):
-			return 0, fmt.Errorf("%w: %v", ErrOperationCanceled, err)
+			return "", fmt.Errorf("%w: %v", ErrOperationCanceled, err)
 		case errors.Is(err, inmemory.ErrAlreadyExists):
-			return 0, fmt.Errorf("%w: %v", ErrAlreadyExists, err)
+			return "", fmt.Errorf("%w: %v", ErrAlreadyExists, err)
 		default:
-			return 0, fmt.Errorf("%w: %v", ErrExecuting, err)
+			return "", fmt.Errorf("%w: %v", ErrExecuting, err)
 		}
 	}
 	return task.ID, nil
 }
 
 func (s *Storage) UpdateOrCreateTask(ctx context.Context, task domain.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if err := s.store.UpdateOrCreateContext(ctx, task.ID, task); err != nil {
 		switch {
 		case errors.Is(err, inmemory.ErrOperationCanceled):
@@ -65,7 +117,26 @@ func (s *Storage) UpdateOrCreateTask(ctx context.Context, task domain.Record) er
 	return nil
 }
 
-func (s *Storage) GetTaskByID(ctx context.Context, id int) (domain.Record, error) {
+// TransitionTask moves a task from one status to another, failing with
+// ErrConflict if the stored status is no longer from.
+func (s *Storage) TransitionTask(ctx context.Context, id domain.ID, from domain.Status, to domain.Status) (domain.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, err := s.GetTaskByID(ctx, id)
+	if err != nil {
+		return domain.Record{}, err
+	}
+	if task.Status != from {
+		return domain.Record{}, fmt.Errorf("%w: task %s is %s, not %s", ErrConflict, id, task.Status, from)
+	}
+	task.Status = to
+	if err := s.store.UpdateOrCreateContext(context.WithoutCancel(ctx), id, task); err != nil {
+		return domain.Record{}, fmt.Errorf("%w: %v", ErrExecuting, err)
+	}
+	return task, nil
+}
+
+func (s *Storage) GetTaskByID(ctx context.Context, id domain.ID) (domain.Record, error) {
 	record, err := s.store.LoadContext(ctx, id)
 	if err != nil {
 		switch {
@@ -84,16 +155,20 @@ func (s *Storage) GetTaskByID(ctx context.Context, id int) (domain.Record, error
 	return task, nil
 }
 
-func (s *Storage) GetTasks(ctx context.Context) ([]domain.Record, error) {
+func (s *Storage) GetTasks(ctx context.Context, query domain.Query) (domain.Page, error) {
 	records, err := s.store.AllContext(ctx)
 	if err != nil {
 		switch {
 		case errors.Is(err, inmemory.ErrOperationCanceled):
-			return nil, fmt.Errorf("%w: %v", ErrOperationCanceled, err)
+			return domain.Page{}, fmt.Errorf("%w: %v", ErrOperationCanceled, err)
 		default:
-			return nil, fmt.Errorf("%w: %v", ErrExecuting, err)
+			return domain.Page{}, fmt.Errorf("%w: %v", ErrExecuting, err)
 		}
 	}
+	after, cErr := decodeCursor(query)
+	if cErr != nil {
+		return domain.Page{}, cErr
+	}
 	var counter uint64
 	tasks := make([]domain.Record, 0, len(records))
 	for _, record := range records {
@@ -101,15 +176,311 @@ func (s *Storage) GetTasks(ctx context.Context) ([]domain.Record, error) {
 		if counter%50 == 0 {
 			select {
 			case <-ctx.Done():
-				return nil, fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
+				return domain.Page{}, fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
 			default:
 			}
 		}
 		task, ok := record.(domain.Record)
 		if !ok {
-			return nil, fmt.Errorf("%w", ErrIncompatible)
+			return domain.Page{}, fmt.Errorf("%w", ErrIncompatible)
+		}
+		if !matches(task, query) {
+			continue
+		}
+		if after != nil && compare(task, *after, query) <= 0 {
+			continue
 		}
 		tasks = append(tasks, task)
 	}
-	return tasks, nil
+	slices.SortFunc(tasks, func(a, b domain.Record) int {
+		return compare(a, b, query)
+	})
+	page := domain.Page{Records: tasks}
+	if query.Limit > 0 && len(tasks) > query.Limit {
+		page.Records = tasks[:query.Limit]
+		page.NextCursor = encodeCursor(page.Records[query.Limit-1], query)
+	}
+	return page, nil
+}
+
+func (s *Storage) CountTasks(ctx context.Context) (map[domain.Status]int, error) {
+	records, err := s.store.AllContext(ctx)
+	if err != nil {
+		switch {
+		case errors.Is(err, inmemory.ErrOperationCanceled):
+			return nil, fmt.Errorf("%w: %v", ErrOperationCanceled, err)
+		default:
+			return nil, fmt.Errorf("%w: %v", ErrExecuting, err)
+		}
+	}
+	counts := make(map[domain.Status]int)
+	for _, record := range records {
+		task, ok := record.(domain.Record)
+		if !ok {
+			return nil, fmt.Errorf("%w", ErrIncompatible)
+		}
+		counts[task.Status]++
+	}
+	return counts, nil
+}
+
+func matches(task domain.Record, query domain.Query) bool {
+	if query.Status != "" && task.Status != query.Status {
+		return false
+	}
+	if query.CreatedFrom != 0 && task.CreatedAt < query.CreatedFrom {
+		return false
+	}
+	if query.CreatedTo != 0 && task.CreatedAt > query.CreatedTo {
+		return false
+	}
+	if query.Owner != "" && task.Owner != query.Owner {
+		return false
+	}
+	if query.Search != "" && !strings.Contains(strings.ToLower(task.Title), strings.ToLower(query.Search)) {
+		return false
+	}
+	return true
+}
+
+func compare(a, b domain.Record, query domain.Query) int {
+	var c int
+	switch query.Sort {
+	case domain.SortByCreatedAt:
+		c = cmp.Compare(a.CreatedAt, b.CreatedAt)
+	case domain.SortByTitle:
+		c = strings.Compare(a.Title, b.Title)
+	}
+	if c == 0 {
+		c = a.ID.Compare(b.ID)
+	}
+	if query.Order == domain.OrderDesc {
+		return -c
+	}
+	return c
+}
+
+func encodeCursor(last domain.Record, query domain.Query) string {
+	var key string
+	switch query.Sort {
+	case domain.SortByCreatedAt:
+		key = strconv.FormatInt(last.CreatedAt, 10)
+	case domain.SortByTitle:
+		key = last.Title
+	}
+	raw := fmt.Sprintf("%s:%s:%s", query.Sort, last.ID, key)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(query domain.Query) (*domain.Record, error) {
+	if query.Cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedCursor, err)
+	}
+	parts := strings.SplitN(string(raw), ":", 3)
+	if len(parts) != 3 || domain.SortField(parts[0]) != query.Sort {
+		return nil, fmt.Errorf("%w: cursor doesn't match sort order", ErrMalformedCursor)
+	}
+	id := domain.ID(parts[1])
+	if !id.Valid() {
+		return nil, fmt.Errorf("%w: malformed id %q", ErrMalformedCursor, id)
+	}
+	last := domain.Record{ID: id}
+	switch query.Sort {
+	case domain.SortByCreatedAt:
+		createdAt, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformedCursor, err)
+		}
+		last.CreatedAt = createdAt
+	case domain.SortByTitle:
+		last.Title = parts[2]
+	}
+	return &last, nil
+}
+
+func (s *Storage) CreateTaskWithEvent(ctx context.Context, task domain.Record, entry domain.Outbox) (domain.ID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry.ID == 0 {
+		entry.ID = s.seq + 1
+	}
+	if err := s.store.CreateContext(ctx, task.ID, task); err != nil {
+		switch {
+		case errors.Is(err, inmemory.ErrOperationCanceled):
+			return "", fmt.Errorf("%w: %v", ErrOperationCanceled, err)
+		case errors.Is(err, inmemory.ErrAlreadyExists):
+			return "", fmt.Errorf("%w: %v", ErrAlreadyExists, err)
+		default:
+			return "", fmt.Errorf("%w: %v", ErrExecuting, err)
+		}
+	}
+	if err := s.outbox.CreateContext(ctx, entry.ID, entry); err != nil {
+		s.store.DeleteContext(context.WithoutCancel(ctx), task.ID)
+		switch {
+		case errors.Is(err, inmemory.ErrOperationCanceled):
+			return "", fmt.Errorf("%w: %v", ErrOperationCanceled, err)
+		default:
+			return "", fmt.Errorf("%w: %v", ErrExecuting, err)
+		}
+	}
+	s.seq = max(s.seq, entry.ID)
+	return task.ID, nil
+}
+
+// UpdateTaskWithEvent replaces a task whose stored status is still from,
+// failing with ErrConflict otherwise.
+func (s *Storage) UpdateTaskWithEvent(ctx context.Context, from domain.Status, task domain.Record, entry domain.Outbox) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry.ID == 0 {
+		entry.ID = s.seq + 1
+	}
+	stored, err := s.GetTaskByID(ctx, task.ID)
+	if err != nil {
+		return err
+	}
+	if stored.Status != from {
+		return fmt.Errorf("%w: task %s is %s, not %s", ErrConflict, task.ID, stored.Status, from)
+	}
+	ctx = context.WithoutCancel(ctx)
+	if err := s.store.UpdateOrCreateContext(ctx, task.ID, task); err != nil {
+		return fmt.Errorf("%w: %v", ErrExecuting, err)
+	}
+	if err := s.outbox.CreateContext(ctx, entry.ID, entry); err != nil {
+		return fmt.Errorf("%w: %v", ErrExecuting, err)
+	}
+	s.seq = max(s.seq, entry.ID)
+	return nil
+}
+
+func (s *Storage) DeleteTaskWithEvent(ctx context.Context, id domain.ID, entry domain.Outbox) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry.ID == 0 {
+		entry.ID = s.seq + 1
+	}
+	if err := s.store.DeleteContext(ctx, id); err != nil {
+		switch {
+		case errors.Is(err, inmemory.ErrOperationCanceled):
+			return fmt.Errorf("%w: %v", ErrOperationCanceled, err)
+		case errors.Is(err, inmemory.ErrNotFound):
+			return fmt.Errorf("%w: %v", ErrNotFound, err)
+		default:
+			return fmt.Errorf("%w: %v", ErrExecuting, err)
+		}
+	}
+	if err := s.outbox.CreateContext(context.WithoutCancel(ctx), entry.ID, entry); err != nil {
+		return fmt.Errorf("%w: %v", ErrExecuting, err)
+	}
+	s.seq = max(s.seq, entry.ID)
+	return nil
+}
+
+// PendingEvents returns up to limit pending entries whose RetryAt is at or
+// before due, ordered by ID.
+func (s *Storage) PendingEvents(ctx context.Context, due int64, limit int) ([]domain.Outbox, error) {
+	entries, err := s.outbox.AllContext(ctx)
+	if err != nil {
+		switch {
+		case errors.Is(err, inmemory.ErrOperationCanceled):
+			return nil, fmt.Errorf("%w: %v", ErrOperationCanceled, err)
+		default:
+			return nil, fmt.Errorf("%w: %v", ErrExecuting, err)
+		}
+	}
+	pending := make([]domain.Outbox, 0, len(entries))
+	for _, e := range entries {
+		entry, ok := e.(domain.Outbox)
+		if !ok {
+			return nil, fmt.Errorf("%w", ErrIncompatible)
+		}
+		if entry.State == domain.OutboxPending && entry.RetryAt <= due {
+			pending = append(pending, entry)
+		}
+	}
+	slices.SortFunc(pending, func(a, b domain.Outbox) int {
+		return a.ID - b.ID
+	})
+	if limit > 0 && len(pending) > limit {
+		pending = pending[:limit]
+	}
+	return pending, nil
+}
+
+func (s *Storage) GetEvent(ctx context.Context, id int) (domain.Outbox, error) {
+	e, err := s.outbox.LoadContext(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, inmemory.ErrOperationCanceled):
+			return domain.Outbox{}, fmt.Errorf("%w: %v", ErrOperationCanceled, err)
+		case errors.Is(err, inmemory.ErrNotFound):
+			return domain.Outbox{}, fmt.Errorf("%w: %v", ErrNotFound, err)
+		default:
+			return domain.Outbox{}, fmt.Errorf("%w: %v", ErrExecuting, err)
+		}
+	}
+	entry, ok := e.(domain.Outbox)
+	if !ok {
+		return domain.Outbox{}, fmt.Errorf("%w", ErrIncompatible)
+	}
+	return entry, nil
+}
+
+// Events returns every stored outbox entry ordered by ID, whatever its state.
+func (s *Storage) Events(ctx context.Context) ([]domain.Outbox, error) {
+	entries, err := s.outbox.AllContext(ctx)
+	if err != nil {
+		switch {
+		case errors.Is(err, inmemory.ErrOperationCanceled):
+			return nil, fmt.Errorf("%w: %v", ErrOperationCanceled, err)
+		default:
+			return nil, fmt.Errorf("%w: %v", ErrExecuting, err)
+		}
+	}
+	events := make([]domain.Outbox, 0, len(entries))
+	for _, e := range entries {
+		entry, ok := e.(domain.Outbox)
+		if !ok {
+			return nil, fmt.Errorf("%w", ErrIncompatible)
+		}
+		events = append(events, entry)
+	}
+	slices.SortFunc(events, func(a, b domain.Outbox) int {
+		return a.ID - b.ID
+	})
+	return events, nil
+}
+
+// RestoreEvent stores entry as is, without touching its task.
+func (s *Storage) RestoreEvent(ctx context.Context, entry domain.Outbox) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.outbox.UpdateOrCreateContext(ctx, entry.ID, entry); err != nil {
+		switch {
+		case errors.Is(err, inmemory.ErrOperationCanceled):
+			return fmt.Errorf("%w: %v", ErrOperationCanceled, err)
+		default:
+			return fmt.Errorf("%w: %v", ErrExecuting, err)
+		}
+	}
+	s.seq = max(s.seq, entry.ID)
+	return nil
+}
+
+// UpdateEvent replaces a stored entry. Delivered and dead entries are kept so
+// they can be inspected; PendingEvents leaves them out.
+func (s *Storage) UpdateEvent(ctx context.Context, entry domain.Outbox) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.GetEvent(ctx, entry.ID); err != nil {
+		return err
+	}
+	if err := s.outbox.UpdateOrCreateContext(context.WithoutCancel(ctx), entry.ID, entry); err != nil {
+		return fmt.Errorf("%w: %v", ErrExecuting, err)
+	}
+	return nil
 }
@@ -9,12 +9,14 @@ import (
 	inmemory "service1/pkg/in_memory"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type mockKeeper struct {
 	ac   mockAllContext
 	c    mockClose
 	cc   mockCreateContext
+	dc   mockDeleteContext
 	lc   mockLoadContext
 	uocc mockUpdateOrCreateContext
 }
@@ -30,6 +32,10 @@ type mockCreateContext struct {
 	err error
 }
 
+type mockDeleteContext struct {
+	err error
+}
+
 type mockLoadContext struct {
 	record any
 	err    error
@@ -49,6 +55,10 @@ func (m *mockKeeper) CreateContext(ctx context.Context, key any, value any) erro
 	return m.cc.err
 }
 
+func (m *mockKeeper) DeleteContext(ctx context.Context, key any) error {
+	return m.dc.err
+}
+
 func (m *mockKeeper) LoadContext(ctx context.Context, key any) (any, error) {
 	return m.lc.record, m.lc.err
 }
@@ -64,55 +74,55 @@ func Test_CreateTask_Unit(t *testing.T) {
 		ctx     context.Context
 		task    domain.Record
 		storage *Storage
-		result  int
+		result  domain.ID
 		err     error
 	}{
 		{
 			name: "success",
 			ctx:  context.Background(),
 			task: domain.Record{
-				ID: 0,
+				ID: "0",
 			},
 			storage: &Storage{store: &mockKeeper{
 				cc: mockCreateContext{},
 			}},
-			result: 0,
+			result: "0",
 			err:    nil,
 		},
 		{
 			name: "already exists",
 			ctx:  context.Background(),
 			task: domain.Record{
-				ID: 0,
+				ID: "0",
 			},
 			storage: &Storage{store: &mockKeeper{
 				cc: mockCreateContext{err: inmemory.ErrAlreadyExists},
 			}},
-			result: 0,
+			result: "",
 			err:    ErrAlreadyExists,
 		},
 		{
 			name: "database failure",
 			ctx:  context.Background(),
 			task: domain.Record{
-				ID: 0,
+				ID: "0",
 			},
 			storage: &Storage{store: &mockKeeper{
 				cc: mockCreateContext{err: errors.New("")},
 			}},
-			result: 0,
+			result: "",
 			err:    ErrExecuting,
 		},
 		{
 			name: "context closed mid databse call",
 			ctx:  context.Background(),
 			task: domain.Record{
-				ID: 0,
+				ID: "0",
 			},
 			storage: &Storage{store: &mockKeeper{
 				cc: mockCreateContext{err: inmemory.ErrOperationCanceled},
 			}},
-			result: 0,
+			result: "",
 			err:    ErrOperationCanceled,
 		},
 	}
@@ -138,7 +148,7 @@ func Test_UpdateOrCreateTask_Unit(t *testing.T) {
 			name: "success",
 			ctx:  context.Background(),
 			task: domain.Record{
-				ID: 0,
+				ID: "0",
 			},
 			storage: &Storage{store: &mockKeeper{
 				uocc: mockUpdateOrCreateContext{},
@@ -149,7 +159,7 @@ func Test_UpdateOrCreateTask_Unit(t *testing.T) {
 			name: "database failure",
 			ctx:  context.Background(),
 			task: domain.Record{
-				ID: 0,
+				ID: "0",
 			},
 			storage: &Storage{store: &mockKeeper{
 				uocc: mockUpdateOrCreateContext{err: errors.New("")},
@@ -160,7 +170,7 @@ func Test_UpdateOrCreateTask_Unit(t *testing.T) {
 			name: "context closed mid databse call",
 			ctx:  context.Background(),
 			task: domain.Record{
-				ID: 0,
+				ID: "0",
 			},
 			storage: &Storage{store: &mockKeeper{
 				uocc: mockUpdateOrCreateContext{err: inmemory.ErrOperationCanceled},
@@ -176,12 +186,78 @@ func Test_UpdateOrCreateTask_Unit(t *testing.T) {
 	}
 }
 
+func Test_TransitionTask_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		from    domain.Status
+		to      domain.Status
+		storage *Storage
+		result  domain.Record
+		err     error
+	}{
+		{
+			name: "success",
+			ctx:  context.Background(),
+			from: domain.StatusNew,
+			to:   domain.StatusPending,
+			storage: &Storage{store: &mockKeeper{
+				lc: mockLoadContext{record: domain.Record{ID: "1", Status: domain.StatusNew}},
+			}},
+			result: domain.Record{ID: "1", Status: domain.StatusPending},
+			err:    nil,
+		},
+		{
+			name: "status changed",
+			ctx:  context.Background(),
+			from: domain.StatusNew,
+			to:   domain.StatusPending,
+			storage: &Storage{store: &mockKeeper{
+				lc: mockLoadContext{record: domain.Record{ID: "1", Status: domain.StatusProcessing}},
+			}},
+			result: domain.Record{},
+			err:    ErrConflict,
+		},
+		{
+			name: "not found",
+			ctx:  context.Background(),
+			from: domain.StatusNew,
+			to:   domain.StatusPending,
+			storage: &Storage{store: &mockKeeper{
+				lc: mockLoadContext{err: inmemory.ErrNotFound},
+			}},
+			result: domain.Record{},
+			err:    ErrNotFound,
+		},
+		{
+			name: "database failure",
+			ctx:  context.Background(),
+			from: domain.StatusNew,
+			to:   domain.StatusPending,
+			storage: &Storage{store: &mockKeeper{
+				lc:   mockLoadContext{record: domain.Record{ID: "1", Status: domain.StatusNew}},
+				uocc: mockUpdateOrCreateContext{err: errors.New("")},
+			}},
+			result: domain.Record{},
+			err:    ErrExecuting,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			result, err := cs.storage.TransitionTask(cs.ctx, "1", cs.from, cs.to)
+			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.result, result)
+		})
+	}
+}
+
 func Test_GetTaskByID_Unit(t *testing.T) {
 	t.Parallel()
 	cases := []struct {
 		name    string
 		ctx     context.Context
-		id      int
+		id      domain.ID
 		storage *Storage
 		result  domain.Record
 		err     error
@@ -189,7 +265,7 @@ func Test_GetTaskByID_Unit(t *testing.T) {
 		{
 			name: "success",
 			ctx:  context.Background(),
-			id:   0,
+			id:   "0",
 			storage: &Storage{store: &mockKeeper{
 				lc: mockLoadContext{record: any(domain.Record{})},
 			}},
@@ -199,7 +275,7 @@ func Test_GetTaskByID_Unit(t *testing.T) {
 		{
 			name: "not found",
 			ctx:  context.Background(),
-			id:   0,
+			id:   "0",
 			storage: &Storage{store: &mockKeeper{
 				lc: mockLoadContext{err: inmemory.ErrNotFound},
 			}},
@@ -209,7 +285,7 @@ func Test_GetTaskByID_Unit(t *testing.T) {
 		{
 			name: "database failure",
 			ctx:  context.Background(),
-			id:   0,
+			id:   "0",
 			storage: &Storage{store: &mockKeeper{
 				lc: mockLoadContext{err: errors.New("")},
 			}},
@@ -219,7 +295,7 @@ func Test_GetTaskByID_Unit(t *testing.T) {
 		{
 			name: "incompatible data",
 			ctx:  context.Background(),
-			id:   0,
+			id:   "0",
 			storage: &Storage{store: &mockKeeper{
 				lc: mockLoadContext{},
 			}},
@@ -229,7 +305,7 @@ func Test_GetTaskByID_Unit(t *testing.T) {
 		{
 			name: "context closed mid databse call",
 			ctx:  context.Background(),
-			id:   0,
+			id:   "0",
 			storage: &Storage{store: &mockKeeper{
 				lc: mockLoadContext{err: inmemory.ErrOperationCanceled},
 			}},
@@ -297,13 +373,558 @@ func Test_GetTasks_Unit(t *testing.T) {
 	}
 	for _, cs := range cases {
 		t.Run(cs.name, func(t *testing.T) {
-			records, err := cs.storage.GetTasks(cs.ctx)
+			page, err := cs.storage.GetTasks(cs.ctx, domain.Query{})
 			assert.ErrorIs(t, err, cs.err)
 			if cs.result != nil {
-				assert.NotNil(t, records)
+				assert.NotNil(t, page.Records)
 			} else {
-				assert.Nil(t, records)
+				assert.Nil(t, page.Records)
 			}
 		})
 	}
 }
+
+func Test_GetTasksQuery_Unit(t *testing.T) {
+	t.Parallel()
+	storage := New()
+	defer storage.Close()
+	records := []domain.Record{
+		{ID: "1", Title: "Buy milk", CreatedAt: 30, Status: domain.StatusNew, Owner: "alice"},
+		{ID: "2", Title: "Walk dog", CreatedAt: 10, Status: domain.StatusCompleted, Owner: "bob"},
+		{ID: "3", Title: "buy bread", CreatedAt: 20, Status: domain.StatusNew, Owner: "alice"},
+		{ID: "4", Title: "Call mom", CreatedAt: 20, Status: domain.StatusFailed},
+	}
+	for _, record := range records {
+		_, err := storage.CreateTask(context.Background(), record)
+		assert.NoError(t, err)
+	}
+	cases := []struct {
+		name   string
+		query  domain.Query
+		result []domain.ID
+		err    error
+	}{
+		{
+			name:   "default order",
+			query:  domain.Query{},
+			result: []domain.ID{"1", "2", "3", "4"},
+			err:    nil,
+		},
+		{
+			name:   "status filter",
+			query:  domain.Query{Status: domain.StatusNew},
+			result: []domain.ID{"1", "3"},
+			err:    nil,
+		},
+		{
+			name:   "created range",
+			query:  domain.Query{CreatedFrom: 15, CreatedTo: 25},
+			result: []domain.ID{"3", "4"},
+			err:    nil,
+		},
+		{
+			name:   "case insensitive search",
+			query:  domain.Query{Search: "BUY"},
+			result: []domain.ID{"1", "3"},
+			err:    nil,
+		},
+		{
+			name:   "owner filter",
+			query:  domain.Query{Owner: "alice"},
+			result: []domain.ID{"1", "3"},
+			err:    nil,
+		},
+		{
+			name:   "sort by created_at descending",
+			query:  domain.Query{Sort: domain.SortByCreatedAt, Order: domain.OrderDesc},
+			result: []domain.ID{"1", "4", "3", "2"},
+			err:    nil,
+		},
+		{
+			name:   "sort by title",
+			query:  domain.Query{Sort: domain.SortByTitle},
+			result: []domain.ID{"1", "4", "2", "3"},
+			err:    nil,
+		},
+		{
+			name:   "malformed cursor",
+			query:  domain.Query{Cursor: "%%%"},
+			result: nil,
+			err:    ErrMalformedCursor,
+		},
+		{
+			name:   "cursor from another sort",
+			query:  domain.Query{Sort: domain.SortByTitle, Cursor: encodeCursor(records[0], domain.Query{Sort: domain.SortByID})},
+			result: nil,
+			err:    ErrMalformedCursor,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			page, err := storage.GetTasks(context.Background(), cs.query)
+			assert.ErrorIs(t, err, cs.err)
+			var ids []domain.ID
+			for _, record := range page.Records {
+				ids = append(ids, record.ID)
+			}
+			assert.Equal(t, cs.result, ids)
+			assert.Empty(t, page.NextCursor)
+		})
+	}
+	t.Run("pagination", func(t *testing.T) {
+		query := domain.Query{Sort: domain.SortByCreatedAt, Limit: 3}
+		var ids []domain.ID
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, len(records))
+			page, err := storage.GetTasks(context.Background(), query)
+			require.NoError(t, err)
+			for _, record := range page.Records {
+				ids = append(ids, record.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+		assert.Equal(t, []domain.ID{"2", "3", "4", "1"}, ids)
+	})
+}
+
+func Test_CreateTaskWithEvent_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		task    domain.Record
+		entry   domain.Outbox
+		storage *Storage
+		result  domain.ID
+		err     error
+	}{
+		{
+			name:  "success",
+			ctx:   context.Background(),
+			task:  domain.Record{ID: "1"},
+			entry: domain.Outbox{},
+			storage: &Storage{
+				store:  &mockKeeper{},
+				outbox: &mockKeeper{},
+			},
+			result: "1",
+			err:    nil,
+		},
+		{
+			name:  "already exists",
+			ctx:   context.Background(),
+			task:  domain.Record{ID: "1"},
+			entry: domain.Outbox{},
+			storage: &Storage{
+				store:  &mockKeeper{cc: mockCreateContext{err: inmemory.ErrAlreadyExists}},
+				outbox: &mockKeeper{},
+			},
+			result: "",
+			err:    ErrAlreadyExists,
+		},
+		{
+			name:  "outbox failure",
+			ctx:   context.Background(),
+			task:  domain.Record{ID: "1"},
+			entry: domain.Outbox{},
+			storage: &Storage{
+				store:  &mockKeeper{},
+				outbox: &mockKeeper{cc: mockCreateContext{err: errors.New("")}},
+			},
+			result: "",
+			err:    ErrExecuting,
+		},
+		{
+			name:  "context closed mid outbox call",
+			ctx:   context.Background(),
+			task:  domain.Record{ID: "1"},
+			entry: domain.Outbox{},
+			storage: &Storage{
+				store:  &mockKeeper{},
+				outbox: &mockKeeper{cc: mockCreateContext{err: inmemory.ErrOperationCanceled}},
+			},
+			result: "",
+			err:    ErrOperationCanceled,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			id, err := cs.storage.CreateTaskWithEvent(cs.ctx, cs.task, cs.entry)
+			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.result, id)
+		})
+	}
+}
+
+func Test_PendingEvents_Unit(t *testing.T) {
+	t.Parallel()
+	entries := []any{
+		domain.Outbox{ID: 3, State: domain.OutboxPending},
+		domain.Outbox{ID: 1, State: domain.OutboxPending},
+		domain.Outbox{ID: 2, State: domain.OutboxDelivered},
+		domain.Outbox{ID: 4, State: domain.OutboxPending},
+		domain.Outbox{ID: 5, State: domain.OutboxDead},
+		domain.Outbox{ID: 6, State: domain.OutboxPending, RetryAt: 20},
+	}
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		due     int64
+		limit   int
+		storage *Storage
+		result  []int
+		err     error
+	}{
+		{
+			name:    "success",
+			ctx:     context.Background(),
+			due:     10,
+			limit:   0,
+			storage: &Storage{outbox: &mockKeeper{ac: mockAllContext{records: entries}}},
+			result:  []int{1, 3, 4},
+			err:     nil,
+		},
+		{
+			name:    "backing off entry now due",
+			ctx:     context.Background(),
+			due:     20,
+			limit:   0,
+			storage: &Storage{outbox: &mockKeeper{ac: mockAllContext{records: entries}}},
+			result:  []int{1, 3, 4, 6},
+			err:     nil,
+		},
+		{
+			name:    "limited",
+			ctx:     context.Background(),
+			due:     10,
+			limit:   2,
+			storage: &Storage{outbox: &mockKeeper{ac: mockAllContext{records: entries}}},
+			result:  []int{1, 3},
+			err:     nil,
+		},
+		{
+			name:    "incompatible data",
+			ctx:     context.Background(),
+			limit:   0,
+			storage: &Storage{outbox: &mockKeeper{ac: mockAllContext{records: []any{""}}}},
+			result:  nil,
+			err:     ErrIncompatible,
+		},
+		{
+			name:    "context closed mid databse call",
+			ctx:     context.Background(),
+			limit:   0,
+			storage: &Storage{outbox: &mockKeeper{ac: mockAllContext{err: inmemory.ErrOperationCanceled}}},
+			result:  nil,
+			err:     ErrOperationCanceled,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			pending, err := cs.storage.PendingEvents(cs.ctx, cs.due, cs.limit)
+			assert.ErrorIs(t, err, cs.err)
+			var ids []int
+			for _, entry := range pending {
+				ids = append(ids, entry.ID)
+			}
+			assert.Equal(t, cs.result, ids)
+		})
+	}
+}
+
+func Test_UpdateEvent_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		entry   domain.Outbox
+		storage *Storage
+		err     error
+	}{
+		{
+			name:    "pending",
+			ctx:     context.Background(),
+			entry:   domain.Outbox{State: domain.OutboxPending},
+			storage: &Storage{outbox: &mockKeeper{lc: mockLoadContext{record: domain.Outbox{}}}},
+			err:     nil,
+		},
+		{
+			name:    "delivered",
+			ctx:     context.Background(),
+			entry:   domain.Outbox{State: domain.OutboxDelivered},
+			storage: &Storage{outbox: &mockKeeper{lc: mockLoadContext{record: domain.Outbox{}}, dc: mockDeleteContext{err: errors.New("")}}},
+			err:     nil,
+		},
+		{
+			name:    "entry not found",
+			ctx:     context.Background(),
+			entry:   domain.Outbox{State: domain.OutboxDead},
+			storage: &Storage{outbox: &mockKeeper{lc: mockLoadContext{err: inmemory.ErrNotFound}}},
+			err:     ErrNotFound,
+		},
+		{
+			name:    "database failure",
+			ctx:     context.Background(),
+			entry:   domain.Outbox{State: domain.OutboxPending},
+			storage: &Storage{outbox: &mockKeeper{lc: mockLoadContext{record: domain.Outbox{}}, uocc: mockUpdateOrCreateContext{err: errors.New("")}}},
+			err:     ErrExecuting,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			err := cs.storage.UpdateEvent(cs.ctx, cs.entry)
+			assert.ErrorIs(t, err, cs.err)
+		})
+	}
+}
+
+func Test_Outbox_Unit(t *testing.T) {
+	t.Parallel()
+	s := New()
+	_, err := s.CreateTaskWithEvent(context.Background(), domain.Record{ID: "1"}, domain.Outbox{State: domain.OutboxPending})
+	require.NoError(t, err)
+	_, err = s.CreateTaskWithEvent(context.Background(), domain.Record{ID: "2"}, domain.Outbox{State: domain.OutboxPending})
+	require.NoError(t, err)
+	require.NoError(t, s.UpdateEvent(context.Background(), domain.Outbox{ID: 1, State: domain.OutboxDead, Attempts: 10}))
+	require.NoError(t, s.UpdateEvent(context.Background(), domain.Outbox{ID: 2, State: domain.OutboxDelivered}))
+
+	pending, err := s.PendingEvents(context.Background(), 0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+	dead, err := s.GetEvent(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.OutboxDead, dead.State)
+	assert.Equal(t, 10, dead.Attempts)
+	events, err := s.Events(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+}
+
+func Test_UpdateTaskWithEvent_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		from    domain.Status
+		task    domain.Record
+		entry   domain.Outbox
+		storage *Storage
+		err     error
+	}{
+		{
+			name:  "success",
+			ctx:   context.Background(),
+			task:  domain.Record{ID: "1"},
+			entry: domain.Outbox{},
+			storage: &Storage{
+				store:  &mockKeeper{lc: mockLoadContext{record: domain.Record{ID: "1"}}},
+				outbox: &mockKeeper{},
+			},
+			err: nil,
+		},
+		{
+			name:  "not found",
+			ctx:   context.Background(),
+			task:  domain.Record{ID: "1"},
+			entry: domain.Outbox{},
+			storage: &Storage{
+				store:  &mockKeeper{lc: mockLoadContext{err: inmemory.ErrNotFound}},
+				outbox: &mockKeeper{},
+			},
+			err: ErrNotFound,
+		},
+		{
+			name:  "status changed",
+			ctx:   context.Background(),
+			from:  domain.StatusNew,
+			task:  domain.Record{ID: "1"},
+			entry: domain.Outbox{},
+			storage: &Storage{
+				store:  &mockKeeper{lc: mockLoadContext{record: domain.Record{ID: "1", Status: domain.StatusPending}}},
+				outbox: &mockKeeper{},
+			},
+			err: ErrConflict,
+		},
+		{
+			name:  "outbox failure",
+			ctx:   context.Background(),
+			task:  domain.Record{ID: "1"},
+			entry: domain.Outbox{},
+			storage: &Storage{
+				store:  &mockKeeper{lc: mockLoadContext{record: domain.Record{ID: "1"}}},
+				outbox: &mockKeeper{cc: mockCreateContext{err: errors.New("")}},
+			},
+			err: ErrExecuting,
+		},
+		{
+			name:  "context closed mid databse call",
+			ctx:   context.Background(),
+			task:  domain.Record{ID: "1"},
+			entry: domain.Outbox{},
+			storage: &Storage{
+				store:  &mockKeeper{lc: mockLoadContext{err: inmemory.ErrOperationCanceled}},
+				outbox: &mockKeeper{},
+			},
+			err: ErrOperationCanceled,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			err := cs.storage.UpdateTaskWithEvent(cs.ctx, cs.from, cs.task, cs.entry)
+			assert.ErrorIs(t, err, cs.err)
+		})
+	}
+}
+
+func Test_DeleteTaskWithEvent_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		id      domain.ID
+		entry   domain.Outbox
+		storage *Storage
+		err     error
+	}{
+		{
+			name:  "success",
+			ctx:   context.Background(),
+			id:    "1",
+			entry: domain.Outbox{},
+			storage: &Storage{
+				store:  &mockKeeper{},
+				outbox: &mockKeeper{},
+			},
+			err: nil,
+		},
+		{
+			name:  "not found",
+			ctx:   context.Background(),
+			id:    "1",
+			entry: domain.Outbox{},
+			storage: &Storage{
+				store:  &mockKeeper{dc: mockDeleteContext{err: inmemory.ErrNotFound}},
+				outbox: &mockKeeper{},
+			},
+			err: ErrNotFound,
+		},
+		{
+			name:  "context closed mid databse call",
+			ctx:   context.Background(),
+			id:    "1",
+			entry: domain.Outbox{},
+			storage: &Storage{
+				store:  &mockKeeper{dc: mockDeleteContext{err: inmemory.ErrOperationCanceled}},
+				outbox: &mockKeeper{},
+			},
+			err: ErrOperationCanceled,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			err := cs.storage.DeleteTaskWithEvent(cs.ctx, cs.id, cs.entry)
+			assert.ErrorIs(t, err, cs.err)
+		})
+	}
+}
+
+func Test_CountTasks_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		storage *Storage
+		result  map[domain.Status]int
+		err     error
+	}{
+		{
+			name: "success",
+			ctx:  context.Background(),
+			storage: &Storage{store: &mockKeeper{
+				ac: mockAllContext{records: []any{
+					domain.Record{Status: domain.StatusNew},
+					domain.Record{Status: domain.StatusNew},
+					domain.Record{Status: domain.StatusFailed},
+				}},
+			}},
+			result: map[domain.Status]int{domain.StatusNew: 2, domain.StatusFailed: 1},
+			err:    nil,
+		},
+		{
+			name: "incompatible data",
+			ctx:  context.Background(),
+			storage: &Storage{store: &mockKeeper{
+				ac: mockAllContext{records: []any{""}},
+			}},
+			result: nil,
+			err:    ErrIncompatible,
+		},
+		{
+			name: "context closed mid databse call",
+			ctx:  context.Background(),
+			storage: &Storage{store: &mockKeeper{
+				ac: mockAllContext{err: inmemory.ErrOperationCanceled},
+			}},
+			result: nil,
+			err:    ErrOperationCanceled,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			counts, err := cs.storage.CountTasks(cs.ctx)
+			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.result, counts)
+		})
+	}
+}
+
+func Test_Retry_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		failures []error
+		calls    int
+		err      error
+	}{
+		{
+			name:  "success",
+			calls: 1,
+			err:   nil,
+		},
+		{
+			name:     "conflict then success",
+			failures: []error{ErrConflict},
+			calls:    2,
+			err:      nil,
+		},
+		{
+			name:     "conflicts exhausted",
+			failures: []error{ErrConflict, ErrConflict, ErrConflict, ErrConflict},
+			calls:    ConflictAttempts,
+			err:      ErrConflict,
+		},
+		{
+			name:     "other error not retried",
+			failures: []error{ErrNotFound},
+			calls:    1,
+			err:      ErrNotFound,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			var calls int
+			err := Retry(func() error {
+				calls++
+				if calls <= len(cs.failures) {
+					return cs.failures[calls-1]
+				}
+				return nil
+			})
+			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.calls, calls)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package traced
+
+import (
+	"context"
+
+	"service1/internal/domain"
+	"service1/internal/pkg/tracing"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Backend interface {
+	Close() error
+	Ping(ctx context.Context) error
+	CreateTask(ctx context.Context, task domain.Record) (domain.ID, error)
+	UpdateOrCreateTask(ctx context.Context, task domain.Record) error
+	TransitionTask(ctx context.Context, id domain.ID, from domain.Status, to domain.Status) (domain.Record, error)
+	GetTaskByID(ctx context.Context, id domain.ID) (domain.Record, error)
+	GetTasks(ctx context.Context, query domain.Query) (domain.Page, error)
+	CountTasks(ctx context.Context) (map[domain.Status]int, error)
+	CreateTaskWithEvent(ctx context.Context, task domain.Record, entry domain.Outbox) (domain.ID, error)
+	UpdateTaskWithEvent(ctx context.Context, from domain.Status, task domain.Record, entry domain.Outbox) error
+	DeleteTaskWithEvent(ctx context.Context, id domain.ID, entry domain.Outbox) error
+	PendingEvents(ctx context.Context, due int64, limit int) ([]domain.Outbox, error)
+	UpdateEvent(ctx context.Context, entry domain.Outbox) error
+}
+
+type Storage struct {
+	backend Backend
+	system  string
+}
+
+func New(backend Backend, system string) *Storage {
+	return &Storage{backend: backend, system: system}
+}
+
+// Spans are only recorded under an existing trace, so background polling
+// (relay ticks, metric scrapes) doesn't produce a root span per call.
+func (s *Storage) start(ctx context.Context, operation string) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return tracing.Start(ctx, "storage."+operation,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			semconv.DBSystemNameKey.String(s.system),
+			semconv.DBOperationName(operation),
+		),
+	)
+}
+
+func (s *Storage) Close() error {
+	return s.backend.Close()
+}
+
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.backend.Ping(ctx)
+}
+
+func (s *Storage) CreateTask(ctx context.Context, task domain.Record) (domain.ID, error) {
+	ctx, span := s.start(ctx, "CreateTask")
+	id, err := s.backend.CreateTask(ctx, task)
+	tracing.End(span, err)
+	return id, err
+}
+
+func (s *Storage) UpdateOrCreateTask(ctx context.Context, task domain.Record) error {
+	ctx, span := s.start(ctx, "UpdateOrCreateTask")
+	err := s.backend.UpdateOrCreateTask(ctx, task)
+	tracing.End(span, err)
+	return err
+}
+
+func (s *Storage) TransitionTask(ctx context.Context, id domain.ID, from domain.Status, to domain.Status) (domain.Record, error) {
+	ctx, span := s.start(ctx, "TransitionTask")
+	task, err := s.backend.TransitionTask(ctx, id, from, to)
+	tracing.End(span, err)
+	return task, err
+}
+
+func (s *Storage) GetTaskByID(ctx context.Context, id domain.ID) (domain.Record, error) {
+	ctx, span := s.start(ctx, "GetTaskByID")
+	task, err := s.backend.GetTaskByID(ctx, id)
+	tracing.End(span, err)
+	return task, err
+}
+
+func (s *Storage) GetTasks(ctx context.Context, query domain.Query) (domain.Page, error) {
+	ctx, span := s.start(ctx, "GetTasks")
+	page, err := s.backend.GetTasks(ctx, query)
+	tracing.End(span, err)
+	return page, err
+}
+
+func (s *Storage) CountTasks(ctx context.Context) (map[domain.Status]int, error) {
+	ctx, span := s.start(ctx, "CountTasks")
+	counts, err := s.backend.CountTasks(ctx)
+	tracing.End(span, err)
+	return counts, err
+}
+
+func (s *Storage) CreateTaskWithEvent(ctx context.Context, task domain.Record, entry domain.Outbox) (domain.ID, error) {
+	ctx, span := s.start(ctx, "CreateTaskWithEvent")
+	id, err := s.backend.CreateTaskWithEvent(ctx, task, entry)
+	tracing.End(span, err)
+	return id, err
+}
+
+func (s *Storage) UpdateTaskWithEvent(ctx context.Context, from domain.Status, task domain.Record, entry domain.Outbox) error {
+	ctx, span := s.start(ctx, "UpdateTaskWithEvent")
+	err := s.backend.UpdateTaskWithEvent(ctx, from, task, entry)
+	tracing.End(span, err)
+	return err
+}
+
+func (s *Storage) DeleteTaskWithEvent(ctx context.Context, id domain.ID, entry domain.Outbox) error {
+	ctx, span := s.start(ctx, "DeleteTaskWithEvent")
+	err := s.backend.DeleteTaskWithEvent(ctx, id, entry)
+	tracing.End(span, err)
+	return err
+}
+
+func (s *Storage) PendingEvents(ctx context.Context, due int64, limit int) ([]domain.Outbox, error) {
+	ctx, span := s.start(ctx, "PendingEvents")
+	entries, err := s.backend.PendingEvents(ctx, due, limit)
+	tracing.End(span, err)
+	return entries, err
+}
+
+func (s *Storage) UpdateEvent(ctx context.Context, entry domain.Outbox) error {
+	ctx, span := s.start(ctx, "UpdateEvent")
+	err := s.backend.UpdateEvent(ctx, entry)
+	tracing.End(span, err)
+	return err
+}
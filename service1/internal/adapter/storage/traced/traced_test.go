@@ -0,0 +1,42 @@
+package traced
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"service1/internal/adapter/storage/inmemory"
+	"service1/internal/domain"
+	"service1/internal/pkg/tracing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func Test_Storage_Unit(t *testing.T) {
+	collected := tracetest.NewSpanRecorder()
+	tracing.Install(sdktrace.WithSpanProcessor(collected))
+	s := New(inmemory.New(), "inmemory")
+
+	_, err := s.GetTaskByID(context.Background(), "1")
+	require.True(t, errors.Is(err, inmemory.ErrNotFound))
+	assert.Empty(t, collected.Ended())
+
+	ctx, span := tracing.Start(context.Background(), "request")
+	_, err = s.CreateTask(ctx, domain.Record{ID: "1", Title: "title"})
+	require.NoError(t, err)
+	_, err = s.GetTaskByID(ctx, "2")
+	require.Error(t, err)
+	span.End()
+
+	ended := collected.Ended()
+	require.Len(t, ended, 3)
+	assert.Equal(t, "storage.CreateTask", ended[0].Name())
+	assert.Equal(t, span.SpanContext().SpanID(), ended[0].Parent().SpanID())
+	assert.Equal(t, codes.Unset, ended[0].Status().Code)
+	assert.Equal(t, "storage.GetTaskByID", ended[1].Name())
+	assert.Equal(t, codes.Error, ended[1].Status().Code)
+}
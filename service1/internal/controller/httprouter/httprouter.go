@@ -1,23 +1,47 @@
 package httprouter
 
 import (
+	"log/slog"
 	"net/http"
 
+	"service1/internal/pkg/admission"
+	"service1/internal/pkg/auth"
+	"service1/internal/pkg/logger"
+	"service1/internal/pkg/metrics"
+	"service1/internal/pkg/ratelimit"
+	"service1/internal/pkg/tracing"
 	"service1/internal/usecase/create"
+	"service1/internal/usecase/health"
 	"service1/internal/usecase/list"
 	"service1/internal/usecase/listid"
+	"service1/internal/usecase/patch"
+	"service1/internal/usecase/remove"
 )
 
 type Config struct {
 	Create *create.Usecase
 	List   *list.Usecase
 	ListID *listid.Usecase
+	Patch  *patch.Usecase
+	Remove *remove.Usecase
+	Health *health.Usecase
+
+	Auth      *auth.Authenticator
+	Limiter   *ratelimit.Limiter
+	Admission *admission.Controller
+	Metrics   *metrics.Metrics
+	Logger    *slog.Logger
 }
 
 func New(c *Config) http.Handler {
 	m := http.NewServeMux()
-	m.HandleFunc("/list", c.List.HTTPHandler)
-	m.HandleFunc("/list/{id}", c.ListID.HTTPHandler)
-	m.HandleFunc("/create", c.Create.HTTPHandler)
-	return m
+	m.HandleFunc("/list", c.Metrics.Instrument("/list", tracing.Handler("/list", c.Limiter.IPHandler(c.Auth.Handler(c.Limiter.Handler("/list", c.List.HTTPHandler))))))
+	m.HandleFunc("/list/{id}", c.Metrics.Instrument("/list/{id}", tracing.Handler("/list/{id}", c.Limiter.IPHandler(c.Auth.Handler(c.Limiter.Handler("/list/{id}", c.ListID.HTTPHandler))))))
+	m.HandleFunc("/create", c.Metrics.Instrument("/create", tracing.Handler("/create", c.Admission.Handler(c.Limiter.IPHandler(c.Auth.Handler(c.Limiter.Handler("/create", c.Create.HTTPHandler)))))))
+	m.HandleFunc("PATCH /tasks/{id}", c.Metrics.Instrument("PATCH /tasks/{id}", tracing.Handler("/tasks/{id}", c.Limiter.IPHandler(c.Auth.Handler(c.Limiter.Handler("PATCH /tasks/{id}", c.Patch.HTTPHandler))))))
+	m.HandleFunc("DELETE /tasks/{id}", c.Metrics.Instrument("DELETE /tasks/{id}", tracing.Handler("/tasks/{id}", c.Limiter.IPHandler(c.Auth.Handler(c.Limiter.Handler("DELETE /tasks/{id}", c.Remove.HTTPHandler))))))
+	m.HandleFunc("/healthz", c.Health.LiveHandler)
+	m.HandleFunc("/readyz", c.Health.ReadyHandler)
+	m.Handle("GET /metrics", c.Metrics.Handler())
+	return logger.Middleware(c.Logger, m)
 }
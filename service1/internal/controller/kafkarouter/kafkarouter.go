@@ -0,0 +1,104 @@
+package kafkarouter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"service1/internal/adapter/broker/kafkaa"
+	"service1/internal/domain"
+	"service1/internal/pkg/logger"
+	"service1/internal/usecase/status"
+
+	"github.com/segmentio/kafka-go"
+)
+
+var (
+	ErrUnknownContentType  = errors.New("kafkarouter: unknown content type")
+	ErrUnmarshalingMessage = errors.New("kafkarouter: failed while unmarshaling message")
+	ErrUnsupportedVersion  = errors.New("kafkarouter: unsupported schema version")
+)
+
+type Config struct {
+	Status *status.Usecase
+
+	Decoders map[string]Decoder
+	Fallback Decoder
+	Logger   *slog.Logger
+}
+
+type Router struct {
+	Config *Config
+
+	Handlers *Handlers
+}
+
+type Decoder interface {
+	Unmarshal(data []byte, v any) error
+}
+
+type Handler interface {
+	EventHandler(ctx context.Context, event domain.Event) error
+}
+
+type Handlers struct {
+	status Handler
+}
+
+func New(c *Config) *Router {
+	return &Router{
+		Config: c,
+		Handlers: &Handlers{
+			status: c.Status,
+		},
+	}
+}
+
+func (r *Router) Route(ctx context.Context, message kafka.Message) error {
+	ctx = logger.WithRequestID(ctx, kafkaa.Header(message, kafkaa.HeaderRequestID))
+	envelope, err := r.decode(kafkaa.Header(message, kafkaa.HeaderContentType), message)
+	if err != nil {
+		r.Config.Logger.ErrorContext(ctx, "decode event", slog.Any("error", err))
+		return nil
+	}
+	switch action(message, envelope.Type) {
+	case domain.ActionStatus:
+		return r.Handlers.status.EventHandler(ctx, envelope.Payload)
+	}
+	return nil
+}
+
+func (r *Router) decode(contentType string, message kafka.Message) (domain.Envelope, error) {
+	decoder, ok := r.Config.Decoders[contentType]
+	switch {
+	case ok:
+	case contentType == "" && r.Config.Fallback != nil:
+		decoder = r.Config.Fallback
+	default:
+		return domain.Envelope{}, fmt.Errorf("%w: %q", ErrUnknownContentType, contentType)
+	}
+	var envelope domain.Envelope
+	if err := decoder.Unmarshal(message.Value, &envelope); err == nil && envelope.SchemaVersion != 0 {
+		if envelope.SchemaVersion > domain.EventSchemaVersion {
+			return domain.Envelope{}, fmt.Errorf("%w: %d", ErrUnsupportedVersion, envelope.SchemaVersion)
+		}
+		return envelope, nil
+	}
+	var event domain.Event
+	if err := decoder.Unmarshal(message.Value, &event); err != nil {
+		return domain.Envelope{}, fmt.Errorf("%w: %v", ErrUnmarshalingMessage, err)
+	}
+	return domain.Envelope{
+		Type:          action(message, domain.Action(string(message.Key))),
+		SchemaVersion: domain.EventSchemaLegacy,
+		Payload:       event,
+	}, nil
+}
+
+func action(message kafka.Message, fallback domain.Action) domain.Action {
+	if a := kafkaa.Header(message, kafkaa.HeaderAction); a != "" {
+		return domain.Action(a)
+	}
+	return fallback
+}
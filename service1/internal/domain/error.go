@@ -13,12 +13,21 @@ var (
 	ErrMalformedBody      = Error{Code: http.StatusBadRequest, Message: "malformed body"}
 	ErrInternal           = Error{Code: http.StatusInternalServerError, Message: "internal error"}
 	ErrMalformedPathValue = Error{Code: http.StatusBadRequest, Message: "malformed path value"}
+	ErrMalformedQuery     = Error{Code: http.StatusBadRequest, Message: "malformed query parameters"}
+	ErrMalformedHeader    = Error{Code: http.StatusBadRequest, Message: "malformed header"}
+	ErrUnauthorized       = Error{Code: http.StatusUnauthorized, Message: "missing or invalid credentials"}
+	ErrTooManyRequests    = Error{Code: http.StatusTooManyRequests, Message: "too many requests, retry later"}
 )
 
 // SITUATIONAL ERRORS
 var (
 	ErrEmptyTitle        = Error{Code: http.StatusBadRequest, Message: "task's title can't be empty"}
+	ErrInvalidStatus     = Error{Code: http.StatusBadRequest, Message: "unknown task status"}
+	ErrEmptyPatch        = Error{Code: http.StatusBadRequest, Message: "nothing to update"}
 	ErrAlreadyExists     = Error{Code: http.StatusConflict, Message: "task already exists"}
+	ErrIllegalTransition = Error{Code: http.StatusConflict, Message: "task can't move to this status"}
+	ErrRequestInProgress = Error{Code: http.StatusConflict, Message: "request with this idempotency key is still in progress"}
+	ErrKeyReused         = Error{Code: http.StatusUnprocessableEntity, Message: "idempotency key was used with a different request"}
 	ErrNotFound          = Error{Code: http.StatusNotFound, Message: "no tasks found"}
 	ErrBrokerUnavailable = Error{Code: http.StatusServiceUnavailable, Message: "service can't handle the request at the moment"}
 )
@@ -1,5 +1,22 @@
 package domain
 
+// EventSchemaVersion is the version producers write; each earlier version
+// keeps its own name so upcasters can target it after the next bump.
+const (
+	EventSchemaLegacy   = 1
+	EventSchemaEnvelope = 2
+	EventSchemaVersion  = EventSchemaEnvelope
+)
+
 type Event struct {
 	Record Record `json:"record"`
 }
+
+type Envelope struct {
+	ID            string `json:"id"`
+	Type          Action `json:"type"`
+	SchemaVersion int    `json:"schema_version"`
+	OccurredAt    int64  `json:"occurred_at"`
+	Producer      string `json:"producer"`
+	Payload       Event  `json:"payload"`
+}
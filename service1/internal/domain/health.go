@@ -0,0 +1,14 @@
+package domain
+
+type HealthStatus string
+
+const (
+	HealthOK          HealthStatus = "ok"
+	HealthDraining    HealthStatus = "draining"
+	HealthUnavailable HealthStatus = "unavailable"
+)
+
+type Health struct {
+	Status HealthStatus      `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var errMalformedID = errors.New("domain: malformed id")
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+type ID string
+
+func (id ID) Valid() bool {
+	if _, ok := id.number(); ok {
+		return true
+	}
+	return id.ulid() || id.uuid()
+}
+
+func (id ID) Compare(other ID) int {
+	a, aok := id.number()
+	b, bok := other.number()
+	switch {
+	case aok && bok && a < b:
+		return -1
+	case aok && bok && a > b:
+		return 1
+	case aok && bok:
+		return 0
+	default:
+		return strings.Compare(string(id), string(other))
+	}
+}
+
+// IDs marshal as JSON strings because snowflakes run past 2^53 and lose
+// precision as JSON numbers; bare numbers from older logs and producers are
+// still accepted.
+func (id *ID) UnmarshalJSON(data []byte) error {
+	raw := string(data)
+	if raw == "null" {
+		return nil
+	}
+	if strings.HasPrefix(raw, `"`) {
+		s, err := strconv.Unquote(raw)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errMalformedID, err)
+		}
+		*id = ID(s)
+		return nil
+	}
+	if _, err := strconv.ParseUint(raw, 10, 64); err != nil {
+		return fmt.Errorf("%w: %v", errMalformedID, err)
+	}
+	*id = ID(raw)
+	return nil
+}
+
+func (id ID) number() (uint64, bool) {
+	n, err := strconv.ParseUint(string(id), 10, 64)
+	if err != nil || strconv.FormatUint(n, 10) != string(id) {
+		return 0, false
+	}
+	return n, true
+}
+
+func (id ID) ulid() bool {
+	if len(id) != 26 || id[0] > '7' {
+		return false
+	}
+	for _, r := range strings.ToUpper(string(id)) {
+		if !strings.ContainsRune(crockford, r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (id ID) uuid() bool {
+	if len(id) != 36 {
+		return false
+	}
+	for i, r := range id {
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if r != '-' {
+				return false
+			}
+		case r >= '0' && r <= '9', r >= 'a' && r <= 'f', r >= 'A' && r <= 'F':
+		default:
+			return false
+		}
+	}
+	return true
+}
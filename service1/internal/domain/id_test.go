@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_IDJSON_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name string
+		id   ID
+		json string
+	}{
+		{name: "snowflake", id: "7263412891123712", json: `"7263412891123712"`},
+		{name: "snowflake above 2^53", id: "432345564227567616", json: `"432345564227567616"`},
+		{name: "ulid", id: "01ARZ3NDEKTSV4RRFFQ69G5FAV", json: `"01ARZ3NDEKTSV4RRFFQ69G5FAV"`},
+		{name: "zero padded number", id: "007", json: `"007"`},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			data, err := json.Marshal(cs.id)
+			assert.NoError(t, err)
+			assert.Equal(t, cs.json, string(data))
+			var id ID
+			assert.NoError(t, json.Unmarshal(data, &id))
+			assert.Equal(t, cs.id, id)
+		})
+	}
+	var id ID
+	assert.NoError(t, json.Unmarshal([]byte(`432345564227567616`), &id))
+	assert.Equal(t, ID("432345564227567616"), id)
+	assert.NoError(t, json.Unmarshal([]byte(`18446744073709551615`), &id))
+	assert.Equal(t, ID("18446744073709551615"), id)
+	assert.Error(t, json.Unmarshal([]byte(`-1`), &id))
+	assert.Error(t, json.Unmarshal([]byte(`1.5`), &id))
+}
+
+func Test_IDCompare_Unit(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, -1, ID("9").Compare("10"))
+	assert.Equal(t, 1, ID("01ARZ3NDEKTSV4RRFFQ69G5FAW").Compare("01ARZ3NDEKTSV4RRFFQ69G5FAV"))
+	assert.Equal(t, 0, ID("42").Compare("42"))
+}
@@ -0,0 +1,27 @@
+package domain
+
+import "strconv"
+
+type OutboxState string
+
+var (
+	OutboxPending   OutboxState = "pending"
+	OutboxDelivered OutboxState = "delivered"
+	OutboxDead      OutboxState = "dead"
+)
+
+type Outbox struct {
+	ID        int               `json:"id"`
+	Action    Action            `json:"action"`
+	Event     Event             `json:"event"`
+	State     OutboxState       `json:"state"`
+	Attempts  int               `json:"attempts"`
+	CreatedAt int64             `json:"created_at"`
+	RetryAt   int64             `json:"retry_at"`
+	RequestID string            `json:"request_id,omitempty"`
+	Trace     map[string]string `json:"trace,omitempty"`
+}
+
+func (o Outbox) EventID() string {
+	return string(o.Event.Record.ID) + "-" + strconv.Itoa(o.ID)
+}
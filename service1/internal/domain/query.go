@@ -0,0 +1,51 @@
+package domain
+
+type SortField string
+
+var (
+	SortByID        SortField = "id"
+	SortByCreatedAt SortField = "created_at"
+	SortByTitle     SortField = "title"
+)
+
+func (f SortField) Valid() bool {
+	switch f {
+	case SortByID, SortByCreatedAt, SortByTitle:
+		return true
+	default:
+		return false
+	}
+}
+
+type Order string
+
+var (
+	OrderAsc  Order = "asc"
+	OrderDesc Order = "desc"
+)
+
+func (o Order) Valid() bool {
+	switch o {
+	case OrderAsc, OrderDesc:
+		return true
+	default:
+		return false
+	}
+}
+
+type Query struct {
+	Status      Status
+	CreatedFrom int64
+	CreatedTo   int64
+	Search      string
+	Owner       string
+	Sort        SortField
+	Order       Order
+	Cursor      string
+	Limit       int
+}
+
+type Page struct {
+	Records    []Record `json:"records"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
@@ -1,8 +1,9 @@
 package domain
 
 type Record struct {
-	ID        int    `json:"id"`
+	ID        ID     `json:"id"`
 	Title     string `json:"title"`
 	CreatedAt int64  `json:"created_at"`
 	Status    Status `json:"status"`
+	Owner     string `json:"owner,omitempty"`
 }
@@ -0,0 +1,7 @@
+package domain
+
+type Replay struct {
+	Fingerprint string `json:"fingerprint"`
+	Code        int    `json:"code"`
+	Body        []byte `json:"body"`
+}
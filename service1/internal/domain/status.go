@@ -9,3 +9,32 @@ var (
 	StatusCompleted  Status = "completed"
 	StatusFailed     Status = "failed"
 )
+
+func (s Status) Valid() bool {
+	switch s {
+	case StatusNew, StatusPending, StatusProcessing, StatusCompleted, StatusFailed:
+		return true
+	default:
+		return false
+	}
+}
+
+// New may skip straight to processing: the relay records pending only after
+// the broker acks the event, so service2's processing report can land first.
+// New may also fail outright when the relay gives up on the create event, so
+// the task was never handed to service2 at all.
+var transitions = map[Status][]Status{
+	StatusNew:        {StatusPending, StatusProcessing, StatusFailed},
+	StatusPending:    {StatusProcessing, StatusFailed},
+	StatusProcessing: {StatusCompleted, StatusFailed},
+	StatusFailed:     {StatusPending},
+}
+
+func (s Status) CanTransition(to Status) bool {
+	for _, next := range transitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CanTransition_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name   string
+		from   Status
+		to     Status
+		result bool
+	}{
+		{name: "new to pending", from: StatusNew, to: StatusPending, result: true},
+		{name: "new to processing before the relay marks pending", from: StatusNew, to: StatusProcessing, result: true},
+		{name: "new to failed when the create event is dead", from: StatusNew, to: StatusFailed, result: true},
+		{name: "new to completed", from: StatusNew, to: StatusCompleted, result: false},
+		{name: "pending to processing", from: StatusPending, to: StatusProcessing, result: true},
+		{name: "pending to completed", from: StatusPending, to: StatusCompleted, result: false},
+		{name: "processing to completed", from: StatusProcessing, to: StatusCompleted, result: true},
+		{name: "processing to failed", from: StatusProcessing, to: StatusFailed, result: true},
+		{name: "failed retried", from: StatusFailed, to: StatusPending, result: true},
+		{name: "completed to new", from: StatusCompleted, to: StatusNew, result: false},
+		{name: "completed to pending", from: StatusCompleted, to: StatusPending, result: false},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			assert.Equal(t, cs.result, cs.from.CanTransition(cs.to))
+		})
+	}
+}
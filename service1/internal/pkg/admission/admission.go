@@ -0,0 +1,206 @@
+package admission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"service1/internal/domain"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const HeaderRetryAfter = "Retry-After"
+
+const (
+	ReasonQueueDepth   = "queue_depth"
+	ReasonWriteLatency = "write_latency"
+	ReasonErrorRate    = "error_rate"
+)
+
+var ErrOperationCanceled = errors.New("admission: operation canceled")
+
+type Config struct {
+	Enabled         bool          `yaml:"enabled"`
+	Interval        time.Duration `yaml:"interval"`
+	MaxPending      int           `yaml:"max_pending"`
+	MaxWriteLatency time.Duration `yaml:"max_write_latency"`
+	MaxErrorRate    float64       `yaml:"max_error_rate"`
+	MinWrites       int64         `yaml:"min_writes"`
+	RetryAfter      time.Duration `yaml:"retry_after"`
+}
+
+type Writer interface {
+	Stats() kafka.WriterStats
+}
+
+// Backlog is the outbox /create writes into; the relay drains it at its own
+// pace, so its depth is what admission bounds.
+type Backlog interface {
+	PendingEvents(ctx context.Context, due int64, limit int) ([]domain.Outbox, error)
+}
+
+type Encoder interface {
+	Marshal(data any) ([]byte, error)
+}
+
+// Controller must be the writer's only Stats caller, as kafka-go resets the
+// counters on every call; it re-exports them through its own Stats.
+type Controller struct {
+	config  Config
+	writer  Writer
+	backlog Backlog
+
+	mu      sync.Mutex
+	window  kafka.WriterStats
+	pending kafka.WriterStats
+	reason  string
+
+	encoder Encoder
+	logger  *slog.Logger
+}
+
+func New(c Config, writer Writer, backlog Backlog, encoder Encoder, logger *slog.Logger) *Controller {
+	return &Controller{
+		config:  c,
+		writer:  writer,
+		backlog: backlog,
+		encoder: encoder,
+		logger:  logger,
+	}
+}
+
+func (c *Controller) Run(ctx context.Context) error {
+	if !c.config.Enabled {
+		return nil
+	}
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
+		case <-ticker.C:
+			c.Sample(ctx)
+		}
+	}
+}
+
+func (c *Controller) Sample(ctx context.Context) string {
+	depth := c.depth(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.collect()
+	reason := c.evaluate(c.window, depth)
+	c.window = kafka.WriterStats{}
+	switch {
+	case reason != "" && c.reason == "":
+		c.logger.WarnContext(ctx, "admission shedding", slog.String("reason", reason))
+	case reason == "" && c.reason != "":
+		c.logger.InfoContext(ctx, "admission recovered", slog.String("reason", c.reason))
+	}
+	c.reason = reason
+	return reason
+}
+
+func (c *Controller) Stats() kafka.WriterStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.collect()
+	s := c.pending
+	c.pending = kafka.WriterStats{}
+	return s
+}
+
+func (c *Controller) Handler(next http.HandlerFunc) http.HandlerFunc {
+	if !c.config.Enabled {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if reason := c.shedding(); reason != "" {
+			c.logger.InfoContext(r.Context(), "request shed", slog.String("reason", reason))
+			c.reject(w)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (c *Controller) shedding() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reason
+}
+
+// depth counts outbox entries up to one past the limit, which is all evaluate
+// needs to know. Entries backing off still count: they are owed to Kafka.
+func (c *Controller) depth(ctx context.Context) int {
+	if c.config.MaxPending <= 0 {
+		return 0
+	}
+	entries, err := c.backlog.PendingEvents(ctx, math.MaxInt64, c.config.MaxPending+1)
+	if err != nil {
+		c.logger.WarnContext(ctx, "admission backlog", slog.Any("error", err))
+		return 0
+	}
+	return len(entries)
+}
+
+func (c *Controller) evaluate(s kafka.WriterStats, depth int) string {
+	switch {
+	case c.config.MaxPending > 0 && depth > c.config.MaxPending:
+		return ReasonQueueDepth
+	case c.config.MaxWriteLatency > 0 && s.WriteTime.Count > 0 && s.WriteTime.Avg > c.config.MaxWriteLatency:
+		return ReasonWriteLatency
+	case c.config.MaxErrorRate > 0 && errorRate(s, c.config.MinWrites) > c.config.MaxErrorRate:
+		return ReasonErrorRate
+	default:
+		return ""
+	}
+}
+
+func errorRate(s kafka.WriterStats, minWrites int64) float64 {
+	attempts := max(s.Writes, s.Errors)
+	if attempts == 0 || attempts < minWrites {
+		return 0
+	}
+	return float64(s.Errors) / float64(attempts)
+}
+
+func (c *Controller) collect() {
+	s := c.writer.Stats()
+	accumulate(&c.window, s)
+	accumulate(&c.pending, s)
+}
+
+func accumulate(dst *kafka.WriterStats, s kafka.WriterStats) {
+	dst.Writes += s.Writes
+	dst.Messages += s.Messages
+	dst.Bytes += s.Bytes
+	dst.Errors += s.Errors
+	dst.Retries += s.Retries
+	dst.WriteTime.Count += s.WriteTime.Count
+	dst.WriteTime.Sum += s.WriteTime.Sum
+	dst.WriteTime.Max = max(dst.WriteTime.Max, s.WriteTime.Max)
+	if dst.WriteTime.Count > 0 {
+		dst.WriteTime.Avg = dst.WriteTime.Sum / time.Duration(dst.WriteTime.Count)
+	}
+}
+
+func (c *Controller) reject(w http.ResponseWriter) {
+	w.Header().Set(HeaderRetryAfter, strconv.Itoa(max(1, int(math.Ceil(c.config.RetryAfter.Seconds())))))
+	d, err := c.encoder.Marshal(domain.ErrBrokerUnavailable)
+	if err != nil {
+		http.Error(w, domain.ErrBrokerUnavailable.Message, domain.ErrBrokerUnavailable.Code)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(domain.ErrBrokerUnavailable.Code)
+	w.Write(d)
+}
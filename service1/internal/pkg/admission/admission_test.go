@@ -0,0 +1,219 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"service1/internal/adapter/broker/kafkaa"
+	"service1/internal/adapter/storage/inmemory"
+	"service1/internal/domain"
+	"service1/internal/pkg/json/standartjson"
+	"service1/internal/usecase/relay"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var discard = slog.New(slog.DiscardHandler)
+
+type mockWriter struct {
+	stats kafka.WriterStats
+}
+
+func (m *mockWriter) Stats() kafka.WriterStats {
+	s := m.stats
+	m.stats = kafka.WriterStats{}
+	return s
+}
+
+type mockBacklog struct {
+	pending int
+	err     error
+}
+
+func (m *mockBacklog) PendingEvents(ctx context.Context, due int64, limit int) ([]domain.Outbox, error) {
+	return make([]domain.Outbox, min(m.pending, limit)), m.err
+}
+
+type mockPublisher struct {
+	err error
+}
+
+func (m *mockPublisher) PublishEvent(ctx context.Context, envelope domain.Envelope) error {
+	return m.err
+}
+
+func (m *mockPublisher) Enqueue(ctx context.Context, envelope domain.Envelope, callbacks ...func(error)) *kafkaa.Future {
+	for _, callback := range callbacks {
+		callback(m.err)
+	}
+	return nil
+}
+
+type mockTimer struct{}
+
+func (m *mockTimer) TimeNow() int64 {
+	return 0
+}
+
+func Test_Sample_Unit(t *testing.T) {
+	t.Parallel()
+	config := Config{
+		Enabled:         true,
+		MaxPending:      10,
+		MaxWriteLatency: time.Second,
+		MaxErrorRate:    0.5,
+		MinWrites:       4,
+	}
+	cases := []struct {
+		name    string
+		writer  *mockWriter
+		backlog *mockBacklog
+		result  string
+	}{
+		{
+			name:   "healthy",
+			writer: &mockWriter{stats: kafka.WriterStats{Writes: 10, Errors: 1, WriteTime: kafka.DurationStats{Count: 10, Sum: time.Second}}},
+			result: "",
+		},
+		{
+			name:   "idle",
+			writer: &mockWriter{},
+			result: "",
+		},
+		{
+			name:    "queue depth",
+			writer:  &mockWriter{},
+			backlog: &mockBacklog{pending: 11},
+			result:  ReasonQueueDepth,
+		},
+		{
+			name:    "backlog unreadable",
+			writer:  &mockWriter{},
+			backlog: &mockBacklog{pending: 11, err: errors.New("")},
+			result:  "",
+		},
+		{
+			name:   "write latency",
+			writer: &mockWriter{stats: kafka.WriterStats{Writes: 2, WriteTime: kafka.DurationStats{Count: 2, Sum: 3 * time.Second}}},
+			result: ReasonWriteLatency,
+		},
+		{
+			name:   "error rate",
+			writer: &mockWriter{stats: kafka.WriterStats{Writes: 4, Errors: 3}},
+			result: ReasonErrorRate,
+		},
+		{
+			name:   "errors below min writes",
+			writer: &mockWriter{stats: kafka.WriterStats{Writes: 2, Errors: 2}},
+			result: "",
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			backlog := cs.backlog
+			if backlog == nil {
+				backlog = &mockBacklog{}
+			}
+			c := New(config, cs.writer, backlog, standartjson.New(), discard)
+			assert.Equal(t, cs.result, c.Sample(context.Background()))
+		})
+	}
+}
+
+func Test_Stats_Unit(t *testing.T) {
+	t.Parallel()
+	w := &mockWriter{stats: kafka.WriterStats{Writes: 2, Messages: 2, WriteTime: kafka.DurationStats{Count: 2, Sum: 2 * time.Second}}}
+	c := New(Config{Enabled: true, MaxWriteLatency: 2 * time.Second}, w, &mockBacklog{}, standartjson.New(), discard)
+
+	assert.Equal(t, "", c.Sample(context.Background()))
+	w.stats = kafka.WriterStats{Writes: 1, Messages: 3, WriteTime: kafka.DurationStats{Count: 1, Sum: 4 * time.Second}}
+
+	s := c.Stats()
+	assert.Equal(t, int64(3), s.Writes)
+	assert.Equal(t, int64(5), s.Messages)
+	assert.Equal(t, 2*time.Second, s.WriteTime.Avg)
+	assert.Equal(t, kafka.WriterStats{}, c.Stats())
+
+	assert.Equal(t, ReasonWriteLatency, c.Sample(context.Background()))
+	assert.Equal(t, "", c.Sample(context.Background()))
+}
+
+func Test_Handler_Unit(t *testing.T) {
+	t.Parallel()
+	w := &mockWriter{}
+	b := &mockBacklog{}
+	c := New(Config{Enabled: true, MaxPending: 1, MaxErrorRate: 0.1, RetryAfter: 1500 * time.Millisecond}, w, b, standartjson.New(), discard)
+	next := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	rec := httptest.NewRecorder()
+	c.Handler(next)(rec, httptest.NewRequest(http.MethodPost, "/create", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	b.pending = 2
+	c.Sample(context.Background())
+	rec = httptest.NewRecorder()
+	c.Handler(next)(rec, httptest.NewRequest(http.MethodPost, "/create", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get(HeaderRetryAfter))
+	var body domain.Error
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, domain.ErrBrokerUnavailable, body)
+
+	b.pending = 0
+	w.stats = kafka.WriterStats{Writes: 1, Errors: 1}
+	c.Sample(context.Background())
+	rec = httptest.NewRecorder()
+	c.Handler(next)(rec, httptest.NewRequest(http.MethodPost, "/create", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	disabled := New(Config{MaxPending: 1}, w, b, standartjson.New(), discard)
+	b.pending = 2
+	disabled.Sample(context.Background())
+	rec = httptest.NewRecorder()
+	disabled.Handler(next)(rec, httptest.NewRequest(http.MethodPost, "/create", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, disabled.Run(context.Background()))
+}
+
+func Test_Backlog_Unit(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	storage := inmemory.New()
+	defer storage.Close()
+	publisher := &mockPublisher{err: errors.New("broker down")}
+	relayer := &relay.Usecase{
+		Config:    relay.Config{BatchSize: 2, RetryAmount: 10, FailTimeout: time.Second, Async: true},
+		Outbox:    storage,
+		Updater:   storage,
+		Publisher: publisher,
+		Timer:     &mockTimer{},
+		Logger:    discard,
+	}
+	c := New(Config{Enabled: true, MaxPending: 3}, &mockWriter{}, storage, standartjson.New(), discard)
+
+	for i := range 4 {
+		id := domain.ID(strconv.Itoa(i + 1))
+		_, err := storage.CreateTaskWithEvent(ctx, domain.Record{ID: id, Status: domain.StatusNew}, domain.Outbox{Action: domain.ActionUpdate, State: domain.OutboxPending})
+		require.NoError(t, err)
+	}
+	_, err := relayer.Relay(ctx)
+	assert.ErrorIs(t, err, relay.ErrBrokerFailure)
+	assert.Equal(t, ReasonQueueDepth, c.Sample(ctx))
+
+	publisher.err = nil
+	delivered, err := relayer.Relay(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, delivered)
+	assert.Equal(t, "", c.Sample(ctx))
+}
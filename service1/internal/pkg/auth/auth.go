@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"service1/internal/domain"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	HeaderAuthorization = "Authorization"
+	HeaderAPIKey        = "X-API-Key"
+	HeaderChallenge     = "WWW-Authenticate"
+
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+
+	DefaultRolesClaim = "roles"
+
+	MinSecretLength = 32
+)
+
+var (
+	ErrMissingCredentials = errors.New("auth: no credentials provided")
+	ErrInvalidToken       = errors.New("auth: invalid token")
+	ErrInvalidAPIKey      = errors.New("auth: invalid api key")
+	ErrUnknownAlgorithm   = errors.New("auth: unknown jwt algorithm")
+	ErrMissingSecret      = errors.New("auth: hs256 requires a secret")
+	ErrWeakSecret         = errors.New("auth: hs256 secret is a placeholder or too short")
+	ErrLoadingJWKS        = errors.New("auth: failed to load jwks")
+	ErrUnknownKey         = errors.New("auth: unknown signing key")
+	ErrMalformedAPIKey    = errors.New("auth: api key requires key and subject")
+)
+
+type Config struct {
+	Enabled   bool    `yaml:"enabled"`
+	AdminRole string  `yaml:"admin_role"`
+	JWT       JWT     `yaml:"jwt"`
+	APIKeys   APIKeys `yaml:"api_keys" env:"AUTH_API_KEYS"`
+}
+
+type JWT struct {
+	Algorithm  string        `yaml:"algorithm"`
+	Secret     string        `yaml:"secret" env:"AUTH_JWT_SECRET"`
+	JWKSPath   string        `yaml:"jwks_path"`
+	Issuer     string        `yaml:"issuer"`
+	Audience   string        `yaml:"audience"`
+	RolesClaim string        `yaml:"roles_claim"`
+	Leeway     time.Duration `yaml:"leeway"`
+}
+
+type APIKey struct {
+	Key     string   `yaml:"key" json:"key"`
+	Subject string   `yaml:"subject" json:"subject"`
+	Roles   []string `yaml:"roles" json:"roles"`
+}
+
+type APIKeys []APIKey
+
+// SetValue lets cleanenv read keys from the environment as a JSON array.
+func (k *APIKeys) SetValue(s string) error {
+	if s == "" {
+		*k = nil
+		return nil
+	}
+	return json.Unmarshal([]byte(s), (*[]APIKey)(k))
+}
+
+type Principal struct {
+	Subject string
+	Roles   []string
+	Admin   bool
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+func Subject(ctx context.Context) string {
+	p, _ := FromContext(ctx)
+	return p.Subject
+}
+
+type Encoder interface {
+	Marshal(data any) ([]byte, error)
+}
+
+type apiKey struct {
+	digest [sha256.Size]byte
+	APIKey
+}
+
+type Authenticator struct {
+	config  Config
+	parser  *jwt.Parser
+	keyfunc jwt.Keyfunc
+	keys    []apiKey
+
+	encoder Encoder
+	logger  *slog.Logger
+}
+
+func New(c Config, encoder Encoder, logger *slog.Logger) (*Authenticator, error) {
+	a := &Authenticator{config: c, encoder: encoder, logger: logger}
+	if !c.Enabled {
+		return a, nil
+	}
+	if a.config.JWT.RolesClaim == "" {
+		a.config.JWT.RolesClaim = DefaultRolesClaim
+	}
+	switch c.JWT.Algorithm {
+	case "":
+	case AlgorithmHS256:
+		if c.JWT.Secret == "" {
+			return nil, fmt.Errorf("%w", ErrMissingSecret)
+		}
+		if len(c.JWT.Secret) < MinSecretLength {
+			return nil, fmt.Errorf("%w: use at least %d random bytes", ErrWeakSecret, MinSecretLength)
+		}
+		secret := []byte(c.JWT.Secret)
+		a.keyfunc = func(*jwt.Token) (any, error) {
+			return secret, nil
+		}
+	case AlgorithmRS256:
+		keys, err := loadJWKS(c.JWT.JWKSPath)
+		if err != nil {
+			return nil, err
+		}
+		a.keyfunc = keys.keyfunc
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, c.JWT.Algorithm)
+	}
+	if a.keyfunc != nil {
+		opts := []jwt.ParserOption{
+			jwt.WithValidMethods([]string{c.JWT.Algorithm}),
+			jwt.WithLeeway(c.JWT.Leeway),
+			jwt.WithExpirationRequired(),
+		}
+		if c.JWT.Issuer != "" {
+			opts = append(opts, jwt.WithIssuer(c.JWT.Issuer))
+		}
+		if c.JWT.Audience != "" {
+			opts = append(opts, jwt.WithAudience(c.JWT.Audience))
+		}
+		a.parser = jwt.NewParser(opts...)
+	}
+	for _, k := range c.APIKeys {
+		if k.Key == "" || k.Subject == "" {
+			return nil, fmt.Errorf("%w: subject %q", ErrMalformedAPIKey, k.Subject)
+		}
+		a.keys = append(a.keys, apiKey{digest: sha256.Sum256([]byte(k.Key)), APIKey: k})
+	}
+	return a, nil
+}
+
+func (a *Authenticator) Handler(next http.HandlerFunc) http.HandlerFunc {
+	if !a.config.Enabled {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.Authenticate(r)
+		if err != nil {
+			a.logger.InfoContext(r.Context(), "authenticate", slog.Any("error", err))
+			a.challenge(w)
+			return
+		}
+		next(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+	}
+}
+
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	if key := r.Header.Get(HeaderAPIKey); key != "" {
+		return a.apiKey(key)
+	}
+	header := r.Header.Get(HeaderAuthorization)
+	if header == "" {
+		return Principal{}, fmt.Errorf("%w", ErrMissingCredentials)
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return Principal{}, fmt.Errorf("%w: unsupported authorization scheme", ErrInvalidToken)
+	}
+	return a.token(token)
+}
+
+func (a *Authenticator) apiKey(key string) (Principal, error) {
+	digest := sha256.Sum256([]byte(key))
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare(digest[:], k.digest[:]) == 1 {
+			return a.principal(k.Subject, k.Roles), nil
+		}
+	}
+	return Principal{}, fmt.Errorf("%w", ErrInvalidAPIKey)
+}
+
+func (a *Authenticator) token(raw string) (Principal, error) {
+	if a.parser == nil {
+		return Principal{}, fmt.Errorf("%w: jwt authentication is disabled", ErrInvalidToken)
+	}
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(raw, claims, a.keyfunc); err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return Principal{}, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+	return a.principal(subject, roles(claims[a.config.JWT.RolesClaim])), nil
+}
+
+func (a *Authenticator) principal(subject string, roles []string) Principal {
+	return Principal{
+		Subject: subject,
+		Roles:   roles,
+		Admin:   a.config.AdminRole != "" && slices.Contains(roles, a.config.AdminRole),
+	}
+}
+
+func (a *Authenticator) challenge(w http.ResponseWriter) {
+	if a.parser != nil {
+		w.Header().Set(HeaderChallenge, "Bearer")
+	}
+	d, err := a.encoder.Marshal(domain.ErrUnauthorized)
+	if err != nil {
+		http.Error(w, domain.ErrUnauthorized.Message, domain.ErrUnauthorized.Code)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(domain.ErrUnauthorized.Code)
+	w.Write(d)
+}
+
+func roles(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		roles := make([]string, 0, len(v))
+		for _, r := range v {
+			if s, ok := r.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+		return roles
+	default:
+		return nil
+	}
+}
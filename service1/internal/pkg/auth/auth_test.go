@@ -0,0 +1,316 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"service1/internal/domain"
+	"service1/internal/pkg/json/standartjson"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var discard = slog.New(slog.DiscardHandler)
+
+const secret = "0123456789abcdef0123456789abcdef"
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	require.NoError(t, err)
+	return s
+}
+
+func claims(subject string, roles ...any) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   subject,
+		"iss":   "taskmaster",
+		"aud":   "service1",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": roles,
+	}
+}
+
+func writeJWKS(t *testing.T, kid string, key *rsa.PublicKey) string {
+	t.Helper()
+	set := map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func Test_New_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name   string
+		config Config
+		err    error
+	}{
+		{
+			name:   "disabled",
+			config: Config{JWT: JWT{Algorithm: "none"}},
+			err:    nil,
+		},
+		{
+			name:   "api keys only",
+			config: Config{Enabled: true, APIKeys: []APIKey{{Key: "k", Subject: "alice"}}},
+			err:    nil,
+		},
+		{
+			name:   "hs256",
+			config: Config{Enabled: true, JWT: JWT{Algorithm: AlgorithmHS256, Secret: secret}},
+			err:    nil,
+		},
+		{
+			name:   "hs256 without secret",
+			config: Config{Enabled: true, JWT: JWT{Algorithm: AlgorithmHS256}},
+			err:    ErrMissingSecret,
+		},
+		{
+			name:   "hs256 placeholder secret",
+			config: Config{Enabled: true, JWT: JWT{Algorithm: AlgorithmHS256, Secret: "change-me"}},
+			err:    ErrWeakSecret,
+		},
+		{
+			name:   "hs256 short secret",
+			config: Config{Enabled: true, JWT: JWT{Algorithm: AlgorithmHS256, Secret: "0123456789abcdef"}},
+			err:    ErrWeakSecret,
+		},
+		{
+			name:   "rs256 missing jwks",
+			config: Config{Enabled: true, JWT: JWT{Algorithm: AlgorithmRS256, JWKSPath: "missing.json"}},
+			err:    ErrLoadingJWKS,
+		},
+		{
+			name:   "unknown algorithm",
+			config: Config{Enabled: true, JWT: JWT{Algorithm: "none"}},
+			err:    ErrUnknownAlgorithm,
+		},
+		{
+			name:   "api key without subject",
+			config: Config{Enabled: true, APIKeys: []APIKey{{Key: "k"}}},
+			err:    ErrMalformedAPIKey,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			_, err := New(cs.config, standartjson.New(), discard)
+			assert.ErrorIs(t, err, cs.err)
+		})
+	}
+}
+
+func Test_Authenticate_Unit(t *testing.T) {
+	t.Parallel()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	hs, err := New(Config{
+		Enabled:   true,
+		AdminRole: "admin",
+		JWT:       JWT{Algorithm: AlgorithmHS256, Secret: secret, Issuer: "taskmaster", Audience: "service1"},
+		APIKeys:   []APIKey{{Key: "alice-key", Subject: "alice"}, {Key: "root-key", Subject: "root", Roles: []string{"admin"}}},
+	}, standartjson.New(), discard)
+	require.NoError(t, err)
+	rs, err := New(Config{
+		Enabled:   true,
+		AdminRole: "admin",
+		JWT:       JWT{Algorithm: AlgorithmRS256, JWKSPath: writeJWKS(t, "k1", &rsaKey.PublicKey)},
+	}, standartjson.New(), discard)
+	require.NoError(t, err)
+
+	cases := []struct {
+		name   string
+		auth   *Authenticator
+		header map[string]string
+		result Principal
+		err    error
+	}{
+		{
+			name:   "hs256 token",
+			auth:   hs,
+			header: map[string]string{HeaderAuthorization: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(secret), "", claims("alice", "user"))},
+			result: Principal{Subject: "alice", Roles: []string{"user"}},
+			err:    nil,
+		},
+		{
+			name:   "hs256 admin token",
+			auth:   hs,
+			header: map[string]string{HeaderAuthorization: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(secret), "", claims("root", "admin"))},
+			result: Principal{Subject: "root", Roles: []string{"admin"}, Admin: true},
+			err:    nil,
+		},
+		{
+			name:   "wrong secret",
+			auth:   hs,
+			header: map[string]string{HeaderAuthorization: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte("other"), "", claims("alice"))},
+			err:    ErrInvalidToken,
+		},
+		{
+			name: "expired token",
+			auth: hs,
+			header: map[string]string{HeaderAuthorization: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(secret), "", jwt.MapClaims{
+				"sub": "alice", "iss": "taskmaster", "aud": "service1", "exp": time.Now().Add(-time.Hour).Unix(),
+			})},
+			err: ErrInvalidToken,
+		},
+		{
+			name: "wrong audience",
+			auth: hs,
+			header: map[string]string{HeaderAuthorization: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(secret), "", jwt.MapClaims{
+				"sub": "alice", "iss": "taskmaster", "aud": "service2", "exp": time.Now().Add(time.Hour).Unix(),
+			})},
+			err: ErrInvalidToken,
+		},
+		{
+			name:   "algorithm mismatch",
+			auth:   hs,
+			header: map[string]string{HeaderAuthorization: "Bearer " + sign(t, jwt.SigningMethodRS256, rsaKey, "k1", claims("alice"))},
+			err:    ErrInvalidToken,
+		},
+		{
+			name:   "rs256 token",
+			auth:   rs,
+			header: map[string]string{HeaderAuthorization: "Bearer " + sign(t, jwt.SigningMethodRS256, rsaKey, "k1", claims("bob", "admin"))},
+			result: Principal{Subject: "bob", Roles: []string{"admin"}, Admin: true},
+			err:    nil,
+		},
+		{
+			name:   "rs256 without kid",
+			auth:   rs,
+			header: map[string]string{HeaderAuthorization: "Bearer " + sign(t, jwt.SigningMethodRS256, rsaKey, "", claims("bob"))},
+			result: Principal{Subject: "bob"},
+			err:    nil,
+		},
+		{
+			name:   "rs256 unknown kid",
+			auth:   rs,
+			header: map[string]string{HeaderAuthorization: "Bearer " + sign(t, jwt.SigningMethodRS256, rsaKey, "k2", claims("bob"))},
+			err:    ErrInvalidToken,
+		},
+		{
+			name:   "rs256 foreign key",
+			auth:   rs,
+			header: map[string]string{HeaderAuthorization: "Bearer " + sign(t, jwt.SigningMethodRS256, otherKey, "k1", claims("bob"))},
+			err:    ErrInvalidToken,
+		},
+		{
+			name:   "api key",
+			auth:   hs,
+			header: map[string]string{HeaderAPIKey: "alice-key"},
+			result: Principal{Subject: "alice"},
+			err:    nil,
+		},
+		{
+			name:   "admin api key",
+			auth:   hs,
+			header: map[string]string{HeaderAPIKey: "root-key"},
+			result: Principal{Subject: "root", Roles: []string{"admin"}, Admin: true},
+			err:    nil,
+		},
+		{
+			name:   "unknown api key",
+			auth:   hs,
+			header: map[string]string{HeaderAPIKey: "nope"},
+			err:    ErrInvalidAPIKey,
+		},
+		{
+			name:   "basic scheme",
+			auth:   hs,
+			header: map[string]string{HeaderAuthorization: "Basic YWxpY2U6cGFzcw=="},
+			err:    ErrInvalidToken,
+		},
+		{
+			name:   "no credentials",
+			auth:   hs,
+			header: map[string]string{},
+			err:    ErrMissingCredentials,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/list", nil)
+			for k, v := range cs.header {
+				r.Header.Set(k, v)
+			}
+			principal, err := cs.auth.Authenticate(r)
+			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.result, principal)
+		})
+	}
+}
+
+func Test_Handler_Unit(t *testing.T) {
+	t.Parallel()
+	a, err := New(Config{
+		Enabled: true,
+		JWT:     JWT{Algorithm: AlgorithmHS256, Secret: secret},
+		APIKeys: []APIKey{{Key: "alice-key", Subject: "alice"}},
+	}, standartjson.New(), discard)
+	require.NoError(t, err)
+	var subject string
+	next := func(w http.ResponseWriter, r *http.Request) {
+		subject = Subject(r.Context())
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/list", nil)
+	a.Handler(next)(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "Bearer", w.Header().Get(HeaderChallenge))
+	var body domain.Error
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, domain.ErrUnauthorized, body)
+	assert.Empty(t, subject)
+
+	w = httptest.NewRecorder()
+	r.Header.Set(HeaderAPIKey, "alice-key")
+	a.Handler(next)(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "alice", subject)
+
+	disabled, err := New(Config{}, standartjson.New(), discard)
+	require.NoError(t, err)
+	subject = "unset"
+	disabled.Handler(next)(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/list", nil))
+	assert.Empty(t, subject)
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+}
+
+func Test_APIKeys_SetValue_Unit(t *testing.T) {
+	t.Parallel()
+	var keys APIKeys
+	require.NoError(t, keys.SetValue(`[{"key":"k","subject":"alice","roles":["admin"]}]`))
+	assert.Equal(t, APIKeys{{Key: "k", Subject: "alice", Roles: []string{"admin"}}}, keys)
+	require.NoError(t, keys.SetValue(""))
+	assert.Nil(t, keys)
+	assert.Error(t, keys.SetValue("k:alice"))
+}
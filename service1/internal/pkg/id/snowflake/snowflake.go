@@ -0,0 +1,76 @@
+package snowflake
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidNode    = errors.New("snowflake: node id out of range")
+	ErrClockBackwards = errors.New("snowflake: clock moved backwards")
+)
+
+const (
+	nodeBits     = 10
+	sequenceBits = 12
+
+	MaxNode     = 1<<nodeBits - 1
+	maxSequence = 1<<sequenceBits - 1
+)
+
+var epoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+type Config struct {
+	Node int64 `yaml:"node"`
+}
+
+type Generator struct {
+	mu       sync.Mutex
+	node     int64
+	last     int64
+	sequence int64
+
+	now func() time.Time
+}
+
+func New(c Config) (*Generator, error) {
+	if c.Node < 0 || c.Node > MaxNode {
+		return nil, fmt.Errorf("%w: %d not in [0, %d]", ErrInvalidNode, c.Node, MaxNode)
+	}
+	return &Generator{
+		node: c.Node,
+		now:  time.Now,
+	}, nil
+}
+
+func (g *Generator) Gen() (string, error) {
+	id, err := g.next()
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(id, 10), nil
+}
+
+func (g *Generator) next() (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ms := g.now().Sub(epoch).Milliseconds()
+	if ms < g.last {
+		return 0, fmt.Errorf("%w: by %dms", ErrClockBackwards, g.last-ms)
+	}
+	if ms == g.last {
+		g.sequence = (g.sequence + 1) & maxSequence
+		if g.sequence == 0 {
+			for ms <= g.last {
+				ms = g.now().Sub(epoch).Milliseconds()
+			}
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.last = ms
+	return ms<<(nodeBits+sequenceBits) | g.node<<sequenceBits | g.sequence, nil
+}
@@ -0,0 +1,39 @@
+package snowflake
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_New_Unit(t *testing.T) {
+	t.Parallel()
+	_, err := New(Config{Node: MaxNode + 1})
+	assert.ErrorIs(t, err, ErrInvalidNode)
+	_, err = New(Config{Node: -1})
+	assert.ErrorIs(t, err, ErrInvalidNode)
+}
+
+func Test_Gen_Unit(t *testing.T) {
+	t.Parallel()
+	g, err := New(Config{Node: 5})
+	require.NoError(t, err)
+	now := epoch.Add(time.Hour)
+	g.now = func() time.Time { return now }
+	var last int64
+	for i := 0; i < maxSequence; i++ {
+		raw, err := g.Gen()
+		require.NoError(t, err)
+		id, err := strconv.ParseInt(raw, 10, 64)
+		require.NoError(t, err)
+		assert.Greater(t, id, last)
+		assert.Equal(t, int64(5), id>>sequenceBits&MaxNode)
+		last = id
+	}
+	now = now.Add(-time.Millisecond)
+	_, err = g.Gen()
+	assert.ErrorIs(t, err, ErrClockBackwards)
+}
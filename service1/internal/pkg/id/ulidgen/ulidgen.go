@@ -0,0 +1,77 @@
+package ulidgen
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrGenerating = errors.New("ulidgen: failed to generate")
+	ErrOverflow   = errors.New("ulidgen: too many ids in one millisecond")
+)
+
+const alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+type Generator struct {
+	mu      sync.Mutex
+	last    uint64
+	entropy [10]byte
+
+	now func() time.Time
+}
+
+func New() *Generator {
+	return &Generator{
+		now: time.Now,
+	}
+}
+
+func (g *Generator) Gen() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ms := uint64(g.now().UnixMilli())
+	if ms <= g.last {
+		ms = g.last
+		if !increment(&g.entropy) {
+			return "", fmt.Errorf("%w", ErrOverflow)
+		}
+	} else if _, err := rand.Read(g.entropy[:]); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrGenerating, err)
+	}
+	g.last = ms
+	return encode(ms, g.entropy), nil
+}
+
+func increment(entropy *[10]byte) bool {
+	for i := len(entropy) - 1; i >= 0; i-- {
+		entropy[i]++
+		if entropy[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func encode(ms uint64, entropy [10]byte) string {
+	var id [26]byte
+	for i := 9; i >= 0; i-- {
+		id[i] = alphabet[ms&0x1f]
+		ms >>= 5
+	}
+	var hi, lo uint64
+	for _, b := range entropy[:2] {
+		hi = hi<<8 | uint64(b)
+	}
+	for _, b := range entropy[2:] {
+		lo = lo<<8 | uint64(b)
+	}
+	for i := 25; i >= 10; i-- {
+		id[i] = alphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(id[:])
+}
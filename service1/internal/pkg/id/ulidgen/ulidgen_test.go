@@ -0,0 +1,33 @@
+package ulidgen
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Gen_Unit(t *testing.T) {
+	t.Parallel()
+	g := New()
+	now := time.UnixMilli(1469918176385)
+	g.now = func() time.Time { return now }
+	first, err := g.Gen()
+	require.NoError(t, err)
+	assert.Len(t, first, 26)
+	assert.Equal(t, "01ARYZ6S41", first[:10])
+	second, err := g.Gen()
+	require.NoError(t, err)
+	assert.Greater(t, second, first)
+}
+
+func Test_encode_Unit(t *testing.T) {
+	t.Parallel()
+	var entropy [10]byte
+	for i := range entropy {
+		entropy[i] = 0xff
+	}
+	assert.Equal(t, "7ZZZZZZZZZZZZZZZZZZZZZZZZZ", encode(1<<48-1, entropy))
+	assert.False(t, increment(&entropy))
+}
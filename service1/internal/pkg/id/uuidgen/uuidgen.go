@@ -2,6 +2,7 @@ package uuidgen
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/google/uuid"
 )
@@ -16,6 +17,10 @@ func New() *Generator {
 	return &Generator{}
 }
 
-func (g *Generator) Gen() (int, error) {
-	return int(uuid.New().ID()), nil
+func (g *Generator) Gen() (string, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrGenerating, err)
+	}
+	return id.String(), nil
 }
@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_New_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name   string
+		config Config
+		err    error
+	}{
+		{
+			name:   "json",
+			config: Config{Level: "info", Format: FormatJSON},
+			err:    nil,
+		},
+		{
+			name:   "text",
+			config: Config{Level: "debug", Format: FormatText},
+			err:    nil,
+		},
+		{
+			name:   "unknown level",
+			config: Config{Level: "loud", Format: FormatJSON},
+			err:    ErrUnknownLevel,
+		},
+		{
+			name:   "unknown format",
+			config: Config{Level: "info", Format: "xml"},
+			err:    ErrUnknownFormat,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			_, err := New(cs.config, &bytes.Buffer{})
+			assert.ErrorIs(t, err, cs.err)
+		})
+	}
+}
+
+func Test_Middleware_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{
+			name:     "generated",
+			incoming: "",
+			keep:     false,
+		},
+		{
+			name:     "propagated",
+			incoming: "req-42",
+			keep:     true,
+		},
+		{
+			name:     "malformed",
+			incoming: "bad id",
+			keep:     false,
+		},
+		{
+			name:     "too long",
+			incoming: strings.Repeat("a", maxRequestID+1),
+			keep:     false,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l, err := New(Config{Level: "info", Format: FormatJSON}, &buf)
+			require.NoError(t, err)
+			var seen string
+			h := Middleware(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestID(r.Context())
+				w.WriteHeader(http.StatusTeapot)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/list", nil)
+			if cs.incoming != "" {
+				req.Header.Set(HeaderRequestID, cs.incoming)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			require.NotEmpty(t, seen)
+			assert.Equal(t, seen, rec.Header().Get(HeaderRequestID))
+			if cs.keep {
+				assert.Equal(t, cs.incoming, seen)
+			} else {
+				assert.NotEqual(t, cs.incoming, seen)
+			}
+			assert.Contains(t, buf.String(), `"request_id":"`+seen+`"`)
+			assert.Contains(t, buf.String(), `"code":418`)
+		})
+	}
+}
+
+func Test_RequestID_Unit(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "", RequestID(context.Background()))
+	assert.Equal(t, "id", RequestID(WithRequestID(context.Background(), "id")))
+	assert.Equal(t, "", RequestID(WithRequestID(context.Background(), "")))
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+)
+
+type WriterStatser interface {
+	Stats() kafka.WriterStats
+}
+
+type ReaderStatser interface {
+	Stats() kafka.ReaderStats
+}
+
+type WriterCollector struct {
+	mu     sync.Mutex
+	writer WriterStatser
+
+	messages, bytes, errors, retries, writes float64
+
+	messagesDesc, bytesDesc, errorsDesc, retriesDesc, writesDesc, writeTimeDesc *prometheus.Desc
+}
+
+func NewWriterCollector(name string, w WriterStatser) *WriterCollector {
+	labels := prometheus.Labels{"writer": name}
+	desc := func(n, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "kafka_writer", n), help, nil, labels)
+	}
+	return &WriterCollector{
+		writer:        w,
+		messagesDesc:  desc("messages_total", "Messages written."),
+		bytesDesc:     desc("bytes_total", "Bytes written."),
+		errorsDesc:    desc("errors_total", "Write errors."),
+		retriesDesc:   desc("retries_total", "Write retries."),
+		writesDesc:    desc("writes_total", "Write calls."),
+		writeTimeDesc: desc("write_time_seconds", "Average write time since the last scrape."),
+	}
+}
+
+func (c *WriterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.messagesDesc
+	ch <- c.bytesDesc
+	ch <- c.errorsDesc
+	ch <- c.retriesDesc
+	ch <- c.writesDesc
+	ch <- c.writeTimeDesc
+}
+
+func (c *WriterCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.writer.Stats()
+	c.messages += float64(s.Messages)
+	c.bytes += float64(s.Bytes)
+	c.errors += float64(s.Errors)
+	c.retries += float64(s.Retries)
+	c.writes += float64(s.Writes)
+	ch <- prometheus.MustNewConstMetric(c.messagesDesc, prometheus.CounterValue, c.messages)
+	ch <- prometheus.MustNewConstMetric(c.bytesDesc, prometheus.CounterValue, c.bytes)
+	ch <- prometheus.MustNewConstMetric(c.errorsDesc, prometheus.CounterValue, c.errors)
+	ch <- prometheus.MustNewConstMetric(c.retriesDesc, prometheus.CounterValue, c.retries)
+	ch <- prometheus.MustNewConstMetric(c.writesDesc, prometheus.CounterValue, c.writes)
+	ch <- prometheus.MustNewConstMetric(c.writeTimeDesc, prometheus.GaugeValue, s.WriteTime.Avg.Seconds())
+}
+
+type ReaderCollector struct {
+	mu     sync.Mutex
+	reader ReaderStatser
+
+	messages, bytes, errors, fetches, rebalances float64
+
+	messagesDesc, bytesDesc, errorsDesc, fetchesDesc, rebalancesDesc, lagDesc, queueDesc *prometheus.Desc
+}
+
+func NewReaderCollector(name string, r ReaderStatser) *ReaderCollector {
+	labels := prometheus.Labels{"reader": name}
+	desc := func(n, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "kafka_reader", n), help, nil, labels)
+	}
+	return &ReaderCollector{
+		reader:         r,
+		messagesDesc:   desc("messages_total", "Messages read."),
+		bytesDesc:      desc("bytes_total", "Bytes read."),
+		errorsDesc:     desc("errors_total", "Read errors."),
+		fetchesDesc:    desc("fetches_total", "Fetch calls."),
+		rebalancesDesc: desc("rebalances_total", "Consumer group rebalances."),
+		lagDesc:        desc("lag", "Consumer lag in messages."),
+		queueDesc:      desc("queue_length", "Messages fetched but not yet handed out."),
+	}
+}
+
+func (c *ReaderCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.messagesDesc
+	ch <- c.bytesDesc
+	ch <- c.errorsDesc
+	ch <- c.fetchesDesc
+	ch <- c.rebalancesDesc
+	ch <- c.lagDesc
+	ch <- c.queueDesc
+}
+
+func (c *ReaderCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.reader.Stats()
+	c.messages += float64(s.Messages)
+	c.bytes += float64(s.Bytes)
+	c.errors += float64(s.Errors)
+	c.fetches += float64(s.Fetches)
+	c.rebalances += float64(s.Rebalances)
+	ch <- prometheus.MustNewConstMetric(c.messagesDesc, prometheus.CounterValue, c.messages)
+	ch <- prometheus.MustNewConstMetric(c.bytesDesc, prometheus.CounterValue, c.bytes)
+	ch <- prometheus.MustNewConstMetric(c.errorsDesc, prometheus.CounterValue, c.errors)
+	ch <- prometheus.MustNewConstMetric(c.fetchesDesc, prometheus.CounterValue, c.fetches)
+	ch <- prometheus.MustNewConstMetric(c.rebalancesDesc, prometheus.CounterValue, c.rebalances)
+	ch <- prometheus.MustNewConstMetric(c.lagDesc, prometheus.GaugeValue, float64(s.Lag))
+	ch <- prometheus.MustNewConstMetric(c.queueDesc, prometheus.GaugeValue, float64(s.QueueLength))
+}
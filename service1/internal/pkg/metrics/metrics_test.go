@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"service1/internal/domain"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockWriter struct {
+	stats kafka.WriterStats
+}
+
+func (m *mockWriter) Stats() kafka.WriterStats {
+	return m.stats
+}
+
+type mockCounter struct {
+	counts map[domain.Status]int
+	err    error
+}
+
+func (m *mockCounter) CountTasks(ctx context.Context) (map[domain.Status]int, error) {
+	return m.counts, m.err
+}
+
+func Test_Instrument_Unit(t *testing.T) {
+	t.Parallel()
+	m := New()
+	h := m.Instrument("/create", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	})
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/create", nil))
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/create", nil))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.requests.WithLabelValues("/create", http.MethodPost, "409")))
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `taskmaster_http_request_duration_seconds_count{method="POST",route="/create"} 2`)
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	m.Instrument("PATCH /tasks/{id}", ok)(httptest.NewRecorder(), httptest.NewRequest(http.MethodPatch, "/tasks/1", nil))
+	m.Instrument("DELETE /tasks/{id}", ok)(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/tasks/1", nil))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues("PATCH /tasks/{id}", http.MethodPatch, "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues("DELETE /tasks/{id}", http.MethodDelete, "200")))
+}
+
+func Test_WriterCollector_Unit(t *testing.T) {
+	t.Parallel()
+	w := &mockWriter{stats: kafka.WriterStats{Messages: 3}}
+	c := NewWriterCollector("tasks", w)
+	expected := `
+		# HELP taskmaster_kafka_writer_messages_total Messages written.
+		# TYPE taskmaster_kafka_writer_messages_total counter
+		taskmaster_kafka_writer_messages_total{writer="tasks"} 3
+	`
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected), "taskmaster_kafka_writer_messages_total"))
+	expected = strings.Replace(expected, "} 3", "} 6", 1)
+	assert.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected), "taskmaster_kafka_writer_messages_total"))
+}
+
+func Test_TaskCollector_Unit(t *testing.T) {
+	t.Parallel()
+	c := NewTaskCollector(&mockCounter{counts: map[domain.Status]int{domain.StatusNew: 2, domain.StatusFailed: 1}}, 0, slog.New(slog.DiscardHandler))
+	expected := `
+		# HELP taskmaster_tasks Tasks currently stored, by status.
+		# TYPE taskmaster_tasks gauge
+		taskmaster_tasks{status="completed"} 0
+		taskmaster_tasks{status="failed"} 1
+		taskmaster_tasks{status="new"} 2
+		taskmaster_tasks{status="pending"} 0
+		taskmaster_tasks{status="processing"} 0
+	`
+	assert.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected)))
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"service1/internal/domain"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type TaskCounter interface {
+	CountTasks(ctx context.Context) (map[domain.Status]int, error)
+}
+
+type TaskCollector struct {
+	counter TaskCounter
+	timeout time.Duration
+	logger  *slog.Logger
+
+	tasksDesc *prometheus.Desc
+}
+
+func NewTaskCollector(c TaskCounter, timeout time.Duration, logger *slog.Logger) *TaskCollector {
+	return &TaskCollector{
+		counter: c,
+		timeout: timeout,
+		logger:  logger,
+		tasksDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "tasks"),
+			"Tasks currently stored, by status.",
+			[]string{"status"}, nil,
+		),
+	}
+}
+
+func (c *TaskCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.tasksDesc
+}
+
+func (c *TaskCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	counts, err := c.counter.CountTasks(ctx)
+	if err != nil {
+		c.logger.ErrorContext(ctx, "count tasks", slog.Any("error", err))
+		return
+	}
+	for _, status := range []domain.Status{domain.StatusNew, domain.StatusPending, domain.StatusProcessing, domain.StatusCompleted, domain.StatusFailed} {
+		ch <- prometheus.MustNewConstMetric(c.tasksDesc, prometheus.GaugeValue, float64(counts[status]), string(status))
+	}
+}
@@ -0,0 +1,182 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"service1/internal/domain"
+	"service1/internal/pkg/auth"
+)
+
+const (
+	HeaderRetryAfter   = "Retry-After"
+	HeaderForwardedFor = "X-Forwarded-For"
+)
+
+var ErrInvalidLimit = errors.New("ratelimit: rate and burst must be positive")
+
+type Config struct {
+	Enabled    bool             `yaml:"enabled"`
+	TrustProxy bool             `yaml:"trust_proxy"`
+	IdleTTL    time.Duration    `yaml:"idle_ttl"`
+	PerIP      Limit            `yaml:"per_ip"`
+	Routes     map[string]Limit `yaml:"routes"`
+}
+
+type Limit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+type Encoder interface {
+	Marshal(data any) ([]byte, error)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type Limiter struct {
+	config Config
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+	now     func() time.Time
+
+	encoder Encoder
+	logger  *slog.Logger
+}
+
+func New(c Config, encoder Encoder, logger *slog.Logger) (*Limiter, error) {
+	if c.PerIP != (Limit{}) && (c.PerIP.Rate <= 0 || c.PerIP.Burst < 1) {
+		return nil, fmt.Errorf("%w: per ip", ErrInvalidLimit)
+	}
+	for route, limit := range c.Routes {
+		if limit.Rate <= 0 || limit.Burst < 1 {
+			return nil, fmt.Errorf("%w: route %q", ErrInvalidLimit, route)
+		}
+	}
+	return &Limiter{
+		config:  c,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+		encoder: encoder,
+		logger:  logger,
+	}, nil
+}
+
+func (l *Limiter) Handler(route string, next http.HandlerFunc) http.HandlerFunc {
+	limit, ok := l.config.Routes[route]
+	if !l.config.Enabled || !ok {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := l.key(r)
+		allowed, wait := l.Allow(route+"|"+key, limit)
+		if !allowed {
+			l.logger.InfoContext(r.Context(), "rate limited", slog.String("route", route), slog.String("client", key))
+			l.reject(w, wait)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// IPHandler limits requests by client address before they reach
+// authentication, so floods of bad credentials are throttled too.
+func (l *Limiter) IPHandler(next http.HandlerFunc) http.HandlerFunc {
+	if !l.config.Enabled || l.config.PerIP == (Limit{}) {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := l.clientIP(r)
+		allowed, wait := l.Allow("ip|"+ip, l.config.PerIP)
+		if !allowed {
+			l.logger.InfoContext(r.Context(), "rate limited", slog.String("client", "ip:"+ip))
+			l.reject(w, wait)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (l *Limiter) Allow(key string, limit Limit) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	if l.config.IdleTTL <= 0 || now.Sub(l.swept) < l.config.IdleTTL {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.config.IdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+	l.swept = now
+}
+
+func (l *Limiter) key(r *http.Request) string {
+	if subject := auth.Subject(r.Context()); subject != "" {
+		return "subject:" + subject
+	}
+	return "ip:" + l.clientIP(r)
+}
+
+// clientIP takes the rightmost X-Forwarded-For entry, the one the trusted
+// proxy appended; anything left of it is whatever the client chose to send.
+func (l *Limiter) clientIP(r *http.Request) string {
+	if l.config.TrustProxy {
+		if values := r.Header.Values(HeaderForwardedFor); len(values) > 0 {
+			forwarded := values[len(values)-1]
+			if client := strings.TrimSpace(forwarded[strings.LastIndex(forwarded, ",")+1:]); client != "" {
+				return client
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (l *Limiter) reject(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set(HeaderRetryAfter, strconv.Itoa(max(1, int(math.Ceil(wait.Seconds())))))
+	d, err := l.encoder.Marshal(domain.ErrTooManyRequests)
+	if err != nil {
+		http.Error(w, domain.ErrTooManyRequests.Message, domain.ErrTooManyRequests.Code)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(domain.ErrTooManyRequests.Code)
+	w.Write(d)
+}
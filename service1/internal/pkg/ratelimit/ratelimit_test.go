@@ -0,0 +1,219 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"service1/internal/domain"
+	"service1/internal/pkg/auth"
+	"service1/internal/pkg/json/standartjson"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var discard = slog.New(slog.DiscardHandler)
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func Test_New_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name   string
+		config Config
+		err    error
+	}{
+		{
+			name:   "success",
+			config: Config{Routes: map[string]Limit{"/create": {Rate: 1, Burst: 1}}},
+			err:    nil,
+		},
+		{
+			name:   "zero rate",
+			config: Config{Routes: map[string]Limit{"/create": {Rate: 0, Burst: 1}}},
+			err:    ErrInvalidLimit,
+		},
+		{
+			name:   "zero burst",
+			config: Config{Routes: map[string]Limit{"/create": {Rate: 1, Burst: 0}}},
+			err:    ErrInvalidLimit,
+		},
+		{
+			name:   "invalid per ip limit",
+			config: Config{PerIP: Limit{Rate: 1}},
+			err:    ErrInvalidLimit,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			_, err := New(cs.config, standartjson.New(), discard)
+			assert.ErrorIs(t, err, cs.err)
+		})
+	}
+}
+
+func Test_Allow_Unit(t *testing.T) {
+	t.Parallel()
+	c := &clock{t: time.Unix(1700000000, 0)}
+	l, err := New(Config{IdleTTL: time.Minute}, standartjson.New(), discard)
+	require.NoError(t, err)
+	l.now = c.now
+	limit := Limit{Rate: 2, Burst: 3}
+
+	for range limit.Burst {
+		allowed, _ := l.Allow("a", limit)
+		assert.True(t, allowed)
+	}
+	allowed, wait := l.Allow("a", limit)
+	assert.False(t, allowed)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	allowed, _ = l.Allow("b", limit)
+	assert.True(t, allowed)
+
+	c.t = c.t.Add(500 * time.Millisecond)
+	allowed, _ = l.Allow("a", limit)
+	assert.True(t, allowed)
+	allowed, _ = l.Allow("a", limit)
+	assert.False(t, allowed)
+
+	c.t = c.t.Add(time.Hour)
+	allowed, _ = l.Allow("c", limit)
+	assert.True(t, allowed)
+	assert.Len(t, l.buckets, 1)
+}
+
+func Test_Handler_Unit(t *testing.T) {
+	t.Parallel()
+	l, err := New(Config{
+		Enabled: true,
+		Routes:  map[string]Limit{"/create": {Rate: 1, Burst: 1}},
+	}, standartjson.New(), discard)
+	require.NoError(t, err)
+	l.now = (&clock{t: time.Unix(1700000000, 0)}).now
+	next := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	handler := l.Handler("/create", next)
+
+	cases := []struct {
+		name   string
+		remote string
+		header string
+		owner  string
+		code   int
+	}{
+		{
+			name:   "first request",
+			remote: "10.0.0.1:1000",
+			code:   http.StatusOK,
+		},
+		{
+			name:   "same ip other port",
+			remote: "10.0.0.1:2000",
+			code:   http.StatusTooManyRequests,
+		},
+		{
+			name:   "other ip",
+			remote: "10.0.0.2:1000",
+			code:   http.StatusOK,
+		},
+		{
+			name:   "forwarded header is ignored",
+			remote: "10.0.0.1:1000",
+			header: "192.168.0.1",
+			code:   http.StatusTooManyRequests,
+		},
+		{
+			name:   "authenticated client",
+			remote: "10.0.0.1:1000",
+			owner:  "alice",
+			code:   http.StatusOK,
+		},
+		{
+			name:   "same client other ip",
+			remote: "10.0.0.3:1000",
+			owner:  "alice",
+			code:   http.StatusTooManyRequests,
+		},
+	}
+	for _, cs := range cases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/create", nil)
+		r.RemoteAddr = cs.remote
+		if cs.header != "" {
+			r.Header.Set(HeaderForwardedFor, cs.header)
+		}
+		if cs.owner != "" {
+			r = r.WithContext(auth.WithPrincipal(r.Context(), auth.Principal{Subject: cs.owner}))
+		}
+		handler(w, r)
+		assert.Equal(t, cs.code, w.Code, cs.name)
+		if cs.code == http.StatusTooManyRequests {
+			assert.Equal(t, "1", w.Header().Get(HeaderRetryAfter), cs.name)
+			var body domain.Error
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, domain.ErrTooManyRequests, body)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/list", nil)
+	r.RemoteAddr = "10.0.0.1:1000"
+	for range 3 {
+		l.Handler("/list", next)(w, r)
+	}
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func Test_key_Unit(t *testing.T) {
+	t.Parallel()
+	l, err := New(Config{TrustProxy: true}, standartjson.New(), discard)
+	require.NoError(t, err)
+	r := httptest.NewRequest(http.MethodGet, "/list", nil)
+	r.RemoteAddr = "10.0.0.1:1000"
+	assert.Equal(t, "ip:10.0.0.1", l.key(r))
+	r.Header.Set(HeaderForwardedFor, "203.0.113.7, 192.168.0.1")
+	assert.Equal(t, "ip:192.168.0.1", l.key(r))
+	r.Header.Add(HeaderForwardedFor, "192.168.0.2")
+	assert.Equal(t, "ip:192.168.0.2", l.key(r))
+	r = r.WithContext(auth.WithPrincipal(r.Context(), auth.Principal{Subject: "alice"}))
+	assert.Equal(t, "subject:alice", l.key(r))
+}
+
+func Test_IPHandler_Unit(t *testing.T) {
+	t.Parallel()
+	l, err := New(Config{Enabled: true, PerIP: Limit{Rate: 1, Burst: 2}}, standartjson.New(), discard)
+	require.NoError(t, err)
+	l.now = (&clock{t: time.Unix(1700000000, 0)}).now
+	unauthorized := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+	handler := l.IPHandler(unauthorized)
+
+	codes := make([]int, 0, 3)
+	for range 3 {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/list", nil)
+		r.RemoteAddr = "10.0.0.1:1000"
+		handler(w, r)
+		codes = append(codes, w.Code)
+	}
+	assert.Equal(t, []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}, codes)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/list", nil)
+	r.RemoteAddr = "10.0.0.2:1000"
+	handler(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
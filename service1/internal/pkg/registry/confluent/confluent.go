@@ -0,0 +1,163 @@
+package confluent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"service1/internal/pkg/registry/serde"
+)
+
+const mediaType = "application/vnd.schemaregistry.v1+json"
+
+var (
+	ErrRequest  = errors.New("confluent: request failed")
+	ErrResponse = errors.New("confluent: unexpected response")
+)
+
+type Config struct {
+	URL string `yaml:"url"`
+}
+
+type Client struct {
+	url    string
+	client *http.Client
+}
+
+type schemaRequest struct {
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType,omitempty"`
+}
+
+type schemaResponse struct {
+	ID         int    `json:"id"`
+	Subject    string `json:"subject"`
+	Version    int    `json:"version"`
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType"`
+}
+
+type compatibilityResponse struct {
+	IsCompatible bool     `json:"is_compatible"`
+	Messages     []string `json:"messages"`
+}
+
+type errorResponse struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+func New(c Config) *Client {
+	return &Client{
+		url:    strings.TrimSuffix(c.URL, "/"),
+		client: &http.Client{},
+	}
+}
+
+func (c *Client) Register(ctx context.Context, subject string, schema serde.Schema) (serde.Schema, error) {
+	var resp schemaResponse
+	if err := c.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", request(schema), &resp); err != nil {
+		return serde.Schema{}, err
+	}
+	schema.ID = resp.ID
+	schema.Subject = subject
+	return schema, nil
+}
+
+func (c *Client) SchemaByID(ctx context.Context, id int) (serde.Schema, error) {
+	var resp schemaResponse
+	if err := c.do(ctx, http.MethodGet, "/schemas/ids/"+strconv.Itoa(id), nil, &resp); err != nil {
+		return serde.Schema{}, err
+	}
+	return serde.Schema{ID: id, Type: schemaType(resp.SchemaType), Schema: resp.Schema}, nil
+}
+
+func (c *Client) Latest(ctx context.Context, subject string) (serde.Schema, error) {
+	var resp schemaResponse
+	if err := c.do(ctx, http.MethodGet, "/subjects/"+url.PathEscape(subject)+"/versions/latest", nil, &resp); err != nil {
+		return serde.Schema{}, err
+	}
+	return serde.Schema{
+		ID:      resp.ID,
+		Subject: resp.Subject,
+		Version: resp.Version,
+		Type:    schemaType(resp.SchemaType),
+		Schema:  resp.Schema,
+	}, nil
+}
+
+func (c *Client) Compatible(ctx context.Context, subject string, schema serde.Schema) error {
+	var resp compatibilityResponse
+	err := c.do(ctx, http.MethodPost, "/compatibility/subjects/"+url.PathEscape(subject)+"/versions/latest", request(schema), &resp)
+	switch {
+	case errors.Is(err, serde.ErrNotFound):
+		return nil
+	case err != nil:
+		return err
+	case !resp.IsCompatible:
+		return fmt.Errorf("%w: %s", serde.ErrIncompatible, strings.Join(resp.Messages, "; "))
+	}
+	return nil
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, body any, v any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrRequest, err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, reader)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRequest, err)
+	}
+	req.Header.Set("Accept", mediaType)
+	if body != nil {
+		req.Header.Set("Content-Type", mediaType)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v: %v", serde.ErrRegistry, ErrRequest, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e errorResponse
+		json.NewDecoder(resp.Body).Decode(&e)
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return fmt.Errorf("%w: %s", serde.ErrNotFound, e.Message)
+		case http.StatusConflict:
+			return fmt.Errorf("%w: %s", serde.ErrIncompatible, e.Message)
+		default:
+			return fmt.Errorf("%w: %v: status %d: %s", serde.ErrRegistry, ErrResponse, resp.StatusCode, e.Message)
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%w: %v: %v", serde.ErrRegistry, ErrResponse, err)
+	}
+	return nil
+}
+
+func request(schema serde.Schema) schemaRequest {
+	r := schemaRequest{Schema: schema.Schema}
+	if schema.Type != serde.TypeAvro {
+		r.SchemaType = schema.Type
+	}
+	return r
+}
+
+func schemaType(t string) string {
+	if t == "" {
+		return serde.TypeAvro
+	}
+	return t
+}
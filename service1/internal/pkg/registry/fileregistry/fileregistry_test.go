@@ -0,0 +1,178 @@
+package fileregistry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"service1/internal/pkg/registry/serde"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	v1 = `{"type":"record","name":"Task","fields":[{"name":"id","type":"string"}]}`
+	v2 = `{"type":"record","name":"Task","fields":[{"name":"id","type":"string"},{"name":"owner","type":"string","default":""}]}`
+	v3 = `{"type":"record","name":"Task","fields":[{"name":"id","type":"string"},{"name":"owner","type":"string"}]}`
+)
+
+func Test_Register_Unit(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "schemas.json")
+	r, err := New(Config{Path: path, Compatibility: CompatibilityBackward})
+	require.NoError(t, err)
+
+	_, err = r.Latest(ctx, "tasks-value")
+	assert.ErrorIs(t, err, serde.ErrNotFound)
+	assert.NoError(t, r.Compatible(ctx, "tasks-value", serde.Schema{Schema: v3}))
+
+	first, err := r.Register(ctx, "tasks-value", serde.Schema{Type: serde.TypeAvro, Schema: v1})
+	require.NoError(t, err)
+	assert.Equal(t, serde.Schema{ID: 1, Subject: "tasks-value", Version: 1, Type: serde.TypeAvro, Schema: v1}, first)
+
+	again, err := r.Register(ctx, "tasks-value", serde.Schema{Schema: v1})
+	require.NoError(t, err)
+	assert.Equal(t, first, again)
+
+	assert.ErrorIs(t, r.Compatible(ctx, "tasks-value", serde.Schema{Schema: v3}), serde.ErrIncompatible)
+	_, err = r.Register(ctx, "tasks-value", serde.Schema{Schema: v3})
+	assert.ErrorIs(t, err, serde.ErrIncompatible)
+	assert.ErrorIs(t, r.Compatible(ctx, "tasks-value", serde.Schema{Type: serde.TypeProtobuf, Schema: v1}), serde.ErrIncompatible)
+
+	second, err := r.Register(ctx, "tasks-value", serde.Schema{Schema: v2})
+	require.NoError(t, err)
+	assert.Equal(t, 2, second.ID)
+	assert.Equal(t, 2, second.Version)
+
+	other, err := r.Register(ctx, "tasks-status-value", serde.Schema{Type: serde.TypeProtobuf, Schema: `syntax = "proto3";`})
+	require.NoError(t, err)
+	assert.Equal(t, 3, other.ID)
+	assert.Equal(t, 1, other.Version)
+
+	reopened, err := New(Config{Path: path, Compatibility: CompatibilityBackward})
+	require.NoError(t, err)
+	latest, err := reopened.Latest(ctx, "tasks-value")
+	require.NoError(t, err)
+	assert.Equal(t, second, latest)
+	byID, err := reopened.SchemaByID(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, other, byID)
+	_, err = reopened.SchemaByID(ctx, 4)
+	assert.ErrorIs(t, err, serde.ErrNotFound)
+}
+
+func Test_Compatibility_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name          string
+		compatibility string
+		previous      string
+		next          string
+		err           error
+	}{
+		{
+			name:          "backward adds defaulted field",
+			compatibility: CompatibilityBackward,
+			previous:      v1,
+			next:          v2,
+		},
+		{
+			name:          "backward adds required field",
+			compatibility: CompatibilityBackward,
+			previous:      v1,
+			next:          v3,
+			err:           serde.ErrIncompatible,
+		},
+		{
+			name:          "forward drops field",
+			compatibility: CompatibilityForward,
+			previous:      v3,
+			next:          v1,
+			err:           serde.ErrIncompatible,
+		},
+		{
+			name:          "full adds defaulted field",
+			compatibility: CompatibilityFull,
+			previous:      v1,
+			next:          v2,
+		},
+		{
+			name:          "none",
+			compatibility: CompatibilityNone,
+			previous:      v1,
+			next:          v3,
+		},
+		{
+			name:          "invalid schema",
+			compatibility: CompatibilityBackward,
+			previous:      v1,
+			next:          `{`,
+			err:           ErrInvalidSchema,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			t.Parallel()
+			r, err := New(Config{Path: filepath.Join(t.TempDir(), "schemas.json"), Compatibility: cs.compatibility})
+			require.NoError(t, err)
+			_, err = r.Register(context.Background(), "tasks-value", serde.Schema{Schema: cs.previous})
+			require.NoError(t, err)
+			_, err = r.Register(context.Background(), "tasks-value", serde.Schema{Schema: cs.next})
+			assert.ErrorIs(t, err, cs.err)
+		})
+	}
+}
+
+func Test_New_Unit(t *testing.T) {
+	t.Parallel()
+	_, err := New(Config{Path: filepath.Join(t.TempDir(), "schemas.json"), Compatibility: "SIDEWAYS"})
+	assert.ErrorIs(t, err, ErrUnknownCompatibility)
+}
+
+func Test_SharedFile_Unit(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "schemas.json")
+	writer, err := New(Config{Path: path, Compatibility: CompatibilityBackward})
+	require.NoError(t, err)
+	reader, err := New(Config{Path: path, Compatibility: CompatibilityBackward})
+	require.NoError(t, err)
+
+	own, err := reader.Register(ctx, "tasks-status-value", serde.Schema{Schema: v1})
+	require.NoError(t, err)
+	assert.Equal(t, 1, own.ID)
+	registered, err := writer.Register(ctx, "tasks-value", serde.Schema{Schema: v2})
+	require.NoError(t, err)
+	assert.Equal(t, 2, registered.ID)
+
+	resolved, err := reader.SchemaByID(ctx, registered.ID)
+	require.NoError(t, err)
+	assert.Equal(t, registered, resolved)
+	latest, err := reader.Latest(ctx, "tasks-value")
+	require.NoError(t, err)
+	assert.Equal(t, registered, latest)
+	_, err = os.Stat(path + ".lock")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func Test_Lock_Unit(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "schemas.json")
+	r, err := New(Config{Path: path, Compatibility: CompatibilityNone})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path+".lock", nil, 0o644))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = r.Register(ctx, "tasks-value", serde.Schema{Schema: v1})
+	assert.ErrorIs(t, err, ErrLocking)
+
+	stale := time.Now().Add(-2 * staleLock)
+	require.NoError(t, os.Chtimes(path+".lock", stale, stale))
+	_, err = r.Register(context.Background(), "tasks-value", serde.Schema{Schema: v1})
+	assert.NoError(t, err)
+}
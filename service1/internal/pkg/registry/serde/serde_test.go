@@ -0,0 +1,203 @@
+package serde
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockRegistry struct {
+	schemas map[int]Schema
+	compErr error
+	regErr  error
+	lookups int
+}
+
+func (m *mockRegistry) Register(ctx context.Context, subject string, schema Schema) (Schema, error) {
+	if m.regErr != nil {
+		return Schema{}, m.regErr
+	}
+	schema.ID = len(m.schemas) + 1
+	schema.Subject = subject
+	m.schemas[schema.ID] = schema
+	return schema, nil
+}
+
+func (m *mockRegistry) SchemaByID(ctx context.Context, id int) (Schema, error) {
+	m.lookups++
+	schema, ok := m.schemas[id]
+	if !ok {
+		return Schema{}, ErrNotFound
+	}
+	return schema, nil
+}
+
+func (m *mockRegistry) Latest(ctx context.Context, subject string) (Schema, error) {
+	return Schema{}, ErrNotFound
+}
+
+func (m *mockRegistry) Compatible(ctx context.Context, subject string, schema Schema) error {
+	return m.compErr
+}
+
+type mockCodec struct{}
+
+func (m *mockCodec) Marshal(data any) ([]byte, error) {
+	return []byte(data.(string)), nil
+}
+
+func (m *mockCodec) Unmarshal(data []byte, v any) error {
+	*v.(*string) = string(data)
+	return nil
+}
+
+func (m *mockCodec) ContentType() string {
+	return "application/avro"
+}
+
+type mockResolvingCodec struct {
+	mockCodec
+	writer string
+}
+
+func (m *mockResolvingCodec) UnmarshalResolved(writer string, data []byte, v any) error {
+	m.writer = writer
+	return m.Unmarshal(data, v)
+}
+
+func Test_Wire_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		id       int
+		indexes  []int
+		protobuf bool
+		header   []byte
+	}{
+		{
+			name:   "avro",
+			id:     258,
+			header: []byte{0x0, 0x0, 0x0, 0x1, 0x2},
+		},
+		{
+			name:     "protobuf first message",
+			id:       1,
+			indexes:  []int{0},
+			protobuf: true,
+			header:   []byte{0x0, 0x0, 0x0, 0x0, 0x1, 0x0},
+		},
+		{
+			name:     "protobuf nested message",
+			id:       1,
+			indexes:  []int{2},
+			protobuf: true,
+			header:   []byte{0x0, 0x0, 0x0, 0x0, 0x1, 0x2, 0x4},
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			t.Parallel()
+			data := Encode(cs.id, cs.indexes, []byte("payload"))
+			assert.Equal(t, cs.header, data[:len(cs.header)])
+			id, payload, err := Decode(data, cs.protobuf)
+			require.NoError(t, err)
+			assert.Equal(t, cs.id, id)
+			assert.Equal(t, []byte("payload"), payload)
+		})
+	}
+
+	_, _, err := Decode([]byte{0x0, 0x1}, false)
+	assert.ErrorIs(t, err, ErrWireFormat)
+	_, _, err = Decode([]byte{0x1, 0x0, 0x0, 0x0, 0x1}, false)
+	assert.ErrorIs(t, err, ErrWireFormat)
+	_, _, err = Decode([]byte{0x0, 0x0, 0x0, 0x0, 0x1, 0x4}, true)
+	assert.ErrorIs(t, err, ErrWireFormat)
+}
+
+func Test_Serde_Unit(t *testing.T) {
+	t.Parallel()
+	registry := &mockRegistry{schemas: make(map[int]Schema)}
+	s := New(Config{
+		Registry: registry,
+		Codec:    &mockCodec{},
+		Subject:  "tasks-value",
+		Schema:   Schema{Type: TypeAvro, Schema: `"string"`},
+	})
+	assert.Equal(t, "application/avro"+ContentTypeParam, s.ContentType())
+
+	_, err := s.Marshal("event")
+	assert.ErrorIs(t, err, ErrNotRegistered)
+
+	require.NoError(t, s.Register(context.Background()))
+	data, err := s.Marshal("event")
+	require.NoError(t, err)
+	var v string
+	require.NoError(t, s.Unmarshal(data, &v))
+	assert.Equal(t, "event", v)
+	assert.Equal(t, 0, registry.lookups)
+
+	registry.schemas[7] = Schema{ID: 7, Type: TypeAvro}
+	require.NoError(t, s.Unmarshal(Encode(7, nil, []byte("other")), &v))
+	require.NoError(t, s.Unmarshal(Encode(7, nil, []byte("other")), &v))
+	assert.Equal(t, "other", v)
+	assert.Equal(t, 1, registry.lookups)
+
+	registry.schemas[8] = Schema{ID: 8, Type: TypeProtobuf}
+	assert.ErrorIs(t, s.Unmarshal(Encode(8, nil, []byte("other")), &v), ErrSchemaMismatch)
+	assert.ErrorIs(t, s.Unmarshal(Encode(9, nil, []byte("other")), &v), ErrUnmarshaling)
+	assert.ErrorIs(t, s.Unmarshal([]byte("{}"), &v), ErrUnmarshaling)
+}
+
+func Test_Serde_Resolve_Unit(t *testing.T) {
+	t.Parallel()
+	registry := &mockRegistry{schemas: make(map[int]Schema)}
+	codec := &mockResolvingCodec{}
+	s := New(Config{
+		Registry: registry,
+		Codec:    codec,
+		Subject:  "tasks-value",
+		Schema:   Schema{Type: TypeAvro, Schema: `"string"`},
+	})
+	require.NoError(t, s.Register(context.Background()))
+	data, err := s.Marshal("event")
+	require.NoError(t, err)
+	var v string
+	require.NoError(t, s.Unmarshal(data, &v))
+	assert.Equal(t, "", codec.writer)
+
+	registry.schemas[7] = Schema{ID: 7, Type: TypeAvro, Schema: `"bytes"`}
+	require.NoError(t, s.Unmarshal(Encode(7, nil, []byte("other")), &v))
+	assert.Equal(t, "other", v)
+	assert.Equal(t, `"bytes"`, codec.writer)
+}
+
+func Test_Register_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		registry *mockRegistry
+		err      error
+	}{
+		{
+			name:     "incompatible",
+			registry: &mockRegistry{schemas: make(map[int]Schema), compErr: ErrIncompatible},
+			err:      ErrIncompatible,
+		},
+		{
+			name:     "registry failure",
+			registry: &mockRegistry{schemas: make(map[int]Schema), regErr: ErrRegistry},
+			err:      ErrRegistry,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			t.Parallel()
+			s := New(Config{Registry: cs.registry, Codec: &mockCodec{}, Subject: "tasks-value", Schema: Schema{Type: TypeAvro}})
+			assert.ErrorIs(t, s.Register(context.Background()), cs.err)
+			_, err := s.Marshal("event")
+			assert.ErrorIs(t, err, ErrNotRegistered)
+		})
+	}
+}
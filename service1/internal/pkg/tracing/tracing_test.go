@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	once      sync.Once
+	collected *tracetest.SpanRecorder
+)
+
+func collector() *tracetest.SpanRecorder {
+	once.Do(func() {
+		collected = tracetest.NewSpanRecorder()
+		Install(sdktrace.WithSpanProcessor(collected))
+	})
+	return collected
+}
+
+func ended(name string) []sdktrace.ReadOnlySpan {
+	spans := make([]sdktrace.ReadOnlySpan, 0)
+	for _, s := range collector().Ended() {
+		if s.Name() == name {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+func Test_New_Unit(t *testing.T) {
+	t.Parallel()
+	_, err := New(context.Background(), Config{Exporter: "zipkin"})
+	assert.ErrorIs(t, err, ErrUnknownExporter)
+}
+
+func Test_Handler_Unit(t *testing.T) {
+	t.Parallel()
+	collector()
+	parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	h := Handler("/handler-test", func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, trace.SpanContextFromContext(r.Context()).IsValid())
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	req := httptest.NewRequest(http.MethodPost, "/handler-test", nil)
+	req.Header.Set("traceparent", parent)
+	h(httptest.NewRecorder(), req)
+
+	spans := ended("POST /handler-test")
+	require.Len(t, spans, 1)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+	assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind())
+	assert.Contains(t, spans[0].Attributes(), semconv.HTTPResponseStatusCode(http.StatusInternalServerError))
+}
+
+func Test_KafkaHeaders_Unit(t *testing.T) {
+	t.Parallel()
+	collector()
+	ctx, span := Start(context.Background(), "kafka-headers-test")
+	defer span.End()
+
+	headers := []kafka.Header{{Key: "x-request-id", Value: []byte("req-1")}}
+	InjectHeaders(ctx, &headers)
+	require.Len(t, headers, 2)
+
+	extracted := trace.SpanContextFromContext(ExtractHeaders(context.Background(), headers))
+	assert.Equal(t, span.SpanContext().TraceID(), extracted.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), extracted.SpanID())
+	assert.True(t, extracted.IsRemote())
+}
+
+func Test_Inject_Unit(t *testing.T) {
+	t.Parallel()
+	collector()
+	assert.Nil(t, Inject(context.Background()))
+
+	ctx, span := Start(context.Background(), "inject-test")
+	defer span.End()
+	carrier := Inject(ctx)
+	require.Contains(t, carrier, "traceparent")
+
+	extracted := trace.SpanContextFromContext(Extract(context.Background(), carrier))
+	assert.Equal(t, span.SpanContext().TraceID(), extracted.TraceID())
+}
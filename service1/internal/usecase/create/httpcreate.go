@@ -2,42 +2,59 @@ package create
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"time"
 
-	"service1/internal/adapter/broker/kafkaa"
+	"service1/internal/adapter/storage/idempotency"
 	"service1/internal/adapter/storage/inmemory"
 	"service1/internal/domain"
+	"service1/internal/pkg/auth"
+	"service1/internal/pkg/logger"
+	"service1/internal/pkg/tracing"
+)
+
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	HeaderReplayed       = "Idempotent-Replayed"
+
+	maxIdempotencyKey = 255
 )
 
 var (
 	ErrEmptyTitle           = errors.New("create: invalid body: empty task title")
 	ErrStorageAlreadyExists = errors.New("create: duplicate")
 	ErrStorageFailure       = errors.New("create: storage failed")
-	ErrBrokerUnavailable    = errors.New("create: broker unavailable")
-	ErrBrokerFailure        = errors.New("create: broker failed")
 	ErrOperationCanceled    = errors.New("create: operation canceled, request killed")
 	ErrGeneratingID         = errors.New("create: failed to generate id")
+	ErrMalformedKey         = errors.New("create: client sent a malformed idempotency key")
+	ErrRequestInProgress    = errors.New("create: request with the same idempotency key in progress")
+	ErrKeyReused            = errors.New("create: idempotency key reused with a different body")
+	ErrIdempotencyFailure   = errors.New("create: idempotency store failed")
 )
 
 type Config struct {
-	FailTimeout time.Duration `yaml:"fail_timeout"`
+	IdempotencyTTL  time.Duration `yaml:"idempotency_ttl"`
+	IdempotencyWait time.Duration `yaml:"idempotency_wait"`
 }
 
 type Creator interface {
-	CreateTask(ctx context.Context, task domain.Record) (int, error)
-	UpdateOrCreateTask(ctx context.Context, task domain.Record) error
+	CreateTaskWithEvent(ctx context.Context, task domain.Record, entry domain.Outbox) (domain.ID, error)
 }
 
-type Publisher interface {
-	PublishEvent(ctx context.Context, event domain.Event) error
+type Idempotency interface {
+	Acquire(ctx context.Context, key string, fingerprint string) (domain.Replay, bool, error)
+	Complete(ctx context.Context, key string, replay domain.Replay, ttl time.Duration) error
+	Release(ctx context.Context, key string) error
 }
 
 type Generator interface {
-	Gen() (int, error)
+	Gen() (string, error)
 }
 
 type Timer interface {
@@ -55,13 +72,14 @@ type Decoder interface {
 type Usecase struct {
 	Config Config
 
-	Creator   Creator
-	Publisher Publisher
+	Creator     Creator
+	Idempotency Idempotency
 
 	Generator Generator
 	Timer     Timer
 	Encoder   Encoder
 	Decoder   Decoder
+	Logger    *slog.Logger
 }
 
 func (u *Usecase) HTTPHandler(w http.ResponseWriter, r *http.Request) {
@@ -94,20 +112,61 @@ func (u *Usecase) HTTPHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	event, err := u.CreateTask(ctx, task)
-	if err != nil && !errors.Is(err, ErrOperationCanceled) {
-		switch {
-		case errors.Is(err, ErrStorageAlreadyExists):
-			u.sendJSON(w, domain.ErrAlreadyExists, domain.ErrAlreadyExists.Code)
-		case errors.Is(err, ErrBrokerUnavailable):
-			u.sendJSON(w, domain.ErrBrokerUnavailable, domain.ErrBrokerUnavailable.Code)
-		default:
-			u.sendJSON(w, domain.ErrInternal, domain.ErrInternal.Code)
+	key := r.Header.Get(HeaderIdempotencyKey)
+	if key == "" {
+		event, err := u.CreateTask(ctx, task)
+		if err != nil && !errors.Is(err, ErrOperationCanceled) {
+			u.sendError(ctx, w, err)
+			return
+		}
+		u.sendJSON(w, event.Record.ID, http.StatusAccepted)
+		return
+	}
+
+	if err := validateKey(key); err != nil {
+		u.sendJSON(w, domain.ErrMalformedHeader, domain.ErrMalformedHeader.Code)
+		return
+	}
+	if subject := auth.Subject(ctx); subject != "" {
+		key = subject + "/" + key
+	}
+	replay, replayed, err := u.CreateTaskOnce(ctx, key, fingerprint(body), task)
+	if err != nil {
+		if !errors.Is(err, ErrOperationCanceled) {
+			u.sendError(ctx, w, err)
 		}
 		return
 	}
+	if replayed {
+		w.Header().Set(HeaderReplayed, "true")
+	}
+	u.sendRaw(w, replay.Body, replay.Code)
+}
 
-	u.sendJSON(w, event.Record.ID, http.StatusOK)
+func (u *Usecase) sendError(ctx context.Context, w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrStorageAlreadyExists):
+		u.sendJSON(w, domain.ErrAlreadyExists, domain.ErrAlreadyExists.Code)
+	case errors.Is(err, ErrRequestInProgress):
+		u.sendJSON(w, domain.ErrRequestInProgress, domain.ErrRequestInProgress.Code)
+	case errors.Is(err, ErrKeyReused):
+		u.sendJSON(w, domain.ErrKeyReused, domain.ErrKeyReused.Code)
+	default:
+		u.Logger.ErrorContext(ctx, "create task", slog.Any("error", err))
+		u.sendJSON(w, domain.ErrInternal, domain.ErrInternal.Code)
+	}
+}
+
+func validateKey(key string) error {
+	if len(key) > maxIdempotencyKey {
+		return fmt.Errorf("%w: longer than %d bytes", ErrMalformedKey, maxIdempotencyKey)
+	}
+	return nil
+}
+
+func fingerprint(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
 }
 
 func validateTask(task domain.Record) error {
@@ -123,18 +182,70 @@ func (u *Usecase) sendJSON(w http.ResponseWriter, data any, code int) {
 		http.Error(w, domain.ErrInternal.Message, domain.ErrInternal.Code)
 		return
 	}
+	u.sendRaw(w, d, code)
+}
+
+func (u *Usecase) sendRaw(w http.ResponseWriter, data []byte, code int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
-	w.Write(d)
+	w.Write(data)
+}
+
+func (u *Usecase) CreateTaskOnce(ctx context.Context, key string, fingerprint string, task domain.Record) (domain.Replay, bool, error) {
+	c, cancel := context.WithTimeout(ctx, u.Config.IdempotencyWait)
+	stored, found, acErr := u.Idempotency.Acquire(c, key, fingerprint)
+	cancel()
+	if acErr != nil {
+		switch {
+		case ctx.Err() != nil:
+			return domain.Replay{}, false, fmt.Errorf("%w: %v", ErrOperationCanceled, acErr)
+		case errors.Is(acErr, idempotency.ErrInProgress):
+			return domain.Replay{}, false, fmt.Errorf("%w: %v", ErrRequestInProgress, acErr)
+		case errors.Is(acErr, idempotency.ErrMismatch):
+			return domain.Replay{}, false, fmt.Errorf("%w: %v", ErrKeyReused, acErr)
+		default:
+			return domain.Replay{}, false, fmt.Errorf("%w: %v", ErrIdempotencyFailure, acErr)
+		}
+	}
+	if found {
+		return stored, true, nil
+	}
+	event, ctErr := u.CreateTask(ctx, task)
+	if ctErr != nil {
+		if relErr := u.Idempotency.Release(context.WithoutCancel(ctx), key); relErr != nil {
+			u.Logger.WarnContext(ctx, "release idempotency key", slog.String("key", key), slog.Any("error", fmt.Errorf("%w: %v", ErrIdempotencyFailure, relErr)))
+		}
+		return domain.Replay{}, false, ctErr
+	}
+	body, mErr := u.Encoder.Marshal(event.Record.ID)
+	if mErr != nil {
+		if relErr := u.Idempotency.Release(context.WithoutCancel(ctx), key); relErr != nil {
+			u.Logger.WarnContext(ctx, "release idempotency key", slog.String("key", key), slog.Any("error", fmt.Errorf("%w: %v", ErrIdempotencyFailure, relErr)))
+		}
+		return domain.Replay{}, false, fmt.Errorf("%w: %v", ErrIdempotencyFailure, mErr)
+	}
+	replay := domain.Replay{Fingerprint: fingerprint, Code: http.StatusAccepted, Body: body}
+	if cErr := u.Idempotency.Complete(context.WithoutCancel(ctx), key, replay, u.Config.IdempotencyTTL); cErr != nil {
+		u.Logger.WarnContext(ctx, "complete idempotency key", slog.String("key", key), slog.Any("error", fmt.Errorf("%w: %v", ErrIdempotencyFailure, cErr)))
+	}
+	return replay, false, nil
 }
 
 func (u *Usecase) CreateTask(ctx context.Context, task domain.Record) (domain.Event, error) {
+	task.Owner = auth.Subject(ctx)
 	event, ceErr := u.createEvent(task)
 	if ceErr != nil {
 		return domain.Event{}, ceErr
 	}
-	record := event.Record
-	_, ctErr := u.Creator.CreateTask(ctx, record)
+	entry := domain.Outbox{
+		Action:    domain.ActionUpdate,
+		Event:     event,
+		State:     domain.OutboxPending,
+		CreatedAt: event.Record.CreatedAt,
+		RequestID: logger.RequestID(ctx),
+		Trace:     tracing.Inject(ctx),
+	}
+	_, ctErr := u.Creator.CreateTaskWithEvent(ctx, event.Record, entry)
 	if ctErr != nil {
 		switch {
 		case errors.Is(ctErr, inmemory.ErrOperationCanceled):
@@ -145,22 +256,6 @@ func (u *Usecase) CreateTask(ctx context.Context, task domain.Record) (domain.Ev
 			return domain.Event{}, fmt.Errorf("%w: %v", ErrStorageFailure, ctErr)
 		}
 	}
-	if pubErr := u.Publisher.PublishEvent(ctx, event); pubErr != nil {
-		c, cancel := context.WithTimeout(context.Background(), u.Config.FailTimeout)
-		defer cancel()
-		_, markErr := u.markFailure(c, record)
-		if markErr != nil {
-			return domain.Event{}, markErr
-		}
-		switch {
-		case errors.Is(pubErr, kafkaa.ErrOperationCanceled):
-			return domain.Event{}, fmt.Errorf("%w: %v", ErrOperationCanceled, pubErr)
-		case errors.Is(pubErr, kafkaa.ErrClosed):
-			return domain.Event{}, fmt.Errorf("%w: %v", ErrBrokerUnavailable, pubErr)
-		default:
-			return domain.Event{}, fmt.Errorf("%w: %v", ErrBrokerFailure, pubErr)
-		}
-	}
 	return event, nil
 }
 
@@ -172,23 +267,11 @@ func (u *Usecase) createEvent(task domain.Record) (domain.Event, error) {
 	time := u.Timer.TimeNow()
 	return domain.Event{
 		Record: domain.Record{
-			ID:        id,
+			ID:        domain.ID(id),
 			Title:     task.Title,
 			CreatedAt: time,
 			Status:    domain.StatusNew,
+			Owner:     task.Owner,
 		},
 	}, nil
 }
-
-func (u *Usecase) markFailure(ctx context.Context, task domain.Record) (domain.Record, error) {
-	task.Status = domain.StatusFailed
-	if err := u.Creator.UpdateOrCreateTask(ctx, task); err != nil {
-		switch {
-		case errors.Is(err, inmemory.ErrOperationCanceled):
-			return domain.Record{}, fmt.Errorf("%w: %v", ErrOperationCanceled, err)
-		default:
-			return domain.Record{}, fmt.Errorf("%w: %v", ErrStorageFailure, err)
-		}
-	}
-	return task, nil
-}
@@ -2,53 +2,75 @@ package create
 
 import (
 	"context"
-	"errors"
+	"log/slog"
+	"strings"
 	"testing"
+	"time"
 
-	"service1/internal/adapter/broker/kafkaa"
+	"service1/internal/adapter/storage/idempotency"
 	"service1/internal/adapter/storage/inmemory"
 	"service1/internal/domain"
+	"service1/internal/pkg/auth"
 	"service1/internal/pkg/id/uuidgen"
+	"service1/internal/pkg/json/standartjson"
 
 	"github.com/stretchr/testify/assert"
 )
 
+var discard = slog.New(slog.DiscardHandler)
+
 type mockCreator struct {
-	ct   mockCreateTask
-	uoct mockUpdateOrCreateTask
+	ctwe mockCreateTaskWithEvent
 }
 
-type mockCreateTask struct {
-	id  int
-	err error
+type mockCreateTaskWithEvent struct {
+	id    domain.ID
+	entry domain.Outbox
+	err   error
 }
 
-type mockUpdateOrCreateTask struct {
-	err error
+func (m *mockCreator) CreateTaskWithEvent(ctx context.Context, task domain.Record, entry domain.Outbox) (domain.ID, error) {
+	m.ctwe.entry = entry
+	return m.ctwe.id, m.ctwe.err
 }
 
-func (m *mockCreator) CreateTask(ctx context.Context, task domain.Record) (int, error) {
-	return m.ct.id, m.ct.err
+type mockIdempotency struct {
+	a        mockAcquire
+	c        mockComplete
+	released bool
 }
 
-func (m *mockCreator) UpdateOrCreateTask(ctx context.Context, task domain.Record) error {
-	return m.uoct.err
+type mockAcquire struct {
+	replay domain.Replay
+	found  bool
+	err    error
 }
 
-type mockPublisher struct {
-	err error
+type mockComplete struct {
+	replay domain.Replay
+	err    error
+}
+
+func (m *mockIdempotency) Acquire(ctx context.Context, key string, fingerprint string) (domain.Replay, bool, error) {
+	return m.a.replay, m.a.found, m.a.err
+}
+
+func (m *mockIdempotency) Complete(ctx context.Context, key string, replay domain.Replay, ttl time.Duration) error {
+	m.c.replay = replay
+	return m.c.err
 }
 
-func (m *mockPublisher) PublishEvent(ctx context.Context, event domain.Event) error {
-	return m.err
+func (m *mockIdempotency) Release(ctx context.Context, key string) error {
+	m.released = true
+	return nil
 }
 
 type mockGenerator struct {
-	id  int
+	id  string
 	err error
 }
 
-func (m *mockGenerator) Gen() (int, error) {
+func (m *mockGenerator) Gen() (string, error) {
 	return m.id, m.err
 }
 
@@ -76,9 +98,9 @@ func Test_CreateTask_Unit(t *testing.T) {
 			task: domain.Record{},
 			usecase: &Usecase{
 				Creator:   &mockCreator{},
-				Publisher: &mockPublisher{},
 				Generator: &mockGenerator{},
 				Timer:     &mockTimer{},
+				Logger:    discard,
 			},
 			result: domain.Event{
 				Record: domain.Record{
@@ -87,15 +109,33 @@ func Test_CreateTask_Unit(t *testing.T) {
 			},
 			err: nil,
 		},
+		{
+			name: "owner taken from principal",
+			ctx:  auth.WithPrincipal(context.Background(), auth.Principal{Subject: "alice"}),
+			task: domain.Record{Owner: "bob"},
+			usecase: &Usecase{
+				Creator:   &mockCreator{},
+				Generator: &mockGenerator{},
+				Timer:     &mockTimer{},
+				Logger:    discard,
+			},
+			result: domain.Event{
+				Record: domain.Record{
+					Status: domain.StatusNew,
+					Owner:  "alice",
+				},
+			},
+			err: nil,
+		},
 		{
 			name: "failed to generate id",
 			ctx:  context.Background(),
 			task: domain.Record{},
 			usecase: &Usecase{
 				Creator:   &mockCreator{},
-				Publisher: &mockPublisher{},
 				Generator: &mockGenerator{err: ErrGeneratingID},
 				Timer:     &mockTimer{},
+				Logger:    discard,
 			},
 			result: domain.Event{},
 			err:    ErrGeneratingID,
@@ -105,10 +145,10 @@ func Test_CreateTask_Unit(t *testing.T) {
 			ctx:  context.Background(),
 			task: domain.Record{},
 			usecase: &Usecase{
-				Creator:   &mockCreator{ct: mockCreateTask{err: inmemory.ErrAlreadyExists}},
-				Publisher: &mockPublisher{},
+				Creator:   &mockCreator{ctwe: mockCreateTaskWithEvent{err: inmemory.ErrAlreadyExists}},
 				Generator: &mockGenerator{},
 				Timer:     &mockTimer{},
+				Logger:    discard,
 			},
 			result: domain.Event{},
 			err:    ErrStorageAlreadyExists,
@@ -118,10 +158,10 @@ func Test_CreateTask_Unit(t *testing.T) {
 			ctx:  context.Background(),
 			task: domain.Record{},
 			usecase: &Usecase{
-				Creator:   &mockCreator{ct: mockCreateTask{err: inmemory.ErrExecuting}},
-				Publisher: &mockPublisher{},
+				Creator:   &mockCreator{ctwe: mockCreateTaskWithEvent{err: inmemory.ErrExecuting}},
 				Generator: &mockGenerator{},
 				Timer:     &mockTimer{},
+				Logger:    discard,
 			},
 			result: domain.Event{},
 			err:    ErrStorageFailure,
@@ -131,178 +171,221 @@ func Test_CreateTask_Unit(t *testing.T) {
 			ctx:  context.Background(),
 			task: domain.Record{},
 			usecase: &Usecase{
-				Creator:   &mockCreator{ct: mockCreateTask{err: inmemory.ErrOperationCanceled}},
-				Publisher: &mockPublisher{},
+				Creator:   &mockCreator{ctwe: mockCreateTaskWithEvent{err: inmemory.ErrOperationCanceled}},
 				Generator: &mockGenerator{},
 				Timer:     &mockTimer{},
+				Logger:    discard,
 			},
 			result: domain.Event{},
 			err:    ErrOperationCanceled,
 		},
+	}
+
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			event, err := cs.usecase.CreateTask(cs.ctx, cs.task)
+			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.result.Record.Status, event.Record.Status)
+			assert.Equal(t, cs.result.Record.Owner, event.Record.Owner)
+			if err == nil {
+				entry := cs.usecase.Creator.(*mockCreator).ctwe.entry
+				assert.Equal(t, domain.OutboxPending, entry.State)
+				assert.Equal(t, event, entry.Event)
+			}
+		})
+	}
+}
+
+func Test_CreateTaskOnce_Unit(t *testing.T) {
+	t.Parallel()
+	stored := domain.Replay{Fingerprint: "fp", Code: 202, Body: []byte(`"7"`)}
+	cases := []struct {
+		name     string
+		ctx      context.Context
+		usecase  *Usecase
+		result   domain.Replay
+		replayed bool
+		released bool
+		err      error
+	}{
 		{
-			name: "broker unavailable",
+			name: "first request",
 			ctx:  context.Background(),
-			task: domain.Record{},
 			usecase: &Usecase{
-				Creator:   &mockCreator{},
-				Publisher: &mockPublisher{err: kafkaa.ErrClosed},
-				Generator: &mockGenerator{},
-				Timer:     &mockTimer{},
+				Creator:     &mockCreator{},
+				Idempotency: &mockIdempotency{},
+				Generator:   &mockGenerator{id: "7"},
+				Timer:       &mockTimer{},
+				Logger:      discard,
+				Encoder:     standartjson.New(),
 			},
-			result: domain.Event{},
-			err:    ErrBrokerUnavailable,
+			result:   stored,
+			replayed: false,
+			released: false,
+			err:      nil,
 		},
 		{
-			name: "broker failure",
+			name: "repeated request",
 			ctx:  context.Background(),
-			task: domain.Record{},
 			usecase: &Usecase{
-				Creator:   &mockCreator{},
-				Publisher: &mockPublisher{err: errors.New("")},
-				Generator: &mockGenerator{},
-				Timer:     &mockTimer{},
+				Creator:     &mockCreator{},
+				Idempotency: &mockIdempotency{a: mockAcquire{replay: stored, found: true}},
+				Generator:   &mockGenerator{id: "8"},
+				Timer:       &mockTimer{},
+				Logger:      discard,
+				Encoder:     standartjson.New(),
 			},
-			result: domain.Event{},
-			err:    ErrBrokerFailure,
+			result:   stored,
+			replayed: true,
+			released: false,
+			err:      nil,
 		},
 		{
-			name: "context closed mid kafka call",
+			name: "concurrent duplicate",
 			ctx:  context.Background(),
-			task: domain.Record{},
 			usecase: &Usecase{
-				Creator:   &mockCreator{},
-				Publisher: &mockPublisher{err: kafkaa.ErrOperationCanceled},
-				Generator: &mockGenerator{},
-				Timer:     &mockTimer{},
+				Creator:     &mockCreator{},
+				Idempotency: &mockIdempotency{a: mockAcquire{err: idempotency.ErrInProgress}},
+				Generator:   &mockGenerator{},
+				Timer:       &mockTimer{},
+				Logger:      discard,
+				Encoder:     standartjson.New(),
 			},
-			result: domain.Event{},
-			err:    ErrOperationCanceled,
+			result:   domain.Replay{},
+			replayed: false,
+			released: false,
+			err:      ErrRequestInProgress,
 		},
 		{
-			name: "failed to mark failure",
+			name: "key reused with a different body",
 			ctx:  context.Background(),
-			task: domain.Record{},
 			usecase: &Usecase{
-				Creator:   &mockCreator{uoct: mockUpdateOrCreateTask{err: inmemory.ErrExecuting}},
-				Publisher: &mockPublisher{err: kafkaa.ErrProducingEvent},
-				Generator: &mockGenerator{},
-				Timer:     &mockTimer{},
+				Creator:     &mockCreator{},
+				Idempotency: &mockIdempotency{a: mockAcquire{err: idempotency.ErrMismatch}},
+				Generator:   &mockGenerator{},
+				Timer:       &mockTimer{},
+				Logger:      discard,
+				Encoder:     standartjson.New(),
 			},
-			result: domain.Event{},
-			err:    ErrStorageFailure,
+			result:   domain.Replay{},
+			replayed: false,
+			released: false,
+			err:      ErrKeyReused,
 		},
 		{
-			name: "context closed mid marking failure",
+			name: "storage failure releases the key",
 			ctx:  context.Background(),
-			task: domain.Record{},
 			usecase: &Usecase{
-				Creator:   &mockCreator{uoct: mockUpdateOrCreateTask{err: inmemory.ErrOperationCanceled}},
-				Publisher: &mockPublisher{err: kafkaa.ErrProducingEvent},
-				Generator: &mockGenerator{},
-				Timer:     &mockTimer{},
+				Creator:     &mockCreator{ctwe: mockCreateTaskWithEvent{err: inmemory.ErrExecuting}},
+				Idempotency: &mockIdempotency{},
+				Generator:   &mockGenerator{},
+				Timer:       &mockTimer{},
+				Logger:      discard,
+				Encoder:     standartjson.New(),
 			},
-			result: domain.Event{},
-			err:    ErrOperationCanceled,
+			result:   domain.Replay{},
+			replayed: false,
+			released: true,
+			err:      ErrStorageFailure,
+		},
+		{
+			name: "failed to save response",
+			ctx:  context.Background(),
+			usecase: &Usecase{
+				Creator:     &mockCreator{},
+				Idempotency: &mockIdempotency{c: mockComplete{err: idempotency.ErrNotFound}},
+				Generator:   &mockGenerator{id: "7"},
+				Timer:       &mockTimer{},
+				Logger:      discard,
+				Encoder:     standartjson.New(),
+			},
+			result:   stored,
+			replayed: false,
+			released: false,
+			err:      nil,
 		},
 	}
 
 	for _, cs := range cases {
 		t.Run(cs.name, func(t *testing.T) {
-			event, err := cs.usecase.CreateTask(cs.ctx, cs.task)
+			replay, replayed, err := cs.usecase.CreateTaskOnce(cs.ctx, "key", "fp", domain.Record{Title: "Title"})
 			assert.ErrorIs(t, err, cs.err)
-			assert.Equal(t, cs.result.Record.Status, event.Record.Status)
+			assert.Equal(t, cs.result, replay)
+			assert.Equal(t, cs.replayed, replayed)
+			assert.Equal(t, cs.released, cs.usecase.Idempotency.(*mockIdempotency).released)
 		})
 	}
 }
 
-func Test_createEvent_Unit(t *testing.T) {
+func Test_validateKey_Unit(t *testing.T) {
 	t.Parallel()
 	cases := []struct {
-		name    string
-		task    domain.Record
-		usecase *Usecase
-		result  domain.Event
-		err     error
+		name string
+		key  string
+		err  error
 	}{
 		{
 			name: "success",
-			task: domain.Record{},
-			usecase: &Usecase{
-				Generator: &mockGenerator{},
-				Timer:     &mockTimer{},
-			},
-			result: domain.Event{
-				Record: domain.Record{
-					Status: domain.StatusNew,
-				},
-			},
-			err: nil,
+			key:  "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+			err:  nil,
 		},
 		{
-			name: "failed to generate id",
-			task: domain.Record{},
-			usecase: &Usecase{
-				Generator: &mockGenerator{err: uuidgen.ErrGenerating},
-				Timer:     &mockTimer{},
-			},
-			result: domain.Event{},
-			err:    ErrGeneratingID,
+			name: "too long",
+			key:  strings.Repeat("k", 256),
+			err:  ErrMalformedKey,
 		},
 	}
 
 	for _, cs := range cases {
 		t.Run(cs.name, func(t *testing.T) {
-			event, err := cs.usecase.createEvent(cs.task)
+			err := validateKey(cs.key)
 			assert.ErrorIs(t, err, cs.err)
-			assert.Equal(t, cs.result.Record.Status, event.Record.Status)
 		})
 	}
 }
 
-func Test_markFailure_Unit(t *testing.T) {
+func Test_createEvent_Unit(t *testing.T) {
 	t.Parallel()
 	cases := []struct {
 		name    string
-		ctx     context.Context
 		task    domain.Record
 		usecase *Usecase
-		result  domain.Record
+		result  domain.Event
 		err     error
 	}{
 		{
 			name: "success",
 			task: domain.Record{},
 			usecase: &Usecase{
-				Creator: &mockCreator{uoct: mockUpdateOrCreateTask{}},
+				Generator: &mockGenerator{},
+				Timer:     &mockTimer{},
+				Logger:    discard,
 			},
-			result: domain.Record{Status: domain.StatusFailed},
-			err:    nil,
-		},
-		{
-			name: "context closed mid storage call",
-			task: domain.Record{},
-			usecase: &Usecase{
-				Creator: &mockCreator{uoct: mockUpdateOrCreateTask{err: inmemory.ErrOperationCanceled}},
+			result: domain.Event{
+				Record: domain.Record{
+					Status: domain.StatusNew,
+				},
 			},
-			result: domain.Record{},
-			err:    ErrOperationCanceled,
+			err: nil,
 		},
 		{
-			name: "storage failure",
+			name: "failed to generate id",
 			task: domain.Record{},
 			usecase: &Usecase{
-				Creator: &mockCreator{uoct: mockUpdateOrCreateTask{err: inmemory.ErrExecuting}},
+				Generator: &mockGenerator{err: uuidgen.ErrGenerating},
+				Timer:     &mockTimer{},
+				Logger:    discard,
 			},
-			result: domain.Record{},
-			err:    ErrStorageFailure,
+			result: domain.Event{},
+			err:    ErrGeneratingID,
 		},
 	}
 
 	for _, cs := range cases {
 		t.Run(cs.name, func(t *testing.T) {
-			record, err := cs.usecase.markFailure(cs.ctx, cs.task)
+			event, err := cs.usecase.createEvent(cs.task)
 			assert.ErrorIs(t, err, cs.err)
-			assert.Equal(t, cs.result.Status, record.Status)
+			assert.Equal(t, cs.result.Record.Status, event.Record.Status)
 		})
 	}
 }
@@ -0,0 +1,80 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"service1/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockPinger struct {
+	err error
+}
+
+func (m *mockPinger) Ping(ctx context.Context) error {
+	return m.err
+}
+
+func Test_Ready_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		usecase *Usecase
+		drain   bool
+		status  domain.HealthStatus
+		checks  map[string]string
+		err     error
+	}{
+		{
+			name:    "no checks",
+			usecase: &Usecase{},
+			status:  domain.HealthOK,
+			checks:  map[string]string{},
+			err:     nil,
+		},
+		{
+			name: "all dependencies ready",
+			usecase: &Usecase{Checks: map[string]Pinger{
+				"storage": &mockPinger{},
+				"kafka":   &mockPinger{},
+			}},
+			status: domain.HealthOK,
+			checks: map[string]string{"storage": "ok", "kafka": "ok"},
+			err:    nil,
+		},
+		{
+			name: "dependency not ready",
+			usecase: &Usecase{Checks: map[string]Pinger{
+				"storage": &mockPinger{},
+				"kafka":   &mockPinger{err: errors.New("unreachable")},
+			}},
+			status: domain.HealthUnavailable,
+			checks: map[string]string{"storage": "ok", "kafka": "unreachable"},
+			err:    ErrNotReady,
+		},
+		{
+			name: "draining",
+			usecase: &Usecase{Checks: map[string]Pinger{
+				"storage": &mockPinger{},
+			}},
+			drain:  true,
+			status: domain.HealthDraining,
+			checks: nil,
+			err:    ErrDraining,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			if cs.drain {
+				cs.usecase.Drain()
+			}
+			report, err := cs.usecase.Ready(context.Background())
+			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.status, report.Status)
+			assert.Equal(t, cs.checks, report.Checks)
+		})
+	}
+}
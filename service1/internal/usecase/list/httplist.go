@@ -4,21 +4,31 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"service1/internal/adapter/storage/inmemory"
 	"service1/internal/domain"
+	"service1/internal/pkg/auth"
 )
 
 var (
+	ErrMalformedQuery    = errors.New("list: client sent malformed query parameters")
+	ErrInvalidStatus     = errors.New("list: client sent unknown task status")
+	ErrMalformedCursor   = errors.New("list: client sent a malformed cursor")
 	ErrDatabaseFailure   = errors.New("list: database failed")
 	ErrOperationCanceled = errors.New("list: operation canceled, request killed")
 )
 
-type Config struct{}
+type Config struct {
+	DefaultLimit int `yaml:"default_limit"`
+	MaxLimit     int `yaml:"max_limit"`
+}
 
 type Getter interface {
-	GetTasks(ctx context.Context) ([]domain.Record, error)
+	GetTasks(ctx context.Context, query domain.Query) (domain.Page, error)
 }
 
 type Encoder interface {
@@ -31,6 +41,7 @@ type Usecase struct {
 	Getter Getter
 
 	Encoder Encoder
+	Logger  *slog.Logger
 }
 
 func (u *Usecase) HTTPHandler(w http.ResponseWriter, r *http.Request) {
@@ -39,16 +50,92 @@ func (u *Usecase) HTTPHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	query, validateErr := u.parseQuery(r.URL.Query())
+	if validateErr != nil {
+		switch {
+		case errors.Is(validateErr, ErrInvalidStatus):
+			u.sendJSON(w, domain.ErrInvalidStatus, domain.ErrInvalidStatus.Code)
+		default:
+			u.sendJSON(w, domain.ErrMalformedQuery, domain.ErrMalformedQuery.Code)
+		}
+		return
+	}
+
 	ctx := r.Context()
-	output, err := u.GetTasks(ctx)
+	output, err := u.GetTasks(ctx, query)
 	if err != nil && !errors.Is(err, ErrOperationCanceled) {
-		u.sendJSON(w, domain.ErrInternal, http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, ErrMalformedCursor):
+			u.sendJSON(w, domain.ErrMalformedQuery, domain.ErrMalformedQuery.Code)
+		default:
+			u.Logger.ErrorContext(ctx, "list tasks", slog.Any("error", err))
+			u.sendJSON(w, domain.ErrInternal, http.StatusInternalServerError)
+		}
 		return
 	}
 
 	u.sendJSON(w, output, http.StatusOK)
 }
 
+func (u *Usecase) parseQuery(values url.Values) (domain.Query, error) {
+	query := domain.Query{
+		Status: domain.Status(values.Get("status")),
+		Search: values.Get("search"),
+		Sort:   domain.SortByID,
+		Order:  domain.OrderAsc,
+		Cursor: values.Get("cursor"),
+		Limit:  u.Config.DefaultLimit,
+	}
+	if query.Status != "" && !query.Status.Valid() {
+		return domain.Query{}, fmt.Errorf("%w: %q", ErrInvalidStatus, query.Status)
+	}
+	if sort := values.Get("sort"); sort != "" {
+		query.Sort = domain.SortField(sort)
+		if !query.Sort.Valid() {
+			return domain.Query{}, fmt.Errorf("%w: unknown sort field %q", ErrMalformedQuery, sort)
+		}
+	}
+	if order := values.Get("order"); order != "" {
+		query.Order = domain.Order(order)
+		if !query.Order.Valid() {
+			return domain.Query{}, fmt.Errorf("%w: unknown order %q", ErrMalformedQuery, order)
+		}
+	}
+	var err error
+	if query.CreatedFrom, err = parseInt(values, "created_from"); err != nil {
+		return domain.Query{}, err
+	}
+	if query.CreatedTo, err = parseInt(values, "created_to"); err != nil {
+		return domain.Query{}, err
+	}
+	if query.CreatedFrom != 0 && query.CreatedTo != 0 && query.CreatedFrom > query.CreatedTo {
+		return domain.Query{}, fmt.Errorf("%w: created_from is after created_to", ErrMalformedQuery)
+	}
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return domain.Query{}, fmt.Errorf("%w: limit must be a positive number", ErrMalformedQuery)
+		}
+		query.Limit = limit
+	}
+	if u.Config.MaxLimit > 0 && (query.Limit == 0 || query.Limit > u.Config.MaxLimit) {
+		query.Limit = u.Config.MaxLimit
+	}
+	return query, nil
+}
+
+func parseInt(values url.Values, key string) (int64, error) {
+	raw := values.Get(key)
+	if raw == "" {
+		return 0, nil
+	}
+	i, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("%w: %s must be a unix timestamp", ErrMalformedQuery, key)
+	}
+	return i, nil
+}
+
 func (u *Usecase) sendJSON(w http.ResponseWriter, data any, code int) {
 	d, err := u.Encoder.Marshal(data)
 	if err != nil {
@@ -60,17 +147,20 @@ func (u *Usecase) sendJSON(w http.ResponseWriter, data any, code int) {
 	w.Write(d)
 }
 
-func (u *Usecase) GetTasks(ctx context.Context) ([]domain.Record, error) {
-	tasks, err := u.Getter.GetTasks(ctx)
+func (u *Usecase) GetTasks(ctx context.Context, query domain.Query) (domain.Page, error) {
+	if p, ok := auth.FromContext(ctx); ok && !p.Admin {
+		query.Owner = p.Subject
+	}
+	page, err := u.Getter.GetTasks(ctx, query)
 	if err != nil {
 		switch {
 		case errors.Is(err, inmemory.ErrOperationCanceled):
-			return nil, fmt.Errorf("%w: %v", ErrOperationCanceled, err)
-		case errors.Is(err, inmemory.ErrIncompatible):
-			return nil, fmt.Errorf("%w: %v", ErrDatabaseFailure, err)
+			return domain.Page{}, fmt.Errorf("%w: %v", ErrOperationCanceled, err)
+		case errors.Is(err, inmemory.ErrMalformedCursor):
+			return domain.Page{}, fmt.Errorf("%w: %v", ErrMalformedCursor, err)
 		default:
-			return nil, fmt.Errorf("%w: %v", ErrDatabaseFailure, err)
+			return domain.Page{}, fmt.Errorf("%w: %v", ErrDatabaseFailure, err)
 		}
 	}
-	return tasks, nil
+	return page, nil
 }
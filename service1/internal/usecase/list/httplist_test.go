@@ -2,21 +2,25 @@ package list
 
 import (
 	"context"
+	"net/url"
 	"testing"
 
 	"service1/internal/adapter/storage/inmemory"
 	"service1/internal/domain"
+	"service1/internal/pkg/auth"
 
 	"github.com/stretchr/testify/assert"
 )
 
 type mockGetter struct {
-	records []domain.Record
-	err     error
+	page  domain.Page
+	query domain.Query
+	err   error
 }
 
-func (m *mockGetter) GetTasks(ctx context.Context) ([]domain.Record, error) {
-	return m.records, m.err
+func (m *mockGetter) GetTasks(ctx context.Context, query domain.Query) (domain.Page, error) {
+	m.query = query
+	return m.page, m.err
 }
 
 func Test_GetTasks_Unit(t *testing.T) {
@@ -25,47 +29,175 @@ func Test_GetTasks_Unit(t *testing.T) {
 		name    string
 		ctx     context.Context
 		usecase *Usecase
-		result  []domain.Record
+		result  domain.Page
 		err     error
 	}{
 		{
 			name:    "success",
 			ctx:     context.Background(),
-			usecase: &Usecase{Getter: &mockGetter{records: []domain.Record{}}},
-			result:  []domain.Record{},
+			usecase: &Usecase{Getter: &mockGetter{page: domain.Page{Records: []domain.Record{}, NextCursor: "abc"}}},
+			result:  domain.Page{Records: []domain.Record{}, NextCursor: "abc"},
 			err:     nil,
 		},
 		{
 			name:    "incompatible data",
 			ctx:     context.Background(),
 			usecase: &Usecase{Getter: &mockGetter{err: inmemory.ErrIncompatible}},
-			result:  nil,
+			result:  domain.Page{},
 			err:     ErrDatabaseFailure,
 		},
+		{
+			name:    "malformed cursor",
+			ctx:     context.Background(),
+			usecase: &Usecase{Getter: &mockGetter{err: inmemory.ErrMalformedCursor}},
+			result:  domain.Page{},
+			err:     ErrMalformedCursor,
+		},
 		{
 			name:    "storage failure",
 			ctx:     context.Background(),
 			usecase: &Usecase{Getter: &mockGetter{err: inmemory.ErrExecuting}},
-			result:  nil,
+			result:  domain.Page{},
 			err:     ErrDatabaseFailure,
 		},
 		{
 			name:    "context closed mid storage call",
 			ctx:     context.Background(),
 			usecase: &Usecase{Getter: &mockGetter{err: inmemory.ErrOperationCanceled}},
-			result:  nil,
+			result:  domain.Page{},
 			err:     ErrOperationCanceled,
 		},
 	}
 	for _, cs := range cases {
 		t.Run(cs.name, func(t *testing.T) {
-			records, err := cs.usecase.GetTasks(cs.ctx)
+			page, err := cs.usecase.GetTasks(cs.ctx, domain.Query{})
+			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.result, page)
+		})
+	}
+}
+
+func Test_GetTasksOwner_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name  string
+		ctx   context.Context
+		owner string
+	}{
+		{
+			name:  "no principal",
+			ctx:   context.Background(),
+			owner: "",
+		},
+		{
+			name:  "scoped to caller",
+			ctx:   auth.WithPrincipal(context.Background(), auth.Principal{Subject: "alice"}),
+			owner: "alice",
+		},
+		{
+			name:  "admin sees everything",
+			ctx:   auth.WithPrincipal(context.Background(), auth.Principal{Subject: "root", Admin: true}),
+			owner: "",
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			getter := &mockGetter{}
+			usecase := &Usecase{Getter: getter}
+			_, err := usecase.GetTasks(cs.ctx, domain.Query{})
+			assert.NoError(t, err)
+			assert.Equal(t, cs.owner, getter.query.Owner)
+		})
+	}
+}
+
+func Test_parseQuery_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name   string
+		values url.Values
+		result domain.Query
+		err    error
+	}{
+		{
+			name:   "defaults",
+			values: url.Values{},
+			result: domain.Query{Sort: domain.SortByID, Order: domain.OrderAsc, Limit: 50},
+			err:    nil,
+		},
+		{
+			name: "all parameters",
+			values: url.Values{
+				"status":       {"new"},
+				"created_from": {"10"},
+				"created_to":   {"20"},
+				"search":       {"milk"},
+				"sort":         {"created_at"},
+				"order":        {"desc"},
+				"cursor":       {"abc"},
+				"limit":        {"10"},
+			},
+			result: domain.Query{
+				Status:      domain.StatusNew,
+				CreatedFrom: 10,
+				CreatedTo:   20,
+				Search:      "milk",
+				Sort:        domain.SortByCreatedAt,
+				Order:       domain.OrderDesc,
+				Cursor:      "abc",
+				Limit:       10,
+			},
+			err: nil,
+		},
+		{
+			name:   "limit capped",
+			values: url.Values{"limit": {"1000"}},
+			result: domain.Query{Sort: domain.SortByID, Order: domain.OrderAsc, Limit: 100},
+			err:    nil,
+		},
+		{
+			name:   "unknown status",
+			values: url.Values{"status": {"done"}},
+			result: domain.Query{},
+			err:    ErrInvalidStatus,
+		},
+		{
+			name:   "unknown sort field",
+			values: url.Values{"sort": {"owner"}},
+			result: domain.Query{},
+			err:    ErrMalformedQuery,
+		},
+		{
+			name:   "unknown order",
+			values: url.Values{"order": {"up"}},
+			result: domain.Query{},
+			err:    ErrMalformedQuery,
+		},
+		{
+			name:   "malformed timestamp",
+			values: url.Values{"created_from": {"yesterday"}},
+			result: domain.Query{},
+			err:    ErrMalformedQuery,
+		},
+		{
+			name:   "inverted range",
+			values: url.Values{"created_from": {"20"}, "created_to": {"10"}},
+			result: domain.Query{},
+			err:    ErrMalformedQuery,
+		},
+		{
+			name:   "non positive limit",
+			values: url.Values{"limit": {"0"}},
+			result: domain.Query{},
+			err:    ErrMalformedQuery,
+		},
+	}
+	u := &Usecase{Config: Config{DefaultLimit: 50, MaxLimit: 100}}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			query, err := u.parseQuery(cs.values)
 			assert.ErrorIs(t, err, cs.err)
-			if cs.result != nil {
-				assert.NotNil(t, records)
-			} else {
-				assert.Nil(t, records)
-			}
+			assert.Equal(t, cs.result, query)
 		})
 	}
 }
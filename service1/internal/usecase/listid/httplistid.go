@@ -4,11 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"strconv"
 
 	"service1/internal/adapter/storage/inmemory"
 	"service1/internal/domain"
+	"service1/internal/pkg/auth"
 )
 
 var (
@@ -21,7 +22,7 @@ var (
 type Config struct{}
 
 type Getter interface {
-	GetTaskByID(ctx context.Context, id int) (domain.Record, error)
+	GetTaskByID(ctx context.Context, id domain.ID) (domain.Record, error)
 }
 
 type Encoder interface {
@@ -34,6 +35,7 @@ type Usecase struct {
 	Getter Getter
 
 	Encoder Encoder
+	Logger  *slog.Logger
 }
 
 func (u *Usecase) HTTPHandler(w http.ResponseWriter, r *http.Request) {
@@ -56,6 +58,7 @@ func (u *Usecase) HTTPHandler(w http.ResponseWriter, r *http.Request) {
 		case errors.Is(uErr, ErrNotFound):
 			u.sendJSON(w, domain.ErrNotFound, domain.ErrNotFound.Code)
 		default:
+			u.Logger.ErrorContext(ctx, "get task", slog.String("id", string(id)), slog.Any("error", uErr))
 			u.sendJSON(w, domain.ErrInternal, domain.ErrInternal.Code)
 		}
 		return
@@ -64,10 +67,10 @@ func (u *Usecase) HTTPHandler(w http.ResponseWriter, r *http.Request) {
 	u.sendJSON(w, output, http.StatusOK)
 }
 
-func validatePathValues(id string) (int, error) {
-	i, err := strconv.Atoi(id)
-	if err != nil {
-		return 0, ErrMalformedID
+func validatePathValues(id string) (domain.ID, error) {
+	i := domain.ID(id)
+	if !i.Valid() {
+		return "", ErrMalformedID
 	}
 	return i, nil
 }
@@ -83,7 +86,7 @@ func (u *Usecase) sendJSON(w http.ResponseWriter, data any, code int) {
 	w.Write(d)
 }
 
-func (u *Usecase) GetTaskByID(ctx context.Context, id int) (domain.Record, error) {
+func (u *Usecase) GetTaskByID(ctx context.Context, id domain.ID) (domain.Record, error) {
 	task, err := u.Getter.GetTaskByID(ctx, id)
 	if err != nil {
 		switch {
@@ -95,5 +98,8 @@ func (u *Usecase) GetTaskByID(ctx context.Context, id int) (domain.Record, error
 			return domain.Record{}, fmt.Errorf("%w: %v", ErrDatabaseFailure, err)
 		}
 	}
+	if p, ok := auth.FromContext(ctx); ok && !p.Admin && task.Owner != p.Subject {
+		return domain.Record{}, fmt.Errorf("%w: owned by another user", ErrNotFound)
+	}
 	return task, nil
 }
@@ -6,6 +6,7 @@ import (
 
 	"service1/internal/adapter/storage/inmemory"
 	"service1/internal/domain"
+	"service1/internal/pkg/auth"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -15,7 +16,7 @@ type mockGetter struct {
 	err    error
 }
 
-func (m *mockGetter) GetTaskByID(ctx context.Context, id int) (domain.Record, error) {
+func (m *mockGetter) GetTaskByID(ctx context.Context, id domain.ID) (domain.Record, error) {
 	return m.record, m.err
 }
 
@@ -24,7 +25,7 @@ func Test_GetTaskByID_Unit(t *testing.T) {
 	cases := []struct {
 		name    string
 		ctx     context.Context
-		id      int
+		id      domain.ID
 		usecase *Usecase
 		result  domain.Record
 		err     error
@@ -32,15 +33,39 @@ func Test_GetTaskByID_Unit(t *testing.T) {
 		{
 			name:    "success",
 			ctx:     context.Background(),
-			id:      0,
+			id:      "0",
 			usecase: &Usecase{Getter: &mockGetter{}},
 			result:  domain.Record{},
 			err:     nil,
 		},
+		{
+			name:    "owned by caller",
+			ctx:     auth.WithPrincipal(context.Background(), auth.Principal{Subject: "alice"}),
+			id:      "0",
+			usecase: &Usecase{Getter: &mockGetter{record: domain.Record{Owner: "alice"}}},
+			result:  domain.Record{Owner: "alice"},
+			err:     nil,
+		},
+		{
+			name:    "owned by another user",
+			ctx:     auth.WithPrincipal(context.Background(), auth.Principal{Subject: "alice"}),
+			id:      "0",
+			usecase: &Usecase{Getter: &mockGetter{record: domain.Record{Owner: "bob"}}},
+			result:  domain.Record{},
+			err:     ErrNotFound,
+		},
+		{
+			name:    "admin reads any task",
+			ctx:     auth.WithPrincipal(context.Background(), auth.Principal{Subject: "root", Admin: true}),
+			id:      "0",
+			usecase: &Usecase{Getter: &mockGetter{record: domain.Record{Owner: "bob"}}},
+			result:  domain.Record{Owner: "bob"},
+			err:     nil,
+		},
 		{
 			name:    "record not found",
 			ctx:     context.Background(),
-			id:      0,
+			id:      "0",
 			usecase: &Usecase{Getter: &mockGetter{err: inmemory.ErrNotFound}},
 			result:  domain.Record{},
 			err:     ErrNotFound,
@@ -48,7 +73,7 @@ func Test_GetTaskByID_Unit(t *testing.T) {
 		{
 			name:    "storage failure",
 			ctx:     context.Background(),
-			id:      0,
+			id:      "0",
 			usecase: &Usecase{Getter: &mockGetter{err: inmemory.ErrExecuting}},
 			result:  domain.Record{},
 			err:     ErrDatabaseFailure,
@@ -56,7 +81,7 @@ func Test_GetTaskByID_Unit(t *testing.T) {
 		{
 			name:    "context canceled mid storage call",
 			ctx:     context.Background(),
-			id:      0,
+			id:      "0",
 			usecase: &Usecase{Getter: &mockGetter{err: inmemory.ErrOperationCanceled}},
 			result:  domain.Record{},
 			err:     ErrOperationCanceled,
@@ -64,8 +89,9 @@ func Test_GetTaskByID_Unit(t *testing.T) {
 	}
 	for _, cs := range cases {
 		t.Run(cs.name, func(t *testing.T) {
-			_, err := cs.usecase.GetTaskByID(cs.ctx, cs.id)
+			task, err := cs.usecase.GetTaskByID(cs.ctx, cs.id)
 			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.result, task)
 		})
 	}
 }
@@ -75,19 +101,37 @@ func Test_validatePathValues_Unit(t *testing.T) {
 	cases := []struct {
 		name   string
 		id     string
-		result int
+		result domain.ID
 		err    error
 	}{
 		{
 			name:   "success",
 			id:     "0",
-			result: 0,
+			result: "0",
+			err:    nil,
+		},
+		{
+			name:   "snowflake id",
+			id:     "7263412891123712",
+			result: "7263412891123712",
+			err:    nil,
+		},
+		{
+			name:   "ulid",
+			id:     "01ARZ3NDEKTSV4RRFFQ69G5FAV",
+			result: "01ARZ3NDEKTSV4RRFFQ69G5FAV",
+			err:    nil,
+		},
+		{
+			name:   "uuid",
+			id:     "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+			result: "7c9e6679-7425-40de-944b-e07fc1f90ae7",
 			err:    nil,
 		},
 		{
 			name:   "malformed id",
 			id:     "xxx",
-			result: 0,
+			result: "",
 			err:    ErrMalformedID,
 		},
 	}
@@ -0,0 +1,206 @@
+package patch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"service1/internal/adapter/storage/inmemory"
+	"service1/internal/domain"
+	"service1/internal/pkg/auth"
+	"service1/internal/pkg/logger"
+	"service1/internal/pkg/tracing"
+)
+
+var (
+	ErrMalformedID       = errors.New("patch: client sent a malformed id")
+	ErrEmptyPatch        = errors.New("patch: invalid body: nothing to update")
+	ErrEmptyTitle        = errors.New("patch: invalid body: empty task title")
+	ErrInvalidStatus     = errors.New("patch: invalid body: unknown task status")
+	ErrIllegalTransition = errors.New("patch: illegal status transition")
+	ErrNotFound          = errors.New("patch: no records found")
+	ErrStorageFailure    = errors.New("patch: storage failed")
+	ErrOperationCanceled = errors.New("patch: operation canceled, request killed")
+)
+
+type Config struct{}
+
+type Editor interface {
+	GetTaskByID(ctx context.Context, id domain.ID) (domain.Record, error)
+	UpdateTaskWithEvent(ctx context.Context, from domain.Status, task domain.Record, entry domain.Outbox) error
+}
+
+type Timer interface {
+	TimeNow() int64
+}
+
+type Encoder interface {
+	Marshal(data any) ([]byte, error)
+}
+
+type Decoder interface {
+	Unmarshal(data []byte, v any) error
+}
+
+type Usecase struct {
+	Config Config
+
+	Editor Editor
+
+	Timer   Timer
+	Encoder Encoder
+	Decoder Decoder
+	Logger  *slog.Logger
+}
+
+type Patch struct {
+	Title  *string        `json:"title"`
+	Status *domain.Status `json:"status"`
+}
+
+func (u *Usecase) HTTPHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		u.sendJSON(w, domain.ErrMethodNotAllowed, domain.ErrMethodNotAllowed.Code)
+		return
+	}
+
+	idRaw := r.PathValue("id")
+	id, validateErr := validatePathValues(idRaw)
+	if validateErr != nil {
+		u.sendJSON(w, domain.ErrMalformedPathValue, domain.ErrMalformedPathValue.Code)
+		return
+	}
+
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		u.sendJSON(w, domain.ErrMalformedBody, domain.ErrMalformedBody.Code)
+		return
+	}
+	var patch Patch
+	if err := u.Decoder.Unmarshal(body, &patch); err != nil {
+		u.sendJSON(w, domain.ErrMalformedBody, domain.ErrMalformedBody.Code)
+		return
+	}
+
+	if err := validatePatch(patch); err != nil {
+		switch {
+		case errors.Is(err, ErrEmptyPatch):
+			u.sendJSON(w, domain.ErrEmptyPatch, domain.ErrEmptyPatch.Code)
+		case errors.Is(err, ErrEmptyTitle):
+			u.sendJSON(w, domain.ErrEmptyTitle, domain.ErrEmptyTitle.Code)
+		case errors.Is(err, ErrInvalidStatus):
+			u.sendJSON(w, domain.ErrInvalidStatus, domain.ErrInvalidStatus.Code)
+		default:
+			u.sendJSON(w, domain.ErrInternal, domain.ErrInternal.Code)
+		}
+		return
+	}
+
+	ctx := r.Context()
+	output, uErr := u.PatchTask(ctx, id, patch)
+	if uErr != nil && !errors.Is(uErr, ErrOperationCanceled) {
+		switch {
+		case errors.Is(uErr, ErrNotFound):
+			u.sendJSON(w, domain.ErrNotFound, domain.ErrNotFound.Code)
+		case errors.Is(uErr, ErrIllegalTransition):
+			u.sendJSON(w, domain.ErrIllegalTransition, domain.ErrIllegalTransition.Code)
+		default:
+			u.Logger.ErrorContext(ctx, "patch task", slog.String("id", string(id)), slog.Any("error", uErr))
+			u.sendJSON(w, domain.ErrInternal, domain.ErrInternal.Code)
+		}
+		return
+	}
+
+	u.sendJSON(w, output, http.StatusOK)
+}
+
+func validatePathValues(id string) (domain.ID, error) {
+	i := domain.ID(id)
+	if !i.Valid() {
+		return "", ErrMalformedID
+	}
+	return i, nil
+}
+
+func validatePatch(patch Patch) error {
+	if patch.Title == nil && patch.Status == nil {
+		return fmt.Errorf("%w", ErrEmptyPatch)
+	}
+	if patch.Title != nil && *patch.Title == "" {
+		return fmt.Errorf("%w", ErrEmptyTitle)
+	}
+	if patch.Status != nil && !patch.Status.Valid() {
+		return fmt.Errorf("%w", ErrInvalidStatus)
+	}
+	return nil
+}
+
+func (u *Usecase) sendJSON(w http.ResponseWriter, data any, code int) {
+	d, err := u.Encoder.Marshal(data)
+	if err != nil {
+		http.Error(w, domain.ErrInternal.Message, domain.ErrInternal.Code)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(d)
+}
+
+func (u *Usecase) PatchTask(ctx context.Context, id domain.ID, patch Patch) (domain.Record, error) {
+	var patched domain.Record
+	err := inmemory.Retry(func() error {
+		task, err := u.Editor.GetTaskByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if p, ok := auth.FromContext(ctx); ok && !p.Admin && task.Owner != p.Subject {
+			return fmt.Errorf("%w: owned by another user", ErrNotFound)
+		}
+		from := task.Status
+		if patch.Title != nil {
+			task.Title = *patch.Title
+		}
+		if patch.Status != nil && *patch.Status != task.Status {
+			if !task.Status.CanTransition(*patch.Status) {
+				return fmt.Errorf("%w: task %s: %s -> %s", ErrIllegalTransition, id, task.Status, *patch.Status)
+			}
+			task.Status = *patch.Status
+		}
+		entry := domain.Outbox{
+			Action:    domain.ActionEdit,
+			Event:     domain.Event{Record: task},
+			State:     domain.OutboxPending,
+			CreatedAt: u.Timer.TimeNow(),
+			RequestID: logger.RequestID(ctx),
+			Trace:     tracing.Inject(ctx),
+		}
+		if err := u.Editor.UpdateTaskWithEvent(ctx, from, task, entry); err != nil {
+			return err
+		}
+		patched = task
+		return nil
+	})
+	if err != nil {
+		return domain.Record{}, mapStorageError(err)
+	}
+	return patched, nil
+}
+
+func mapStorageError(err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrIllegalTransition):
+		return err
+	case errors.Is(err, inmemory.ErrOperationCanceled):
+		return fmt.Errorf("%w: %v", ErrOperationCanceled, err)
+	case errors.Is(err, inmemory.ErrNotFound):
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	case errors.Is(err, inmemory.ErrConflict):
+		return fmt.Errorf("%w: %v", ErrIllegalTransition, err)
+	default:
+		return fmt.Errorf("%w: %v", ErrStorageFailure, err)
+	}
+}
@@ -0,0 +1,269 @@
+package patch
+
+import (
+	"context"
+	"testing"
+
+	"service1/internal/adapter/storage/inmemory"
+	"service1/internal/domain"
+	"service1/internal/pkg/auth"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockEditor struct {
+	gtbi mockGetTaskByID
+	utwe mockUpdateTaskWithEvent
+}
+
+type mockGetTaskByID struct {
+	record domain.Record
+	err    error
+}
+
+type mockUpdateTaskWithEvent struct {
+	calls int
+	from  domain.Status
+	entry domain.Outbox
+	err   error
+}
+
+func (m *mockEditor) GetTaskByID(ctx context.Context, id domain.ID) (domain.Record, error) {
+	return m.gtbi.record, m.gtbi.err
+}
+
+func (m *mockEditor) UpdateTaskWithEvent(ctx context.Context, from domain.Status, task domain.Record, entry domain.Outbox) error {
+	m.utwe.calls++
+	m.utwe.from = from
+	m.utwe.entry = entry
+	return m.utwe.err
+}
+
+type mockTimer struct {
+	time int64
+}
+
+func (m *mockTimer) TimeNow() int64 {
+	return m.time
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func Test_PatchTask_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		patch   Patch
+		usecase *Usecase
+		result  domain.Record
+		err     error
+	}{
+		{
+			name:  "title edited",
+			ctx:   context.Background(),
+			patch: Patch{Title: ptr("New")},
+			usecase: &Usecase{
+				Editor: &mockEditor{gtbi: mockGetTaskByID{record: domain.Record{Title: "Old", Status: domain.StatusNew}}},
+				Timer:  &mockTimer{},
+			},
+			result: domain.Record{Title: "New", Status: domain.StatusNew},
+			err:    nil,
+		},
+		{
+			name:  "status changed",
+			ctx:   context.Background(),
+			patch: Patch{Status: ptr(domain.StatusPending)},
+			usecase: &Usecase{
+				Editor: &mockEditor{gtbi: mockGetTaskByID{record: domain.Record{Title: "Old", Status: domain.StatusFailed}}},
+				Timer:  &mockTimer{},
+			},
+			result: domain.Record{Title: "Old", Status: domain.StatusPending},
+			err:    nil,
+		},
+		{
+			name:  "status unchanged",
+			ctx:   context.Background(),
+			patch: Patch{Title: ptr("New"), Status: ptr(domain.StatusCompleted)},
+			usecase: &Usecase{
+				Editor: &mockEditor{gtbi: mockGetTaskByID{record: domain.Record{Title: "Old", Status: domain.StatusCompleted}}},
+				Timer:  &mockTimer{},
+			},
+			result: domain.Record{Title: "New", Status: domain.StatusCompleted},
+			err:    nil,
+		},
+		{
+			name:  "illegal transition",
+			ctx:   context.Background(),
+			patch: Patch{Status: ptr(domain.StatusNew)},
+			usecase: &Usecase{
+				Editor: &mockEditor{gtbi: mockGetTaskByID{record: domain.Record{Title: "Old", Status: domain.StatusCompleted}}},
+				Timer:  &mockTimer{},
+			},
+			result: domain.Record{},
+			err:    ErrIllegalTransition,
+		},
+		{
+			name:  "owner edits own task",
+			ctx:   auth.WithPrincipal(context.Background(), auth.Principal{Subject: "alice"}),
+			patch: Patch{Title: ptr("New")},
+			usecase: &Usecase{
+				Editor: &mockEditor{gtbi: mockGetTaskByID{record: domain.Record{Title: "Old", Status: domain.StatusNew, Owner: "alice"}}},
+				Timer:  &mockTimer{},
+			},
+			result: domain.Record{Title: "New", Status: domain.StatusNew, Owner: "alice"},
+			err:    nil,
+		},
+		{
+			name:  "task owned by another user",
+			ctx:   auth.WithPrincipal(context.Background(), auth.Principal{Subject: "alice"}),
+			patch: Patch{Status: ptr(domain.StatusPending)},
+			usecase: &Usecase{
+				Editor: &mockEditor{gtbi: mockGetTaskByID{record: domain.Record{Title: "Old", Status: domain.StatusFailed, Owner: "bob"}}},
+				Timer:  &mockTimer{},
+			},
+			result: domain.Record{},
+			err:    ErrNotFound,
+		},
+		{
+			name:  "admin edits any task",
+			ctx:   auth.WithPrincipal(context.Background(), auth.Principal{Subject: "root", Admin: true}),
+			patch: Patch{Title: ptr("New")},
+			usecase: &Usecase{
+				Editor: &mockEditor{gtbi: mockGetTaskByID{record: domain.Record{Title: "Old", Status: domain.StatusNew, Owner: "bob"}}},
+				Timer:  &mockTimer{},
+			},
+			result: domain.Record{Title: "New", Status: domain.StatusNew, Owner: "bob"},
+			err:    nil,
+		},
+		{
+			name:  "record not found",
+			ctx:   context.Background(),
+			patch: Patch{Title: ptr("New")},
+			usecase: &Usecase{
+				Editor: &mockEditor{gtbi: mockGetTaskByID{err: inmemory.ErrNotFound}},
+				Timer:  &mockTimer{},
+			},
+			result: domain.Record{},
+			err:    ErrNotFound,
+		},
+		{
+			name:  "storage failure",
+			ctx:   context.Background(),
+			patch: Patch{Title: ptr("New")},
+			usecase: &Usecase{
+				Editor: &mockEditor{utwe: mockUpdateTaskWithEvent{err: inmemory.ErrExecuting}},
+				Timer:  &mockTimer{},
+			},
+			result: domain.Record{},
+			err:    ErrStorageFailure,
+		},
+		{
+			name:  "changed concurrently on every attempt",
+			ctx:   context.Background(),
+			patch: Patch{Status: ptr(domain.StatusPending)},
+			usecase: &Usecase{
+				Editor: &mockEditor{
+					gtbi: mockGetTaskByID{record: domain.Record{Title: "Old", Status: domain.StatusFailed}},
+					utwe: mockUpdateTaskWithEvent{err: inmemory.ErrConflict},
+				},
+				Timer: &mockTimer{},
+			},
+			result: domain.Record{},
+			err:    ErrIllegalTransition,
+		},
+		{
+			name:  "context closed mid storage call",
+			ctx:   context.Background(),
+			patch: Patch{Title: ptr("New")},
+			usecase: &Usecase{
+				Editor: &mockEditor{utwe: mockUpdateTaskWithEvent{err: inmemory.ErrOperationCanceled}},
+				Timer:  &mockTimer{},
+			},
+			result: domain.Record{},
+			err:    ErrOperationCanceled,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			record, err := cs.usecase.PatchTask(cs.ctx, "0", cs.patch)
+			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.result, record)
+			if err == nil {
+				editor := cs.usecase.Editor.(*mockEditor)
+				assert.Equal(t, editor.gtbi.record.Status, editor.utwe.from)
+				entry := editor.utwe.entry
+				assert.Equal(t, domain.ActionEdit, entry.Action)
+				assert.Equal(t, cs.result, entry.Event.Record)
+			}
+		})
+	}
+}
+
+func Test_validatePatch_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name  string
+		patch Patch
+		err   error
+	}{
+		{
+			name:  "success",
+			patch: Patch{Title: ptr("Title"), Status: ptr(domain.StatusCompleted)},
+			err:   nil,
+		},
+		{
+			name:  "empty patch",
+			patch: Patch{},
+			err:   ErrEmptyPatch,
+		},
+		{
+			name:  "empty title",
+			patch: Patch{Title: ptr("")},
+			err:   ErrEmptyTitle,
+		},
+		{
+			name:  "unknown status",
+			patch: Patch{Status: ptr(domain.Status("done"))},
+			err:   ErrInvalidStatus,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			err := validatePatch(cs.patch)
+			assert.ErrorIs(t, err, cs.err)
+		})
+	}
+}
+
+func Test_validatePathValues_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name   string
+		id     string
+		result domain.ID
+		err    error
+	}{
+		{
+			name:   "success",
+			id:     "0",
+			result: "0",
+			err:    nil,
+		},
+		{
+			name:   "malformed id",
+			id:     "xxx",
+			result: "",
+			err:    ErrMalformedID,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			id, err := validatePathValues(cs.id)
+			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.result, id)
+		})
+	}
+}
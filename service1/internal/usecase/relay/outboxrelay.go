@@ -0,0 +1,265 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"service1/internal/adapter/broker/kafkaa"
+	"service1/internal/adapter/storage/inmemory"
+	"service1/internal/domain"
+	"service1/internal/pkg/logger"
+	"service1/internal/pkg/tracing"
+)
+
+var (
+	ErrOperationCanceled = errors.New("relay: operation canceled")
+
+	ErrStorageFailure    = errors.New("relay: storage failed")
+	ErrBrokerUnavailable = errors.New("relay: broker unavailable")
+	ErrBrokerFailure     = errors.New("relay: broker failed")
+)
+
+type Config struct {
+	Interval    time.Duration `yaml:"interval"`
+	BatchSize   int           `yaml:"batch_size"`
+	RetryAmount int           `yaml:"retry_amount"`
+	Backoff     time.Duration `yaml:"backoff"`
+	MaxBackoff  time.Duration `yaml:"max_backoff"`
+	FailTimeout time.Duration `yaml:"fail_timeout"`
+	Async       bool          `yaml:"async"`
+}
+
+type Outbox interface {
+	PendingEvents(ctx context.Context, due int64, limit int) ([]domain.Outbox, error)
+	UpdateEvent(ctx context.Context, entry domain.Outbox) error
+}
+
+type Updater interface {
+	GetTaskByID(ctx context.Context, id domain.ID) (domain.Record, error)
+	TransitionTask(ctx context.Context, id domain.ID, from domain.Status, to domain.Status) (domain.Record, error)
+}
+
+type Publisher interface {
+	PublishEvent(ctx context.Context, envelope domain.Envelope) error
+	Enqueue(ctx context.Context, envelope domain.Envelope, callbacks ...func(error)) *kafkaa.Future
+}
+
+type Timer interface {
+	TimeNow() int64
+}
+
+type Usecase struct {
+	Config Config
+
+	Outbox    Outbox
+	Updater   Updater
+	Publisher Publisher
+
+	Timer  Timer
+	Logger *slog.Logger
+}
+
+func (u *Usecase) Run(ctx context.Context) error {
+	ticker := time.NewTicker(u.Config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
+		case <-ticker.C:
+			if _, err := u.Relay(ctx); err != nil && !errors.Is(err, ErrOperationCanceled) {
+				u.Logger.ErrorContext(ctx, "relay outbox", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// Relay publishes pending entries that are due. An entry still backing off is
+// skipped rather than waited on, so it holds up neither later entries for its
+// own task nor entries for any other.
+func (u *Usecase) Relay(ctx context.Context) (int, error) {
+	now := u.Timer.TimeNow()
+	entries, err := u.Outbox.PendingEvents(ctx, now, u.Config.BatchSize)
+	if err != nil {
+		switch {
+		case errors.Is(err, inmemory.ErrOperationCanceled):
+			return 0, fmt.Errorf("%w: %v", ErrOperationCanceled, err)
+		default:
+			return 0, fmt.Errorf("%w: %v", ErrStorageFailure, err)
+		}
+	}
+	entries = slices.DeleteFunc(entries, func(entry domain.Outbox) bool {
+		return entry.RetryAt > now
+	})
+	if u.Config.Async {
+		return u.relayAsync(ctx, entries)
+	}
+	for i, entry := range entries {
+		if err := u.deliver(ctx, entry); err != nil {
+			return i, err
+		}
+	}
+	return len(entries), nil
+}
+
+type result struct {
+	entry domain.Outbox
+	err   error
+}
+
+func (u *Usecase) relayAsync(ctx context.Context, entries []domain.Outbox) (int, error) {
+	results := make(chan result, len(entries))
+	now := u.Timer.TimeNow()
+	for _, entry := range entries {
+		u.Publisher.Enqueue(entryContext(ctx, entry), envelope(entry), func(err error) {
+			results <- result{entry: entry, err: err}
+		})
+	}
+	var delivered int
+	var relayErr error
+	for range entries {
+		var r result
+		select {
+		case <-ctx.Done():
+			return delivered, fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
+		case r = <-results:
+		}
+		if err := u.settle(entryContext(ctx, r.entry), r.entry, now, r.err); err != nil {
+			if relayErr == nil {
+				relayErr = err
+			}
+			continue
+		}
+		delivered++
+	}
+	return delivered, relayErr
+}
+
+func entryContext(ctx context.Context, entry domain.Outbox) context.Context {
+	ctx = logger.WithRequestID(ctx, entry.RequestID)
+	return tracing.Extract(ctx, entry.Trace)
+}
+
+func (u *Usecase) deliver(ctx context.Context, entry domain.Outbox) error {
+	ctx = entryContext(ctx, entry)
+	now := u.Timer.TimeNow()
+	return u.settle(ctx, entry, now, u.Publisher.PublishEvent(ctx, envelope(entry)))
+}
+
+func (u *Usecase) settle(ctx context.Context, entry domain.Outbox, now int64, pubErr error) error {
+	if pubErr == nil {
+		entry.State = domain.OutboxDelivered
+		if updErr := u.updateEvent(ctx, entry); updErr != nil {
+			return updErr
+		}
+		if entry.Action == domain.ActionUpdate {
+			if _, markErr := u.mark(ctx, entry.Event.Record.ID, domain.StatusPending); markErr != nil {
+				return markErr
+			}
+		}
+		return nil
+	}
+	if errors.Is(pubErr, kafkaa.ErrOperationCanceled) {
+		return fmt.Errorf("%w: %v", ErrOperationCanceled, pubErr)
+	}
+	c, cancel := context.WithTimeout(context.WithoutCancel(ctx), u.Config.FailTimeout)
+	defer cancel()
+	entry.Attempts++
+	entry.RetryAt = now + int64(u.backoff(entry.Attempts).Seconds())
+	if entry.Attempts >= u.Config.RetryAmount {
+		entry.State = domain.OutboxDead
+		u.Logger.WarnContext(ctx, "event given up",
+			slog.Int("event", entry.ID),
+			slog.String("action", string(entry.Action)),
+			slog.String("id", string(entry.Event.Record.ID)),
+			slog.Int("attempts", entry.Attempts),
+			slog.Any("error", pubErr),
+		)
+	}
+	if entry.State == domain.OutboxDead && processes(entry) {
+		if _, markErr := u.mark(c, entry.Event.Record.ID, domain.StatusFailed); markErr != nil {
+			return markErr
+		}
+	}
+	if updErr := u.updateEvent(c, entry); updErr != nil {
+		return updErr
+	}
+	switch {
+	case errors.Is(pubErr, kafkaa.ErrClosed):
+		return fmt.Errorf("%w: %v", ErrBrokerUnavailable, pubErr)
+	default:
+		return fmt.Errorf("%w: %v", ErrBrokerFailure, pubErr)
+	}
+}
+
+func envelope(entry domain.Outbox) domain.Envelope {
+	return domain.Envelope{
+		ID:            entry.EventID(),
+		Type:          entry.Action,
+		SchemaVersion: domain.EventSchemaVersion,
+		OccurredAt:    entry.CreatedAt,
+		Payload:       entry.Event,
+	}
+}
+
+func (u *Usecase) backoff(attempts int) time.Duration {
+	backoff := u.Config.Backoff
+	for a := 1; a < attempts && backoff < u.Config.MaxBackoff; a++ {
+		backoff *= 2
+	}
+	return min(backoff, u.Config.MaxBackoff)
+}
+
+func (u *Usecase) updateEvent(ctx context.Context, entry domain.Outbox) error {
+	if err := u.Outbox.UpdateEvent(ctx, entry); err != nil {
+		switch {
+		case errors.Is(err, inmemory.ErrOperationCanceled):
+			return fmt.Errorf("%w: %v", ErrOperationCanceled, err)
+		default:
+			return fmt.Errorf("%w: %v", ErrStorageFailure, err)
+		}
+	}
+	return nil
+}
+
+func processes(entry domain.Outbox) bool {
+	switch entry.Action {
+	case domain.ActionUpdate:
+		return true
+	case domain.ActionEdit:
+		return entry.Event.Record.Status == domain.StatusPending
+	default:
+		return false
+	}
+}
+
+func (u *Usecase) mark(ctx context.Context, id domain.ID, status domain.Status) (domain.Record, error) {
+	var updated domain.Record
+	err := inmemory.Retry(func() error {
+		task, err := u.Updater.GetTaskByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !task.Status.CanTransition(status) {
+			updated = task
+			return nil
+		}
+		updated, err = u.Updater.TransitionTask(ctx, id, task.Status, status)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, inmemory.ErrOperationCanceled):
+			return domain.Record{}, fmt.Errorf("%w: %v", ErrOperationCanceled, err)
+		case errors.Is(err, inmemory.ErrNotFound):
+			return domain.Record{}, nil
+		default:
+			return domain.Record{}, fmt.Errorf("%w: %v", ErrStorageFailure, err)
+		}
+	}
+	return updated, nil
+}
@@ -0,0 +1,482 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"service1/internal/adapter/broker/kafkaa"
+	"service1/internal/adapter/storage/inmemory"
+	"service1/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var discard = slog.New(slog.DiscardHandler)
+
+type mockOutbox struct {
+	pe mockPendingEvents
+	ue mockUpdateEvent
+}
+
+type mockPendingEvents struct {
+	due     int64
+	entries []domain.Outbox
+	err     error
+}
+
+type mockUpdateEvent struct {
+	entries []domain.Outbox
+	err     error
+}
+
+func (m *mockOutbox) PendingEvents(ctx context.Context, due int64, limit int) ([]domain.Outbox, error) {
+	m.pe.due = due
+	return m.pe.entries, m.pe.err
+}
+
+func (m *mockOutbox) UpdateEvent(ctx context.Context, entry domain.Outbox) error {
+	m.ue.entries = append(m.ue.entries, entry)
+	return m.ue.err
+}
+
+type mockUpdater struct {
+	gtbi mockGetTaskByID
+	tt   mockTransitionTask
+}
+
+type mockGetTaskByID struct {
+	record domain.Record
+	err    error
+}
+
+type mockTransitionTask struct {
+	calls int
+	err   error
+}
+
+func (m *mockUpdater) GetTaskByID(ctx context.Context, id domain.ID) (domain.Record, error) {
+	return m.gtbi.record, m.gtbi.err
+}
+
+func (m *mockUpdater) TransitionTask(ctx context.Context, id domain.ID, from domain.Status, to domain.Status) (domain.Record, error) {
+	m.tt.calls++
+	if m.tt.err != nil {
+		return domain.Record{}, m.tt.err
+	}
+	task := m.gtbi.record
+	task.Status = to
+	return task, nil
+}
+
+type mockPublisher struct {
+	published int
+	err       error
+}
+
+func (m *mockPublisher) PublishEvent(ctx context.Context, envelope domain.Envelope) error {
+	m.published++
+	return m.err
+}
+
+func (m *mockPublisher) Enqueue(ctx context.Context, envelope domain.Envelope, callbacks ...func(error)) *kafkaa.Future {
+	m.published++
+	for _, callback := range callbacks {
+		callback(m.err)
+	}
+	return nil
+}
+
+type mockTimer struct {
+	time int64
+}
+
+func (m *mockTimer) TimeNow() int64 {
+	return m.time
+}
+
+func Test_Relay_Unit(t *testing.T) {
+	t.Parallel()
+	pending := []domain.Outbox{
+		{ID: 1, State: domain.OutboxPending},
+		{ID: 2, State: domain.OutboxPending},
+	}
+	cases := []struct {
+		name      string
+		ctx       context.Context
+		usecase   *Usecase
+		result    int
+		published int
+		states    []domain.OutboxState
+		err       error
+	}{
+		{
+			name: "success",
+			ctx:  context.Background(),
+			usecase: &Usecase{
+				Config:    Config{RetryAmount: 3},
+				Outbox:    &mockOutbox{pe: mockPendingEvents{entries: pending}},
+				Updater:   &mockUpdater{},
+				Publisher: &mockPublisher{},
+				Timer:     &mockTimer{},
+				Logger:    discard,
+			},
+			result:    2,
+			published: 2,
+			states:    []domain.OutboxState{domain.OutboxDelivered, domain.OutboxDelivered},
+			err:       nil,
+		},
+		{
+			name: "delivered task becomes pending",
+			ctx:  context.Background(),
+			usecase: &Usecase{
+				Config:    Config{RetryAmount: 3},
+				Outbox:    &mockOutbox{pe: mockPendingEvents{entries: []domain.Outbox{{ID: 1, Action: domain.ActionUpdate, State: domain.OutboxPending}}}},
+				Updater:   &mockUpdater{gtbi: mockGetTaskByID{record: domain.Record{Status: domain.StatusNew}}},
+				Publisher: &mockPublisher{},
+				Timer:     &mockTimer{},
+				Logger:    discard,
+			},
+			result:    1,
+			published: 1,
+			states:    []domain.OutboxState{domain.OutboxDelivered},
+			err:       nil,
+		},
+		{
+			name: "storage failure",
+			ctx:  context.Background(),
+			usecase: &Usecase{
+				Config:    Config{RetryAmount: 3},
+				Outbox:    &mockOutbox{pe: mockPendingEvents{err: inmemory.ErrExecuting}},
+				Updater:   &mockUpdater{},
+				Publisher: &mockPublisher{},
+				Timer:     &mockTimer{},
+				Logger:    discard,
+			},
+			result:    0,
+			published: 0,
+			states:    nil,
+			err:       ErrStorageFailure,
+		},
+		{
+			name: "broker failure stops the batch",
+			ctx:  context.Background(),
+			usecase: &Usecase{
+				Config:    Config{RetryAmount: 3},
+				Outbox:    &mockOutbox{pe: mockPendingEvents{entries: pending}},
+				Updater:   &mockUpdater{},
+				Publisher: &mockPublisher{err: errors.New("")},
+				Timer:     &mockTimer{},
+				Logger:    discard,
+			},
+			result:    0,
+			published: 1,
+			states:    []domain.OutboxState{domain.OutboxPending},
+			err:       ErrBrokerFailure,
+		},
+		{
+			name: "broker unavailable",
+			ctx:  context.Background(),
+			usecase: &Usecase{
+				Config:    Config{RetryAmount: 3},
+				Outbox:    &mockOutbox{pe: mockPendingEvents{entries: pending}},
+				Updater:   &mockUpdater{},
+				Publisher: &mockPublisher{err: kafkaa.ErrClosed},
+				Timer:     &mockTimer{},
+				Logger:    discard,
+			},
+			result:    0,
+			published: 1,
+			states:    []domain.OutboxState{domain.OutboxPending},
+			err:       ErrBrokerUnavailable,
+		},
+		{
+			name: "context closed mid kafka call",
+			ctx:  context.Background(),
+			usecase: &Usecase{
+				Config:    Config{RetryAmount: 3},
+				Outbox:    &mockOutbox{pe: mockPendingEvents{entries: pending}},
+				Updater:   &mockUpdater{},
+				Publisher: &mockPublisher{err: kafkaa.ErrOperationCanceled},
+				Timer:     &mockTimer{},
+				Logger:    discard,
+			},
+			result:    0,
+			published: 1,
+			states:    nil,
+			err:       ErrOperationCanceled,
+		},
+		{
+			name: "backing off is skipped",
+			ctx:  context.Background(),
+			usecase: &Usecase{
+				Config:    Config{RetryAmount: 3},
+				Outbox:    &mockOutbox{pe: mockPendingEvents{entries: []domain.Outbox{{ID: 1, RetryAt: 10}, {ID: 2, State: domain.OutboxPending}}}},
+				Updater:   &mockUpdater{},
+				Publisher: &mockPublisher{},
+				Timer:     &mockTimer{time: 5},
+			},
+			result:    1,
+			published: 1,
+			states:    []domain.OutboxState{domain.OutboxDelivered},
+			err:       nil,
+		},
+		{
+			name: "retries exhausted",
+			ctx:  context.Background(),
+			usecase: &Usecase{
+				Config:    Config{RetryAmount: 3},
+				Outbox:    &mockOutbox{pe: mockPendingEvents{entries: []domain.Outbox{{ID: 1, Action: domain.ActionUpdate, Attempts: 2, State: domain.OutboxPending}}}},
+				Updater:   &mockUpdater{},
+				Publisher: &mockPublisher{err: errors.New("")},
+				Timer:     &mockTimer{},
+				Logger:    discard,
+			},
+			result:    0,
+			published: 1,
+			states:    []domain.OutboxState{domain.OutboxDead},
+			err:       ErrBrokerFailure,
+		},
+		{
+			name: "failed to mark failure",
+			ctx:  context.Background(),
+			usecase: &Usecase{
+				Config:    Config{RetryAmount: 3},
+				Outbox:    &mockOutbox{pe: mockPendingEvents{entries: []domain.Outbox{{ID: 1, Action: domain.ActionUpdate, Attempts: 2, State: domain.OutboxPending}}}},
+				Updater:   &mockUpdater{gtbi: mockGetTaskByID{record: domain.Record{Status: domain.StatusNew}}, tt: mockTransitionTask{err: inmemory.ErrExecuting}},
+				Publisher: &mockPublisher{err: errors.New("")},
+				Timer:     &mockTimer{},
+				Logger:    discard,
+			},
+			result:    0,
+			published: 1,
+			states:    nil,
+			err:       ErrStorageFailure,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			delivered, err := cs.usecase.Relay(cs.ctx)
+			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.result, delivered)
+			assert.Equal(t, cs.usecase.Timer.TimeNow(), cs.usecase.Outbox.(*mockOutbox).pe.due)
+			assert.Equal(t, cs.published, cs.usecase.Publisher.(*mockPublisher).published)
+			var states []domain.OutboxState
+			for _, entry := range cs.usecase.Outbox.(*mockOutbox).ue.entries {
+				states = append(states, entry.State)
+			}
+			assert.Equal(t, cs.states, states)
+		})
+	}
+}
+
+func Test_RelayAsync_Unit(t *testing.T) {
+	t.Parallel()
+	pending := []domain.Outbox{
+		{ID: 1, State: domain.OutboxPending},
+		{ID: 2, State: domain.OutboxPending},
+	}
+	cases := []struct {
+		name      string
+		usecase   *Usecase
+		result    int
+		published int
+		states    []domain.OutboxState
+		err       error
+	}{
+		{
+			name: "success",
+			usecase: &Usecase{
+				Config:    Config{RetryAmount: 3, Async: true},
+				Outbox:    &mockOutbox{pe: mockPendingEvents{entries: pending}},
+				Updater:   &mockUpdater{},
+				Publisher: &mockPublisher{},
+				Timer:     &mockTimer{},
+				Logger:    discard,
+			},
+			result:    2,
+			published: 2,
+			states:    []domain.OutboxState{domain.OutboxDelivered, domain.OutboxDelivered},
+			err:       nil,
+		},
+		{
+			name: "broker failure settles the whole batch",
+			usecase: &Usecase{
+				Config:    Config{RetryAmount: 3, Async: true},
+				Outbox:    &mockOutbox{pe: mockPendingEvents{entries: pending}},
+				Updater:   &mockUpdater{},
+				Publisher: &mockPublisher{err: errors.New("")},
+				Timer:     &mockTimer{},
+				Logger:    discard,
+			},
+			result:    0,
+			published: 2,
+			states:    []domain.OutboxState{domain.OutboxPending, domain.OutboxPending},
+			err:       ErrBrokerFailure,
+		},
+		{
+			name: "backing off is skipped",
+			usecase: &Usecase{
+				Config:    Config{RetryAmount: 3, Async: true},
+				Outbox:    &mockOutbox{pe: mockPendingEvents{entries: []domain.Outbox{{ID: 1}, {ID: 2, RetryAt: 10}, {ID: 3}}}},
+				Updater:   &mockUpdater{},
+				Publisher: &mockPublisher{},
+				Timer:     &mockTimer{time: 5},
+				Logger:    discard,
+			},
+			result:    2,
+			published: 2,
+			states:    []domain.OutboxState{domain.OutboxDelivered, domain.OutboxDelivered},
+			err:       nil,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			delivered, err := cs.usecase.Relay(context.Background())
+			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.result, delivered)
+			assert.Equal(t, cs.published, cs.usecase.Publisher.(*mockPublisher).published)
+			var states []domain.OutboxState
+			for _, entry := range cs.usecase.Outbox.(*mockOutbox).ue.entries {
+				states = append(states, entry.State)
+			}
+			assert.Equal(t, cs.states, states)
+		})
+	}
+}
+
+func Test_mark_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		usecase *Usecase
+		result  domain.Record
+		err     error
+	}{
+		{
+			name:    "success",
+			ctx:     context.Background(),
+			usecase: &Usecase{Updater: &mockUpdater{gtbi: mockGetTaskByID{record: domain.Record{Status: domain.StatusNew}}}},
+			result:  domain.Record{Status: domain.StatusFailed},
+			err:     nil,
+		},
+		{
+			name:    "task already finished",
+			ctx:     context.Background(),
+			usecase: &Usecase{Updater: &mockUpdater{gtbi: mockGetTaskByID{record: domain.Record{Status: domain.StatusCompleted}}}},
+			result:  domain.Record{Status: domain.StatusCompleted},
+			err:     nil,
+		},
+		{
+			name:    "context closed mid storage call",
+			ctx:     context.Background(),
+			usecase: &Usecase{Updater: &mockUpdater{gtbi: mockGetTaskByID{record: domain.Record{Status: domain.StatusNew}}, tt: mockTransitionTask{err: inmemory.ErrOperationCanceled}}},
+			result:  domain.Record{},
+			err:     ErrOperationCanceled,
+		},
+		{
+			name:    "changed concurrently",
+			ctx:     context.Background(),
+			usecase: &Usecase{Updater: &mockUpdater{gtbi: mockGetTaskByID{record: domain.Record{Status: domain.StatusNew}}, tt: mockTransitionTask{err: inmemory.ErrConflict}}},
+			result:  domain.Record{},
+			err:     ErrStorageFailure,
+		},
+		{
+			name:    "deleted between read and write",
+			ctx:     context.Background(),
+			usecase: &Usecase{Updater: &mockUpdater{gtbi: mockGetTaskByID{record: domain.Record{Status: domain.StatusNew}}, tt: mockTransitionTask{err: inmemory.ErrNotFound}}},
+			result:  domain.Record{},
+			err:     nil,
+		},
+		{
+			name:    "record already deleted",
+			ctx:     context.Background(),
+			usecase: &Usecase{Updater: &mockUpdater{gtbi: mockGetTaskByID{err: inmemory.ErrNotFound}}},
+			result:  domain.Record{},
+			err:     nil,
+		},
+		{
+			name:    "storage failure",
+			ctx:     context.Background(),
+			usecase: &Usecase{Updater: &mockUpdater{gtbi: mockGetTaskByID{err: inmemory.ErrExecuting}}},
+			result:  domain.Record{},
+			err:     ErrStorageFailure,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			record, err := cs.usecase.mark(cs.ctx, "0", domain.StatusFailed)
+			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.result.Status, record.Status)
+		})
+	}
+}
+
+func Test_backoff_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		attempts int
+		result   time.Duration
+	}{
+		{name: "first attempt", attempts: 1, result: time.Second},
+		{name: "third attempt", attempts: 3, result: time.Second * 4},
+		{name: "capped", attempts: 10, result: time.Second * 30},
+	}
+	u := &Usecase{Config: Config{Backoff: time.Second, MaxBackoff: time.Second * 30}}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			assert.Equal(t, cs.result, u.backoff(cs.attempts))
+		})
+	}
+}
+
+func Test_envelope_Unit(t *testing.T) {
+	t.Parallel()
+	entry := domain.Outbox{
+		ID:        7,
+		Action:    domain.ActionEdit,
+		Event:     domain.Event{Record: domain.Record{ID: "42", Title: "Title"}},
+		CreatedAt: 100,
+	}
+	assert.Equal(t, domain.Envelope{
+		ID:            "42-7",
+		Type:          domain.ActionEdit,
+		SchemaVersion: domain.EventSchemaVersion,
+		OccurredAt:    100,
+		Payload:       entry.Event,
+	}, envelope(entry))
+}
+
+func Test_Relay_DeadCreate_Unit(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.New()
+	task := domain.Record{ID: "1", Status: domain.StatusNew}
+	_, err := storage.CreateTaskWithEvent(context.Background(), task, domain.Outbox{Action: domain.ActionUpdate, Event: domain.Event{Record: task}, State: domain.OutboxPending})
+	if !assert.NoError(t, err) {
+		return
+	}
+	u := &Usecase{
+		Config:    Config{RetryAmount: 1, FailTimeout: time.Second},
+		Outbox:    storage,
+		Updater:   storage,
+		Publisher: &mockPublisher{err: errors.New("")},
+		Timer:     &mockTimer{},
+		Logger:    discard,
+	}
+	_, err = u.Relay(context.Background())
+	assert.ErrorIs(t, err, ErrBrokerFailure)
+
+	task, err = storage.GetTaskByID(context.Background(), "1")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.StatusFailed, task.Status)
+	entry, err := storage.GetEvent(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.OutboxDead, entry.State)
+	pending, err := storage.PendingEvents(context.Background(), 0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
@@ -0,0 +1,128 @@
+package remove
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"service1/internal/adapter/storage/inmemory"
+	"service1/internal/domain"
+	"service1/internal/pkg/auth"
+	"service1/internal/pkg/logger"
+	"service1/internal/pkg/tracing"
+)
+
+var (
+	ErrMalformedID       = errors.New("remove: client sent a malformed id")
+	ErrNotFound          = errors.New("remove: no records found")
+	ErrStorageFailure    = errors.New("remove: storage failed")
+	ErrOperationCanceled = errors.New("remove: operation canceled, request killed")
+)
+
+type Config struct{}
+
+type Remover interface {
+	GetTaskByID(ctx context.Context, id domain.ID) (domain.Record, error)
+	DeleteTaskWithEvent(ctx context.Context, id domain.ID, entry domain.Outbox) error
+}
+
+type Timer interface {
+	TimeNow() int64
+}
+
+type Encoder interface {
+	Marshal(data any) ([]byte, error)
+}
+
+type Usecase struct {
+	Config Config
+
+	Remover Remover
+
+	Timer   Timer
+	Encoder Encoder
+	Logger  *slog.Logger
+}
+
+func (u *Usecase) HTTPHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		u.sendJSON(w, domain.ErrMethodNotAllowed, domain.ErrMethodNotAllowed.Code)
+		return
+	}
+
+	idRaw := r.PathValue("id")
+	id, validateErr := validatePathValues(idRaw)
+	if validateErr != nil {
+		u.sendJSON(w, domain.ErrMalformedPathValue, domain.ErrMalformedPathValue.Code)
+		return
+	}
+
+	ctx := r.Context()
+	output, uErr := u.RemoveTask(ctx, id)
+	if uErr != nil && !errors.Is(uErr, ErrOperationCanceled) {
+		switch {
+		case errors.Is(uErr, ErrNotFound):
+			u.sendJSON(w, domain.ErrNotFound, domain.ErrNotFound.Code)
+		default:
+			u.Logger.ErrorContext(ctx, "remove task", slog.String("id", string(id)), slog.Any("error", uErr))
+			u.sendJSON(w, domain.ErrInternal, domain.ErrInternal.Code)
+		}
+		return
+	}
+
+	u.sendJSON(w, output.ID, http.StatusOK)
+}
+
+func validatePathValues(id string) (domain.ID, error) {
+	i := domain.ID(id)
+	if !i.Valid() {
+		return "", ErrMalformedID
+	}
+	return i, nil
+}
+
+func (u *Usecase) sendJSON(w http.ResponseWriter, data any, code int) {
+	d, err := u.Encoder.Marshal(data)
+	if err != nil {
+		http.Error(w, domain.ErrInternal.Message, domain.ErrInternal.Code)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(d)
+}
+
+func (u *Usecase) RemoveTask(ctx context.Context, id domain.ID) (domain.Record, error) {
+	task, getErr := u.Remover.GetTaskByID(ctx, id)
+	if getErr != nil {
+		return domain.Record{}, mapStorageError(getErr)
+	}
+	if p, ok := auth.FromContext(ctx); ok && !p.Admin && task.Owner != p.Subject {
+		return domain.Record{}, fmt.Errorf("%w: owned by another user", ErrNotFound)
+	}
+	entry := domain.Outbox{
+		Action:    domain.ActionDelete,
+		Event:     domain.Event{Record: task},
+		State:     domain.OutboxPending,
+		CreatedAt: u.Timer.TimeNow(),
+		RequestID: logger.RequestID(ctx),
+		Trace:     tracing.Inject(ctx),
+	}
+	if delErr := u.Remover.DeleteTaskWithEvent(ctx, id, entry); delErr != nil {
+		return domain.Record{}, mapStorageError(delErr)
+	}
+	return task, nil
+}
+
+func mapStorageError(err error) error {
+	switch {
+	case errors.Is(err, inmemory.ErrOperationCanceled):
+		return fmt.Errorf("%w: %v", ErrOperationCanceled, err)
+	case errors.Is(err, inmemory.ErrNotFound):
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	default:
+		return fmt.Errorf("%w: %v", ErrStorageFailure, err)
+	}
+}
@@ -0,0 +1,178 @@
+package remove
+
+import (
+	"context"
+	"testing"
+
+	"service1/internal/adapter/storage/inmemory"
+	"service1/internal/domain"
+	"service1/internal/pkg/auth"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockRemover struct {
+	gtbi mockGetTaskByID
+	dtwe mockDeleteTaskWithEvent
+}
+
+type mockGetTaskByID struct {
+	record domain.Record
+	err    error
+}
+
+type mockDeleteTaskWithEvent struct {
+	entry domain.Outbox
+	err   error
+}
+
+func (m *mockRemover) GetTaskByID(ctx context.Context, id domain.ID) (domain.Record, error) {
+	return m.gtbi.record, m.gtbi.err
+}
+
+func (m *mockRemover) DeleteTaskWithEvent(ctx context.Context, id domain.ID, entry domain.Outbox) error {
+	m.dtwe.entry = entry
+	return m.dtwe.err
+}
+
+type mockTimer struct {
+	time int64
+}
+
+func (m *mockTimer) TimeNow() int64 {
+	return m.time
+}
+
+func Test_RemoveTask_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		usecase *Usecase
+		result  domain.Record
+		err     error
+	}{
+		{
+			name: "success",
+			ctx:  context.Background(),
+			usecase: &Usecase{
+				Remover: &mockRemover{gtbi: mockGetTaskByID{record: domain.Record{ID: "1", Title: "Title"}}},
+				Timer:   &mockTimer{},
+			},
+			result: domain.Record{ID: "1", Title: "Title"},
+			err:    nil,
+		},
+		{
+			name: "owner removes own task",
+			ctx:  auth.WithPrincipal(context.Background(), auth.Principal{Subject: "alice"}),
+			usecase: &Usecase{
+				Remover: &mockRemover{gtbi: mockGetTaskByID{record: domain.Record{ID: "1", Owner: "alice"}}},
+				Timer:   &mockTimer{},
+			},
+			result: domain.Record{ID: "1", Owner: "alice"},
+			err:    nil,
+		},
+		{
+			name: "task owned by another user",
+			ctx:  auth.WithPrincipal(context.Background(), auth.Principal{Subject: "alice"}),
+			usecase: &Usecase{
+				Remover: &mockRemover{gtbi: mockGetTaskByID{record: domain.Record{ID: "1", Owner: "bob"}}},
+				Timer:   &mockTimer{},
+			},
+			result: domain.Record{},
+			err:    ErrNotFound,
+		},
+		{
+			name: "admin removes any task",
+			ctx:  auth.WithPrincipal(context.Background(), auth.Principal{Subject: "root", Admin: true}),
+			usecase: &Usecase{
+				Remover: &mockRemover{gtbi: mockGetTaskByID{record: domain.Record{ID: "1", Owner: "bob"}}},
+				Timer:   &mockTimer{},
+			},
+			result: domain.Record{ID: "1", Owner: "bob"},
+			err:    nil,
+		},
+		{
+			name: "record not found",
+			ctx:  context.Background(),
+			usecase: &Usecase{
+				Remover: &mockRemover{gtbi: mockGetTaskByID{err: inmemory.ErrNotFound}},
+				Timer:   &mockTimer{},
+			},
+			result: domain.Record{},
+			err:    ErrNotFound,
+		},
+		{
+			name: "deleted concurrently",
+			ctx:  context.Background(),
+			usecase: &Usecase{
+				Remover: &mockRemover{dtwe: mockDeleteTaskWithEvent{err: inmemory.ErrNotFound}},
+				Timer:   &mockTimer{},
+			},
+			result: domain.Record{},
+			err:    ErrNotFound,
+		},
+		{
+			name: "storage failure",
+			ctx:  context.Background(),
+			usecase: &Usecase{
+				Remover: &mockRemover{dtwe: mockDeleteTaskWithEvent{err: inmemory.ErrExecuting}},
+				Timer:   &mockTimer{},
+			},
+			result: domain.Record{},
+			err:    ErrStorageFailure,
+		},
+		{
+			name: "context closed mid storage call",
+			ctx:  context.Background(),
+			usecase: &Usecase{
+				Remover: &mockRemover{gtbi: mockGetTaskByID{err: inmemory.ErrOperationCanceled}},
+				Timer:   &mockTimer{},
+			},
+			result: domain.Record{},
+			err:    ErrOperationCanceled,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			record, err := cs.usecase.RemoveTask(cs.ctx, "0")
+			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.result, record)
+			if err == nil {
+				entry := cs.usecase.Remover.(*mockRemover).dtwe.entry
+				assert.Equal(t, domain.ActionDelete, entry.Action)
+				assert.Equal(t, cs.result, entry.Event.Record)
+			}
+		})
+	}
+}
+
+func Test_validatePathValues_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name   string
+		id     string
+		result domain.ID
+		err    error
+	}{
+		{
+			name:   "success",
+			id:     "0",
+			result: "0",
+			err:    nil,
+		},
+		{
+			name:   "malformed id",
+			id:     "xxx",
+			result: "",
+			err:    ErrMalformedID,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			id, err := validatePathValues(cs.id)
+			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.result, id)
+		})
+	}
+}
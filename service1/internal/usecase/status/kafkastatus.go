@@ -0,0 +1,84 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"service1/internal/adapter/storage/inmemory"
+	"service1/internal/domain"
+)
+
+var (
+	ErrOperationCanceled = errors.New("status: operation canceled")
+
+	ErrNotFound          = errors.New("status: no records found")
+	ErrIllegalTransition = errors.New("status: illegal status transition")
+	ErrStorageFailure    = errors.New("status: storage failed")
+)
+
+type Config struct{}
+
+type Updater interface {
+	GetTaskByID(ctx context.Context, id domain.ID) (domain.Record, error)
+	TransitionTask(ctx context.Context, id domain.ID, from domain.Status, to domain.Status) (domain.Record, error)
+}
+
+type Usecase struct {
+	Config Config
+
+	Updater Updater
+
+	Logger *slog.Logger
+}
+
+// EventHandler applies a status event, returning an error only when storage
+// failed and the event should be redelivered; anything else is logged and
+// dropped.
+func (u *Usecase) EventHandler(ctx context.Context, event domain.Event) error {
+	task, usErr := u.UpdateStatus(ctx, event)
+	if usErr != nil {
+		switch {
+		case errors.Is(usErr, ErrStorageFailure), errors.Is(usErr, ErrOperationCanceled):
+			return usErr
+		default:
+			u.Logger.ErrorContext(ctx, "update status", slog.String("id", string(event.Record.ID)), slog.Any("error", usErr))
+			return nil
+		}
+	}
+	u.Logger.InfoContext(ctx, "status updated", slog.String("id", string(task.ID)), slog.String("status", string(task.Status)))
+	return nil
+}
+
+func (u *Usecase) UpdateStatus(ctx context.Context, event domain.Event) (domain.Record, error) {
+	var updated domain.Record
+	err := inmemory.Retry(func() error {
+		task, err := u.Updater.GetTaskByID(ctx, event.Record.ID)
+		if err != nil {
+			return err
+		}
+		if task.Status == event.Record.Status {
+			updated = task
+			return nil
+		}
+		if !task.Status.CanTransition(event.Record.Status) {
+			return fmt.Errorf("%w: task %s: %s -> %s", ErrIllegalTransition, task.ID, task.Status, event.Record.Status)
+		}
+		updated, err = u.Updater.TransitionTask(ctx, task.ID, task.Status, event.Record.Status)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrIllegalTransition):
+			return domain.Record{}, err
+		case errors.Is(err, inmemory.ErrOperationCanceled):
+			return domain.Record{}, fmt.Errorf("%w: %v", ErrOperationCanceled, err)
+		case errors.Is(err, inmemory.ErrNotFound):
+			return domain.Record{}, fmt.Errorf("%w: %v", ErrNotFound, err)
+		default:
+			return domain.Record{}, fmt.Errorf("%w: %v", ErrStorageFailure, err)
+		}
+	}
+	return updated, nil
+}
@@ -0,0 +1,206 @@
+package status
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"service1/internal/adapter/storage/inmemory"
+	"service1/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var discard = slog.New(slog.DiscardHandler)
+
+type mockUpdater struct {
+	gtbi mockGetTaskByID
+	tt   mockTransitionTask
+}
+
+type mockGetTaskByID struct {
+	record domain.Record
+	err    error
+}
+
+type mockTransitionTask struct {
+	calls int
+	err   error
+}
+
+func (m *mockUpdater) GetTaskByID(ctx context.Context, id domain.ID) (domain.Record, error) {
+	return m.gtbi.record, m.gtbi.err
+}
+
+func (m *mockUpdater) TransitionTask(ctx context.Context, id domain.ID, from domain.Status, to domain.Status) (domain.Record, error) {
+	m.tt.calls++
+	if m.tt.err != nil {
+		return domain.Record{}, m.tt.err
+	}
+	task := m.gtbi.record
+	task.Status = to
+	return task, nil
+}
+
+func Test_UpdateStatus_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		event   domain.Event
+		usecase *Usecase
+		result  domain.Record
+		err     error
+	}{
+		{
+			name:  "success",
+			ctx:   context.Background(),
+			event: domain.Event{Record: domain.Record{Status: domain.StatusCompleted}},
+			usecase: &Usecase{Updater: &mockUpdater{
+				gtbi: mockGetTaskByID{record: domain.Record{Title: "Title", Status: domain.StatusProcessing}},
+			}},
+			result: domain.Record{Title: "Title", Status: domain.StatusCompleted},
+			err:    nil,
+		},
+		{
+			name:  "processing before pending is recorded",
+			ctx:   context.Background(),
+			event: domain.Event{Record: domain.Record{Status: domain.StatusProcessing}},
+			usecase: &Usecase{Updater: &mockUpdater{
+				gtbi: mockGetTaskByID{record: domain.Record{Title: "Title", Status: domain.StatusNew}},
+			}},
+			result: domain.Record{Title: "Title", Status: domain.StatusProcessing},
+			err:    nil,
+		},
+		{
+			name:  "redelivered status",
+			ctx:   context.Background(),
+			event: domain.Event{Record: domain.Record{Status: domain.StatusProcessing}},
+			usecase: &Usecase{Updater: &mockUpdater{
+				gtbi: mockGetTaskByID{record: domain.Record{Title: "Title", Status: domain.StatusProcessing}},
+				tt:   mockTransitionTask{err: inmemory.ErrExecuting},
+			}},
+			result: domain.Record{Title: "Title", Status: domain.StatusProcessing},
+			err:    nil,
+		},
+		{
+			name:  "illegal transition",
+			ctx:   context.Background(),
+			event: domain.Event{Record: domain.Record{Status: domain.StatusProcessing}},
+			usecase: &Usecase{Updater: &mockUpdater{
+				gtbi: mockGetTaskByID{record: domain.Record{Title: "Title", Status: domain.StatusCompleted}},
+			}},
+			result: domain.Record{},
+			err:    ErrIllegalTransition,
+		},
+		{
+			name:  "record not found",
+			ctx:   context.Background(),
+			event: domain.Event{Record: domain.Record{Status: domain.StatusCompleted}},
+			usecase: &Usecase{Updater: &mockUpdater{
+				gtbi: mockGetTaskByID{err: inmemory.ErrNotFound},
+			}},
+			result: domain.Record{},
+			err:    ErrNotFound,
+		},
+		{
+			name:  "storage failure on read",
+			ctx:   context.Background(),
+			event: domain.Event{Record: domain.Record{Status: domain.StatusCompleted}},
+			usecase: &Usecase{Updater: &mockUpdater{
+				gtbi: mockGetTaskByID{err: inmemory.ErrExecuting},
+			}},
+			result: domain.Record{},
+			err:    ErrStorageFailure,
+		},
+		{
+			name:  "storage failure on write",
+			ctx:   context.Background(),
+			event: domain.Event{Record: domain.Record{Status: domain.StatusCompleted}},
+			usecase: &Usecase{Updater: &mockUpdater{
+				gtbi: mockGetTaskByID{record: domain.Record{Status: domain.StatusProcessing}},
+				tt:   mockTransitionTask{err: inmemory.ErrExecuting},
+			}},
+			result: domain.Record{},
+			err:    ErrStorageFailure,
+		},
+		{
+			name:  "deleted between read and write",
+			ctx:   context.Background(),
+			event: domain.Event{Record: domain.Record{Status: domain.StatusCompleted}},
+			usecase: &Usecase{Updater: &mockUpdater{
+				gtbi: mockGetTaskByID{record: domain.Record{Status: domain.StatusProcessing}},
+				tt:   mockTransitionTask{err: inmemory.ErrNotFound},
+			}},
+			result: domain.Record{},
+			err:    ErrNotFound,
+		},
+		{
+			name:  "changed concurrently on every attempt",
+			ctx:   context.Background(),
+			event: domain.Event{Record: domain.Record{Status: domain.StatusCompleted}},
+			usecase: &Usecase{Updater: &mockUpdater{
+				gtbi: mockGetTaskByID{record: domain.Record{Status: domain.StatusProcessing}},
+				tt:   mockTransitionTask{err: inmemory.ErrConflict},
+			}},
+			result: domain.Record{},
+			err:    ErrStorageFailure,
+		},
+		{
+			name:  "context closed mid storage call",
+			ctx:   context.Background(),
+			event: domain.Event{Record: domain.Record{Status: domain.StatusCompleted}},
+			usecase: &Usecase{Updater: &mockUpdater{
+				gtbi: mockGetTaskByID{record: domain.Record{Status: domain.StatusProcessing}},
+				tt:   mockTransitionTask{err: inmemory.ErrOperationCanceled},
+			}},
+			result: domain.Record{},
+			err:    ErrOperationCanceled,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			record, err := cs.usecase.UpdateStatus(cs.ctx, cs.event)
+			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.result, record)
+		})
+	}
+}
+
+func Test_EventHandler_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		usecase *Usecase
+		err     error
+	}{
+		{
+			name: "success",
+			usecase: &Usecase{Updater: &mockUpdater{
+				gtbi: mockGetTaskByID{record: domain.Record{Status: domain.StatusProcessing}},
+			}},
+			err: nil,
+		},
+		{
+			name: "illegal transition dropped",
+			usecase: &Usecase{Updater: &mockUpdater{
+				gtbi: mockGetTaskByID{record: domain.Record{Status: domain.StatusCompleted}},
+			}},
+			err: nil,
+		},
+		{
+			name: "storage failure redelivered",
+			usecase: &Usecase{Updater: &mockUpdater{
+				gtbi: mockGetTaskByID{err: inmemory.ErrExecuting},
+			}},
+			err: ErrStorageFailure,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			cs.usecase.Logger = discard
+			err := cs.usecase.EventHandler(context.Background(), domain.Event{Record: domain.Record{Status: domain.StatusFailed}})
+			assert.ErrorIs(t, err, cs.err)
+		})
+	}
+}
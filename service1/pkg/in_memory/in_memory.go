@@ -32,11 +32,9 @@ func (m *InMemory) CreateContext(ctx context.Context, key any, value any) error
 	case <-ctx.Done():
 		return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
 	default:
-		_, ok := m.store.Load(key)
-		if ok {
+		if _, loaded := m.store.LoadOrStore(key, value); loaded {
 			return fmt.Errorf("%w", ErrAlreadyExists)
 		}
-		m.store.Store(key, value)
 		return nil
 	}
 }
@@ -51,6 +49,18 @@ func (m *InMemory) UpdateOrCreateContext(ctx context.Context, key any, value any
 	}
 }
 
+func (m *InMemory) DeleteContext(ctx context.Context, key any) error {
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
+	default:
+		if _, loaded := m.store.LoadAndDelete(key); !loaded {
+			return fmt.Errorf("%w", ErrNotFound)
+		}
+		return nil
+	}
+}
+
 func (m *InMemory) LoadContext(ctx context.Context, key any) (any, error) {
 	select {
 	case <-ctx.Done():
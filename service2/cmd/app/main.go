@@ -4,14 +4,33 @@ import (
 	"context"
 	"errors"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"service2/config"
+	"service2/internal/controller/httprouter"
 	"service2/internal/controller/kafkarouter"
+	"service2/internal/pkg/avro/avroevent"
 	"service2/internal/pkg/broker/kafkaa"
+	"service2/internal/pkg/dedup"
+	"service2/internal/pkg/id/uuidgen"
 	"service2/internal/pkg/json/standartjson"
+	"service2/internal/pkg/logger"
+	"service2/internal/pkg/metrics"
+	"service2/internal/pkg/protobuf/protoevent"
+	"service2/internal/pkg/registry/confluent"
+	"service2/internal/pkg/registry/fileregistry"
+	"service2/internal/pkg/registry/serde"
+	"service2/internal/pkg/server/httpserver"
+	"service2/internal/pkg/timestamp/standarttime"
+	"service2/internal/pkg/tracing"
+	"service2/internal/pkg/upcaster"
+	"service2/internal/usecase/change"
+	"service2/internal/usecase/health"
 	"service2/internal/usecase/update"
 
 	"golang.org/x/sync/errgroup"
@@ -35,32 +54,144 @@ func run() error {
 		return cnErr
 	}
 	config.Kafka.Brokers = brokers
+	config.Producer.Address = brokers
+
+	slogger, lnewErr := logger.New(config.Logger, os.Stdout)
+	if lnewErr != nil {
+		return lnewErr
+	}
+	slog.SetDefault(slogger)
+
+	tracer, tnewErr := tracing.New(context.Background(), config.Tracing)
+	if tnewErr != nil {
+		return tnewErr
+	}
+	defer func() {
+		tshutCtx, tshutCancel := context.WithTimeout(context.Background(), config.Admin.ShutdownTimeout)
+		defer tshutCancel()
+		if tshutErr := tracer.Shutdown(tshutCtx); tshutErr != nil {
+			slogger.Error("shutdown", slog.Any("error", tshutErr))
+		}
+	}()
 
 	json := standartjson.New()
+	proto := protoevent.New()
+	avro := avroevent.New()
+
+	codecs := []serde.Codec{json, proto, avro}
+	config.Producer.Encoder = newEncoder(config.Codec, json, proto, avro)
+	serdes, snewErr := newSerdes(config.Registry, config.Producer.Topic+"-value", proto, avro)
+	if snewErr != nil {
+		return snewErr
+	}
+	for _, s := range serdes {
+		codecs = append(codecs, s)
+	}
+	if encoder, ok := serdes[config.Codec]; ok {
+		rregCtx, rregCancel := context.WithTimeout(context.Background(), config.Registry.Timeout)
+		defer rregCancel()
+		if rregErr := encoder.Register(rregCtx); rregErr != nil {
+			return rregErr
+		}
+		config.Producer.Encoder = encoder
+	}
+	producer := kafkaa.NewProducer(config.Producer)
 
+	updateUsecase := &update.Usecase{
+		Config:    config.Router.Update,
+		Publisher: producer,
+		Generator: uuidgen.New(),
+		Timer:     standarttime.New(),
+		Logger:    slogger,
+	}
+	config.Router.Dedup.Logger = slogger
+	seen, dnewErr := dedup.New(config.Router.Dedup)
+	if dnewErr != nil {
+		return dnewErr
+	}
 	router := kafkarouter.New(&kafkarouter.Config{
-		Update: &update.Usecase{
-			Config:  config.Router.Update,
-			Decoder: json,
+		Update: updateUsecase,
+		Change: &change.Usecase{
+			Config:    config.Router.Change,
+			Processor: updateUsecase,
+			Logger:    slogger,
 		},
+		Decoders: newDecoders(codecs),
+		Fallback: json,
+		Upcaster: upcaster.New(),
+		Dedup:    seen,
 	})
 
 	config.Kafka.Handler = router
+	config.Kafka.Logger = slogger
 	broker := kafkaa.New(config.Kafka)
 
+	metric := metrics.New()
+	if mregErr := metric.Register(
+		metrics.NewReaderCollector(config.Kafka.Topic, broker),
+		metrics.NewWorkerCollector(config.Kafka.Topic, broker),
+		metrics.NewDedupCollector(config.Kafka.Topic, seen),
+		metrics.NewWriterCollector(config.Producer.Topic, producer),
+		metrics.NewWriterCollector(config.Kafka.DeadLetterTopic, metrics.WriterStatsFunc(broker.DeadLetterStats)),
+	); mregErr != nil {
+		return mregErr
+	}
+
+	healthUsecase := &health.Usecase{
+		Config: config.Health,
+		Checks: map[string]health.Pinger{
+			"kafka":    producer,
+			"consumer": broker,
+		},
+		Encoder: json,
+	}
+
+	config.Admin.Handler = httprouter.New(&httprouter.Config{
+		Health:  healthUsecase,
+		Metrics: metric,
+	})
+	admin := httpserver.New(config.Admin)
+
 	snCtx, snCancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	defer snCancel()
 	ewith, ewithCtx := errgroup.WithContext(snCtx)
 	ewith.Go(func() error {
+		defer func() {
+			if bshutErr := broker.Shutdown(); bshutErr != nil {
+				slogger.Error("shutdown", slog.Any("error", bshutErr))
+			}
+			if pcloseErr := producer.Close(); pcloseErr != nil {
+				slogger.Error("shutdown", slog.Any("error", pcloseErr))
+			}
+			if dflushErr := seen.Flush(); dflushErr != nil {
+				slogger.Error("shutdown", slog.Any("error", dflushErr))
+			}
+		}()
 		if brunErr := broker.Run(ewithCtx); brunErr != nil && !errors.Is(brunErr, kafkaa.ErrOperationCanceled) {
 			return brunErr
 		}
 		return nil
 	})
+	ewith.Go(func() error {
+		if drunErr := seen.Run(ewithCtx); drunErr != nil && !errors.Is(drunErr, dedup.ErrOperationCanceled) {
+			return drunErr
+		}
+		return nil
+	})
+	ewith.Go(func() error {
+		if arunErr := admin.Run(); arunErr != nil && !errors.Is(arunErr, http.ErrServerClosed) {
+			return arunErr
+		}
+		return nil
+	})
 	ewith.Go(func() error {
 		<-ewithCtx.Done()
-		if bshutErr := broker.Shutdown(); bshutErr != nil {
-			log.Println(bshutErr)
+		healthUsecase.Drain()
+		time.Sleep(config.Health.DrainDelay)
+		ashutCtx, ashutCancel := context.WithTimeout(context.Background(), config.Admin.ShutdownTimeout)
+		defer ashutCancel()
+		if ashutErr := admin.Shutdown(ashutCtx); ashutErr != nil {
+			slogger.Error("shutdown", slog.Any("error", ashutErr))
 		}
 		return nil
 	})
@@ -71,6 +202,58 @@ func run() error {
 	return nil
 }
 
+func newEncoder(codec string, json *standartjson.JSON, proto *protoevent.Protobuf, avro *avroevent.Avro) kafkaa.Encoder {
+	switch codec {
+	case config.CodecProtobuf:
+		return proto
+	case config.CodecAvro:
+		return avro
+	default:
+		return json
+	}
+}
+
+func newDecoders(codecs []serde.Codec) map[string]kafkarouter.Decoder {
+	decoders := make(map[string]kafkarouter.Decoder, len(codecs))
+	for _, c := range codecs {
+		decoders[c.ContentType()] = c
+	}
+	return decoders
+}
+
+func newSerdes(c config.Registry, subject string, proto *protoevent.Protobuf, avro *avroevent.Avro) (map[string]*serde.Serde, error) {
+	var registry serde.Registry
+	switch c.Driver {
+	case config.RegistryNone:
+		return nil, nil
+	case config.RegistryConfluent:
+		registry = confluent.New(c.Confluent)
+	default:
+		file, err := fileregistry.New(c.File)
+		if err != nil {
+			return nil, err
+		}
+		registry = file
+	}
+	return map[string]*serde.Serde{
+		config.CodecProtobuf: serde.New(serde.Config{
+			Registry: registry,
+			Codec:    proto,
+			Subject:  subject,
+			Schema:   serde.Schema{Type: serde.TypeProtobuf, Schema: protoevent.Schema},
+			Indexes:  []int{protoevent.EnvelopeIndex},
+			Timeout:  c.Timeout,
+		}),
+		config.CodecAvro: serde.New(serde.Config{
+			Registry: registry,
+			Codec:    avro,
+			Subject:  subject,
+			Schema:   serde.Schema{Type: serde.TypeAvro, Schema: avroevent.Schema},
+			Timeout:  c.Timeout,
+		}),
+	}, nil
+}
+
 func loadEnvs() (string, []string) {
 	configPath := os.Getenv("CONFIG_PATH")
 	if configPath == "" {
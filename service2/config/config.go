@@ -3,24 +3,67 @@ package config
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"service2/internal/pkg/broker/kafkaa"
+	"service2/internal/pkg/dedup"
+	"service2/internal/pkg/logger"
+	"service2/internal/pkg/registry/confluent"
+	"service2/internal/pkg/registry/fileregistry"
+	"service2/internal/pkg/server/httpserver"
+	"service2/internal/pkg/tracing"
+	"service2/internal/usecase/change"
+	"service2/internal/usecase/health"
 	"service2/internal/usecase/update"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
 var (
-	ErrReadingConfig = errors.New("config: failed to load config")
+	ErrReadingConfig     = errors.New("config: failed to load config")
+	ErrUnknownCodec      = errors.New("config: unknown event codec")
+	ErrUnknownRegistry   = errors.New("config: unknown schema registry driver")
+	ErrUnknownMessageKey = errors.New("config: unknown kafka message key")
+	ErrUnknownDispatch   = errors.New("config: unknown kafka dispatch mode")
+	ErrRegistryCodec     = errors.New("config: schema registry requires protobuf or avro codec")
+	ErrBatchDedup        = errors.New("config: kafka batching requires router dedup")
+)
+
+const (
+	CodecJSON     = "json"
+	CodecProtobuf = "protobuf"
+	CodecAvro     = "avro"
+)
+
+const (
+	RegistryNone      = "none"
+	RegistryFile      = "file"
+	RegistryConfluent = "confluent"
 )
 
 type Config struct {
-	Kafka  kafkaa.Config `yaml:"kafka"`
-	Router Router        `yaml:"router"`
+	Admin    httpserver.Config     `yaml:"admin"`
+	Kafka    kafkaa.Config         `yaml:"kafka"`
+	Producer kafkaa.ProducerConfig `yaml:"producer"`
+	Codec    string                `yaml:"codec"`
+	Registry Registry              `yaml:"registry"`
+	Router   Router                `yaml:"router"`
+	Health   health.Config         `yaml:"health"`
+	Logger   logger.Config         `yaml:"logger"`
+	Tracing  tracing.Config        `yaml:"tracing"`
+}
+
+type Registry struct {
+	Driver    string              `yaml:"driver"`
+	Timeout   time.Duration       `yaml:"timeout"`
+	File      fileregistry.Config `yaml:"file"`
+	Confluent confluent.Config    `yaml:"confluent"`
 }
 
 type Router struct {
 	Update update.Config `yaml:"update"`
+	Change change.Config `yaml:"change"`
+	Dedup  dedup.Config  `yaml:"dedup"`
 }
 
 func New(path string) (Config, error) {
@@ -29,5 +72,32 @@ func New(path string) (Config, error) {
 	if err != nil {
 		return Config{}, fmt.Errorf("%w: %v", ErrReadingConfig, err)
 	}
+	switch c.Codec {
+	case CodecJSON, CodecProtobuf, CodecAvro:
+	default:
+		return Config{}, fmt.Errorf("%w: %q", ErrUnknownCodec, c.Codec)
+	}
+	switch c.Registry.Driver {
+	case RegistryNone:
+	case RegistryFile, RegistryConfluent:
+		if c.Codec == CodecJSON {
+			return Config{}, fmt.Errorf("%w: %q", ErrRegistryCodec, c.Codec)
+		}
+	default:
+		return Config{}, fmt.Errorf("%w: %q", ErrUnknownRegistry, c.Registry.Driver)
+	}
+	switch c.Producer.Key {
+	case "", kafkaa.KeyTaskID, kafkaa.KeyAction:
+	default:
+		return Config{}, fmt.Errorf("%w: %q", ErrUnknownMessageKey, c.Producer.Key)
+	}
+	switch c.Kafka.Dispatch {
+	case "", kafkaa.DispatchKey, kafkaa.DispatchPartition:
+	default:
+		return Config{}, fmt.Errorf("%w: %q", ErrUnknownDispatch, c.Kafka.Dispatch)
+	}
+	if c.Kafka.BatchSize > 1 && !c.Router.Dedup.Enabled {
+		return Config{}, fmt.Errorf("%w: batch size %d", ErrBatchDedup, c.Kafka.BatchSize)
+	}
 	return c, nil
 }
@@ -0,0 +1,22 @@
+package httprouter
+
+import (
+	"net/http"
+
+	"service2/internal/pkg/metrics"
+	"service2/internal/usecase/health"
+)
+
+type Config struct {
+	Health *health.Usecase
+
+	Metrics *metrics.Metrics
+}
+
+func New(c *Config) http.Handler {
+	m := http.NewServeMux()
+	m.HandleFunc("GET /healthz", c.Health.LiveHandler)
+	m.HandleFunc("GET /readyz", c.Health.ReadyHandler)
+	m.Handle("GET /metrics", c.Metrics.Handler())
+	return m
+}
@@ -2,15 +2,35 @@ package kafkarouter
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"service2/internal/domain"
+	"service2/internal/pkg/broker/kafkaa"
+	"service2/internal/pkg/logger"
+	"service2/internal/pkg/tracing"
+	"service2/internal/usecase/change"
 	"service2/internal/usecase/update"
 
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrUnknownAction       = errors.New("kafkarouter: unknown action")
+	ErrUnknownContentType  = errors.New("kafkarouter: unknown content type")
+	ErrUnmarshalingMessage = errors.New("kafkarouter: failed while unmarshaling message")
 )
 
 type Config struct {
 	Update *update.Usecase
+	Change *change.Usecase
+
+	Decoders map[string]Decoder
+	Fallback Decoder
+	Upcaster Upcaster
+	Dedup    Deduplicator
 }
 
 type Router struct {
@@ -19,12 +39,30 @@ type Router struct {
 	Handlers *Handlers
 }
 
-type Handler interface {
-	EventHandler(ctx context.Context, message kafka.Message)
+type Decoder interface {
+	Unmarshal(data []byte, v any) error
+}
+
+type Upcaster interface {
+	Upcast(envelope domain.Envelope) (domain.Envelope, error)
+}
+
+type Deduplicator interface {
+	Seen(id string) bool
+	Mark(id string)
+}
+
+type UpdateHandler interface {
+	EventHandler(ctx context.Context, event domain.Event) error
+}
+
+type ChangeHandler interface {
+	Change(ctx context.Context, action domain.Action, event domain.Event) error
 }
 
 type Handlers struct {
-	update Handler
+	update UpdateHandler
+	change ChangeHandler
 }
 
 func New(c *Config) *Router {
@@ -32,13 +70,122 @@ func New(c *Config) *Router {
 		Config: c,
 		Handlers: &Handlers{
 			update: c.Update,
+			change: c.Change,
 		},
 	}
 }
 
-func (r *Router) Route(ctx context.Context, message kafka.Message) {
-	switch domain.Action(string(message.Key)) {
+func (r *Router) Route(ctx context.Context, message kafka.Message) error {
+	ctx = logger.WithRequestID(ctx, kafkaa.Header(message, kafkaa.HeaderRequestID))
+	contentType := kafkaa.Header(message, kafkaa.HeaderContentType)
+	ctx, span := tracing.Start(ctx, "kafkarouter.Route", trace.WithAttributes(attribute.String("taskmaster.content_type", contentType)))
+	err := r.route(ctx, contentType, message)
+	tracing.End(span, err)
+	return err
+}
+
+// RouteBatch routes each message under its own trace and request ID. The first
+// failure stops the batch and reports its index, so the consumer resumes there
+// instead of routing the messages before it again.
+func (r *Router) RouteBatch(ctx context.Context, messages []kafka.Message) error {
+	for i, message := range messages {
+		if err := r.Route(tracing.ExtractHeaders(ctx, message.Headers), message); err != nil {
+			return &kafkaa.BatchError{Index: i, Err: err}
+		}
+	}
+	return nil
+}
+
+// Preempt cancels work for a delete as soon as it is fetched. It runs on the
+// fetch loop, so only messages whose action header says delete are decoded.
+func (r *Router) Preempt(ctx context.Context, message kafka.Message) {
+	if domain.Action(kafkaa.Header(message, kafkaa.HeaderAction)) != domain.ActionDelete {
+		return
+	}
+	envelope, err := r.decode(kafkaa.Header(message, kafkaa.HeaderContentType), message)
+	if err != nil || envelope.Type != domain.ActionDelete {
+		return
+	}
+	ctx = logger.WithRequestID(ctx, kafkaa.Header(message, kafkaa.HeaderRequestID))
+	r.Handlers.change.Change(ctx, envelope.Type, envelope.Payload)
+}
+
+func (r *Router) route(ctx context.Context, contentType string, message kafka.Message) error {
+	envelope, err := r.decode(contentType, message)
+	if err != nil {
+		return err
+	}
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("taskmaster.action", string(envelope.Type)),
+		attribute.String("taskmaster.event_id", envelope.ID),
+		attribute.Int("taskmaster.schema_version", envelope.SchemaVersion),
+	)
+	if r.Config.Dedup == nil {
+		return r.dispatch(ctx, envelope)
+	}
+	if r.Config.Dedup.Seen(envelope.ID) {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("taskmaster.duplicate", true))
+		return nil
+	}
+	if err := r.dispatch(ctx, envelope); err != nil {
+		return err
+	}
+	r.Config.Dedup.Mark(envelope.ID)
+	return nil
+}
+
+func (r *Router) dispatch(ctx context.Context, envelope domain.Envelope) error {
+	switch envelope.Type {
 	case domain.ActionUpdate:
-		r.Handlers.update.EventHandler(ctx, message)
+		return r.Handlers.update.EventHandler(ctx, envelope.Payload)
+	case domain.ActionEdit, domain.ActionDelete:
+		return r.Handlers.change.Change(ctx, envelope.Type, envelope.Payload)
+	default:
+		return fmt.Errorf("%w: %v: %q", kafkaa.ErrUnprocessable, ErrUnknownAction, envelope.Type)
+	}
+}
+
+func (r *Router) decode(contentType string, message kafka.Message) (domain.Envelope, error) {
+	decoder, ok := r.Config.Decoders[contentType]
+	switch {
+	case ok:
+	case contentType == "" && r.Config.Fallback != nil:
+		decoder = r.Config.Fallback
+	default:
+		return domain.Envelope{}, fmt.Errorf("%w: %v: %q", kafkaa.ErrUnprocessable, ErrUnknownContentType, contentType)
+	}
+	var envelope domain.Envelope
+	if err := decoder.Unmarshal(message.Value, &envelope); err != nil || envelope.SchemaVersion == 0 {
+		var event domain.Event
+		if lerr := decoder.Unmarshal(message.Value, &event); lerr != nil {
+			return domain.Envelope{}, fmt.Errorf("%w: %v: %v", kafkaa.ErrUnprocessable, ErrUnmarshalingMessage, errors.Join(err, lerr))
+		}
+		envelope = legacy(message, event)
+	}
+	envelope.Type = action(message, envelope.Type)
+	envelope, err := r.Config.Upcaster.Upcast(envelope)
+	if err != nil {
+		return domain.Envelope{}, fmt.Errorf("%w: %v", kafkaa.ErrUnprocessable, err)
+	}
+	return envelope, nil
+}
+
+func legacy(message kafka.Message, event domain.Event) domain.Envelope {
+	envelope := domain.Envelope{
+		ID:            fmt.Sprintf("%s-%d-%d", message.Topic, message.Partition, message.Offset),
+		Type:          domain.Action(string(message.Key)),
+		SchemaVersion: domain.EventSchemaLegacy,
+		Payload:       event,
+	}
+	if !message.Time.IsZero() {
+		envelope.OccurredAt = message.Time.Unix()
+	}
+	return envelope
+}
+
+func action(message kafka.Message, fallback domain.Action) domain.Action {
+	if a := kafkaa.Header(message, kafkaa.HeaderAction); a != "" {
+		return domain.Action(a)
 	}
+	return fallback
 }
@@ -0,0 +1,289 @@
+package kafkarouter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"service2/internal/domain"
+	"service2/internal/pkg/broker/kafkaa"
+	"service2/internal/pkg/json/standartjson"
+	"service2/internal/pkg/logger"
+	"service2/internal/pkg/protobuf/protoevent"
+	"service2/internal/pkg/upcaster"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type encoder interface {
+	Marshal(data any) ([]byte, error)
+}
+
+type mockUpdateHandler struct {
+	events []domain.Event
+	err    error
+}
+
+func (m *mockUpdateHandler) EventHandler(ctx context.Context, event domain.Event) error {
+	m.events = append(m.events, event)
+	return m.err
+}
+
+type mockBatchUpdateHandler struct {
+	requestIDs []string
+	failures   int
+}
+
+func (m *mockBatchUpdateHandler) EventHandler(ctx context.Context, event domain.Event) error {
+	m.requestIDs = append(m.requestIDs, logger.RequestID(ctx))
+	if event.Record.ID == "2" && m.failures > 0 {
+		m.failures--
+		return errors.New("")
+	}
+	return nil
+}
+
+type mockDeduplicator struct {
+	seen map[string]bool
+}
+
+func (m *mockDeduplicator) Seen(id string) bool {
+	return m.seen[id]
+}
+
+func (m *mockDeduplicator) Mark(id string) {
+	m.seen[id] = true
+}
+
+type mockChangeHandler struct {
+	actions []domain.Action
+}
+
+func (m *mockChangeHandler) Change(ctx context.Context, action domain.Action, event domain.Event) error {
+	m.actions = append(m.actions, action)
+	return nil
+}
+
+func Test_Route_Unit(t *testing.T) {
+	t.Parallel()
+	json := standartjson.New()
+	proto := protoevent.New()
+	record := domain.Record{ID: "1", Title: "Title", CreatedAt: 100, Status: domain.StatusPending}
+	encode := func(e encoder, v any) []byte {
+		b, err := e.Marshal(v)
+		require.NoError(t, err)
+		return b
+	}
+	envelope := func(action domain.Action) domain.Envelope {
+		return domain.Envelope{ID: "e", Type: action, SchemaVersion: domain.EventSchemaVersion, Producer: "service1", Payload: domain.Event{Record: record}}
+	}
+	header := func(contentType string) []kafka.Header {
+		return []kafka.Header{{Key: kafkaa.HeaderContentType, Value: []byte(contentType)}}
+	}
+	cases := []struct {
+		name    string
+		message kafka.Message
+		updates int
+		actions []domain.Action
+		err     error
+	}{
+		{
+			name:    "json envelope",
+			message: kafka.Message{Key: []byte("ignored"), Value: encode(json, envelope(domain.ActionUpdate)), Headers: header(standartjson.ContentType)},
+			updates: 1,
+		},
+		{
+			name:    "protobuf envelope",
+			message: kafka.Message{Value: encode(proto, envelope(domain.ActionEdit)), Headers: header(protoevent.ContentType)},
+			actions: []domain.Action{domain.ActionEdit},
+		},
+		{
+			name:    "legacy json without content type",
+			message: kafka.Message{Key: []byte(domain.ActionDelete), Value: encode(json, domain.Event{Record: record}), Time: time.Unix(5, 0)},
+			actions: []domain.Action{domain.ActionDelete},
+		},
+		{
+			name:    "action header wins over envelope type",
+			message: kafka.Message{Key: []byte(record.ID), Value: encode(json, envelope(domain.ActionUpdate)), Headers: append(header(standartjson.ContentType), kafka.Header{Key: kafkaa.HeaderAction, Value: []byte(domain.ActionEdit)})},
+			actions: []domain.Action{domain.ActionEdit},
+		},
+		{
+			name:    "legacy keyed by task id with action header",
+			message: kafka.Message{Key: []byte(record.ID), Value: encode(json, domain.Event{Record: record}), Headers: []kafka.Header{{Key: kafkaa.HeaderAction, Value: []byte(domain.ActionDelete)}}},
+			actions: []domain.Action{domain.ActionDelete},
+		},
+		{
+			name:    "legacy protobuf",
+			message: kafka.Message{Key: []byte(domain.ActionUpdate), Value: encode(proto, domain.Event{Record: record}), Headers: header(protoevent.ContentType)},
+			updates: 1,
+		},
+		{
+			name:    "unknown content type",
+			message: kafka.Message{Value: encode(json, envelope(domain.ActionUpdate)), Headers: header("text/plain")},
+			err:     kafkaa.ErrUnprocessable,
+		},
+		{
+			name:    "unknown action",
+			message: kafka.Message{Value: encode(json, envelope("unknown")), Headers: header(standartjson.ContentType)},
+			err:     kafkaa.ErrUnprocessable,
+		},
+		{
+			name:    "legacy without action",
+			message: kafka.Message{Value: encode(json, domain.Event{Record: record})},
+			err:     kafkaa.ErrUnprocessable,
+		},
+		{
+			name:    "newer schema version",
+			message: kafka.Message{Value: encode(json, domain.Envelope{Type: domain.ActionUpdate, SchemaVersion: domain.EventSchemaVersion + 1}), Headers: header(standartjson.ContentType)},
+			err:     kafkaa.ErrUnprocessable,
+		},
+		{
+			name:    "garbage",
+			message: kafka.Message{Value: []byte("{"), Headers: header(standartjson.ContentType)},
+			err:     kafkaa.ErrUnprocessable,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			t.Parallel()
+			update := &mockUpdateHandler{}
+			change := &mockChangeHandler{}
+			r := &Router{
+				Config: &Config{
+					Decoders: map[string]Decoder{
+						standartjson.ContentType: json,
+						protoevent.ContentType:   proto,
+					},
+					Fallback: json,
+					Upcaster: upcaster.New(),
+				},
+				Handlers: &Handlers{update: update, change: change},
+			}
+			err := r.Route(context.Background(), cs.message)
+			assert.ErrorIs(t, err, cs.err)
+			assert.Len(t, update.events, cs.updates)
+			assert.Equal(t, cs.actions, change.actions)
+			for _, e := range update.events {
+				assert.Equal(t, record, e.Record)
+			}
+		})
+	}
+}
+
+func Test_Route_Dedup_Unit(t *testing.T) {
+	t.Parallel()
+	json := standartjson.New()
+	value, err := json.Marshal(domain.Envelope{ID: "e", Type: domain.ActionUpdate, SchemaVersion: domain.EventSchemaVersion, Payload: domain.Event{Record: domain.Record{ID: "1"}}})
+	require.NoError(t, err)
+	message := kafka.Message{Key: []byte("1"), Value: value, Headers: []kafka.Header{{Key: kafkaa.HeaderContentType, Value: []byte(standartjson.ContentType)}}}
+	update := &mockUpdateHandler{err: errors.New("")}
+	dedup := &mockDeduplicator{seen: make(map[string]bool)}
+	r := &Router{
+		Config: &Config{
+			Decoders: map[string]Decoder{standartjson.ContentType: json},
+			Upcaster: upcaster.New(),
+			Dedup:    dedup,
+		},
+		Handlers: &Handlers{update: update, change: &mockChangeHandler{}},
+	}
+
+	assert.Error(t, r.Route(context.Background(), message))
+	assert.False(t, dedup.seen["e"])
+
+	update.err = nil
+	assert.NoError(t, r.Route(context.Background(), message))
+	assert.True(t, dedup.seen["e"])
+
+	assert.NoError(t, r.Route(context.Background(), message))
+	assert.Len(t, update.events, 2)
+}
+
+func Test_RouteBatch_Unit(t *testing.T) {
+	t.Parallel()
+	json := standartjson.New()
+	message := func(id string) kafka.Message {
+		value, err := json.Marshal(domain.Envelope{ID: "e" + id, Type: domain.ActionUpdate, SchemaVersion: domain.EventSchemaVersion, Payload: domain.Event{Record: domain.Record{ID: domain.ID(id)}}})
+		require.NoError(t, err)
+		return kafka.Message{Key: []byte(id), Value: value, Headers: []kafka.Header{
+			{Key: kafkaa.HeaderContentType, Value: []byte(standartjson.ContentType)},
+			{Key: kafkaa.HeaderRequestID, Value: []byte("r" + id)},
+		}}
+	}
+	batch := []kafka.Message{message("1"), message("2"), message("3")}
+	update := &mockBatchUpdateHandler{failures: 1}
+	r := &Router{
+		Config: &Config{
+			Decoders: map[string]Decoder{standartjson.ContentType: json},
+			Upcaster: upcaster.New(),
+		},
+		Handlers: &Handlers{update: update, change: &mockChangeHandler{}},
+	}
+
+	err := r.RouteBatch(context.Background(), batch)
+	var batchErr *kafkaa.BatchError
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, 1, batchErr.Index)
+
+	assert.NoError(t, r.RouteBatch(context.Background(), batch[batchErr.Index:]))
+	assert.Equal(t, []string{"r1", "r2", "r2", "r3"}, update.requestIDs)
+}
+
+func Test_Preempt_Unit(t *testing.T) {
+	t.Parallel()
+	json := standartjson.New()
+	record := domain.Record{ID: "1", Title: "Title", Status: domain.StatusPending}
+	message := func(action domain.Action, headers bool) kafka.Message {
+		value, err := json.Marshal(domain.Envelope{ID: "e", Type: action, SchemaVersion: domain.EventSchemaVersion, Payload: domain.Event{Record: record}})
+		require.NoError(t, err)
+		m := kafka.Message{Key: []byte(record.ID), Value: value}
+		if headers {
+			m.Headers = []kafka.Header{
+				{Key: kafkaa.HeaderContentType, Value: []byte(standartjson.ContentType)},
+				{Key: kafkaa.HeaderAction, Value: []byte(action)},
+			}
+		}
+		return m
+	}
+	cases := []struct {
+		name    string
+		message kafka.Message
+		actions []domain.Action
+	}{
+		{
+			name:    "delete",
+			message: message(domain.ActionDelete, true),
+			actions: []domain.Action{domain.ActionDelete},
+		},
+		{
+			name:    "delete without headers",
+			message: message(domain.ActionDelete, false),
+		},
+		{
+			name:    "update",
+			message: message(domain.ActionUpdate, true),
+		},
+		{
+			name:    "edit",
+			message: message(domain.ActionEdit, false),
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			t.Parallel()
+			change := &mockChangeHandler{}
+			r := &Router{
+				Config: &Config{
+					Decoders: map[string]Decoder{standartjson.ContentType: json},
+					Fallback: json,
+					Upcaster: upcaster.New(),
+				},
+				Handlers: &Handlers{update: &mockUpdateHandler{}, change: change},
+			}
+			r.Preempt(context.Background(), cs.message)
+			assert.Equal(t, cs.actions, change.actions)
+		})
+	}
+}
@@ -4,4 +4,7 @@ type Action string
 
 var (
 	ActionUpdate Action = "update"
+	ActionEdit   Action = "edit"
+	ActionDelete Action = "delete"
+	ActionStatus Action = "status"
 )
@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var errMalformedID = errors.New("domain: malformed id")
+
+type ID string
+
+// IDs marshal as JSON strings because snowflakes run past 2^53 and lose
+// precision as JSON numbers; bare numbers from older logs and producers are
+// still accepted.
+func (id *ID) UnmarshalJSON(data []byte) error {
+	raw := string(data)
+	if raw == "null" {
+		return nil
+	}
+	if strings.HasPrefix(raw, `"`) {
+		s, err := strconv.Unquote(raw)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errMalformedID, err)
+		}
+		*id = ID(s)
+		return nil
+	}
+	if _, err := strconv.ParseUint(raw, 10, 64); err != nil {
+		return fmt.Errorf("%w: %v", errMalformedID, err)
+	}
+	*id = ID(raw)
+	return nil
+}
@@ -0,0 +1,234 @@
+package avroevent
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"service2/internal/domain"
+
+	"github.com/hamba/avro/v2"
+)
+
+const ContentType = "application/avro"
+
+const Schema = `{
+	"type": "record",
+	"name": "Envelope",
+	"namespace": "taskmaster",
+	"fields": [
+		{"name": "id", "type": "string"},
+		{"name": "type", "type": "string"},
+		{"name": "schema_version", "type": "int"},
+		{"name": "occurred_at", "type": "long"},
+		{"name": "producer", "type": "string"},
+		{"name": "payload", "type": ` + EventSchema + `}
+	]
+}`
+
+const EventSchema = `{
+	"type": "record",
+	"name": "Event",
+	"namespace": "taskmaster",
+	"fields": [
+		{"name": "record", "type": {
+			"type": "record",
+			"name": "Record",
+			"fields": [
+				{"name": "id", "type": "string"},
+				{"name": "title", "type": "string"},
+				{"name": "created_at", "type": "long"},
+				{"name": "status", "type": "string"},
+				{"name": "owner", "type": "string", "default": ""}
+			]
+		}}
+	]
+}`
+
+var (
+	ErrMarshaling   = errors.New("avroevent: failed to marshal")
+	ErrUnmarshaling = errors.New("avroevent: failed to unmarshal")
+	ErrUnsupported  = errors.New("avroevent: unsupported type")
+)
+
+type envelope struct {
+	ID            string `avro:"id"`
+	Type          string `avro:"type"`
+	SchemaVersion int    `avro:"schema_version"`
+	OccurredAt    int64  `avro:"occurred_at"`
+	Producer      string `avro:"producer"`
+	Payload       event  `avro:"payload"`
+}
+
+type event struct {
+	Record record `avro:"record"`
+}
+
+type record struct {
+	ID        string `avro:"id"`
+	Title     string `avro:"title"`
+	CreatedAt int64  `avro:"created_at"`
+	Status    string `avro:"status"`
+	Owner     string `avro:"owner"`
+}
+
+type Avro struct {
+	schema      avro.Schema
+	eventSchema avro.Schema
+
+	mu       sync.Mutex
+	resolved map[resolution]avro.Schema
+}
+
+type resolution struct {
+	reader avro.Schema
+	writer string
+}
+
+func New() *Avro {
+	return &Avro{
+		schema:      avro.MustParse(Schema),
+		eventSchema: avro.MustParse(EventSchema),
+		resolved:    make(map[resolution]avro.Schema),
+	}
+}
+
+func (a *Avro) ContentType() string {
+	return ContentType
+}
+
+func (a *Avro) Marshal(data any) ([]byte, error) {
+	var (
+		d   []byte
+		err error
+	)
+	switch v := data.(type) {
+	case domain.Envelope:
+		d, err = avro.Marshal(a.schema, fromEnvelope(v))
+	case *domain.Envelope:
+		d, err = avro.Marshal(a.schema, fromEnvelope(*v))
+	case domain.Event:
+		d, err = avro.Marshal(a.eventSchema, fromEvent(v))
+	case *domain.Event:
+		d, err = avro.Marshal(a.eventSchema, fromEvent(*v))
+	default:
+		return nil, fmt.Errorf("%w: %v: %T", ErrMarshaling, ErrUnsupported, data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMarshaling, err)
+	}
+	return d, nil
+}
+
+func (a *Avro) Unmarshal(data []byte, v any) error {
+	reader, err := a.reader(v)
+	if err != nil {
+		return err
+	}
+	return unmarshal(reader, data, v)
+}
+
+// UnmarshalResolved decodes data written with the writer schema, resolving
+// fields added or dropped by other producers by name and default instead of
+// by position.
+func (a *Avro) UnmarshalResolved(writer string, data []byte, v any) error {
+	reader, err := a.reader(v)
+	if err != nil {
+		return err
+	}
+	schema, err := a.resolve(reader, writer)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnmarshaling, err)
+	}
+	return unmarshal(schema, data, v)
+}
+
+func (a *Avro) reader(v any) (avro.Schema, error) {
+	switch v.(type) {
+	case *domain.Envelope:
+		return a.schema, nil
+	case *domain.Event:
+		return a.eventSchema, nil
+	default:
+		return nil, fmt.Errorf("%w: %v: %T", ErrUnmarshaling, ErrUnsupported, v)
+	}
+}
+
+func (a *Avro) resolve(reader avro.Schema, writer string) (avro.Schema, error) {
+	key := resolution{reader: reader, writer: writer}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if schema, ok := a.resolved[key]; ok {
+		return schema, nil
+	}
+	parsed, err := avro.Parse(writer)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := avro.NewSchemaCompatibility().Resolve(reader, parsed)
+	if err != nil {
+		return nil, err
+	}
+	a.resolved[key] = schema
+	return schema, nil
+}
+
+func unmarshal(schema avro.Schema, data []byte, v any) error {
+	switch target := v.(type) {
+	case *domain.Envelope:
+		var e envelope
+		if err := avro.Unmarshal(schema, data, &e); err != nil {
+			return fmt.Errorf("%w: %v", ErrUnmarshaling, err)
+		}
+		*target = toEnvelope(e)
+	case *domain.Event:
+		var e event
+		if err := avro.Unmarshal(schema, data, &e); err != nil {
+			return fmt.Errorf("%w: %v", ErrUnmarshaling, err)
+		}
+		*target = toEvent(e)
+	}
+	return nil
+}
+
+func fromEnvelope(e domain.Envelope) envelope {
+	return envelope{
+		ID:            e.ID,
+		Type:          string(e.Type),
+		SchemaVersion: e.SchemaVersion,
+		OccurredAt:    e.OccurredAt,
+		Producer:      e.Producer,
+		Payload:       fromEvent(e.Payload),
+	}
+}
+
+func toEnvelope(e envelope) domain.Envelope {
+	return domain.Envelope{
+		ID:            e.ID,
+		Type:          domain.Action(e.Type),
+		SchemaVersion: e.SchemaVersion,
+		OccurredAt:    e.OccurredAt,
+		Producer:      e.Producer,
+		Payload:       toEvent(e.Payload),
+	}
+}
+
+func fromEvent(e domain.Event) event {
+	return event{Record: record{
+		ID:        string(e.Record.ID),
+		Title:     e.Record.Title,
+		CreatedAt: e.Record.CreatedAt,
+		Status:    string(e.Record.Status),
+		Owner:     e.Record.Owner,
+	}}
+}
+
+func toEvent(e event) domain.Event {
+	return domain.Event{Record: domain.Record{
+		ID:        domain.ID(e.Record.ID),
+		Title:     e.Record.Title,
+		CreatedAt: e.Record.CreatedAt,
+		Status:    domain.Status(e.Record.Status),
+		Owner:     e.Record.Owner,
+	}}
+}
@@ -0,0 +1,127 @@
+package avroevent
+
+import (
+	"testing"
+
+	"service2/internal/domain"
+
+	"github.com/hamba/avro/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Avro_Unit(t *testing.T) {
+	t.Parallel()
+	event := domain.Event{Record: domain.Record{ID: "1", Title: "Title", CreatedAt: 1700000000, Status: domain.StatusPending, Owner: "alice"}}
+	envelope := domain.Envelope{
+		ID:            "1-1",
+		Type:          domain.ActionUpdate,
+		SchemaVersion: domain.EventSchemaVersion,
+		OccurredAt:    1700000000,
+		Producer:      "service1",
+		Payload:       event,
+	}
+	cases := []struct {
+		name   string
+		data   any
+		target any
+		result any
+		err    error
+	}{
+		{
+			name:   "envelope",
+			data:   envelope,
+			target: &domain.Envelope{},
+			result: &envelope,
+		},
+		{
+			name:   "envelope pointer",
+			data:   &envelope,
+			target: &domain.Envelope{},
+			result: &envelope,
+		},
+		{
+			name:   "legacy event",
+			data:   event,
+			target: &domain.Event{},
+			result: &event,
+		},
+		{
+			name:   "legacy event pointer",
+			data:   &event,
+			target: &domain.Event{},
+			result: &event,
+		},
+		{
+			name: "unsupported",
+			data: domain.Record{ID: "1"},
+			err:  ErrMarshaling,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			t.Parallel()
+			a := New()
+			data, err := a.Marshal(cs.data)
+			if cs.err != nil {
+				assert.ErrorIs(t, err, cs.err)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, a.Unmarshal(data, cs.target))
+			assert.Equal(t, cs.result, cs.target)
+		})
+	}
+}
+
+func Test_Unmarshal_Unit(t *testing.T) {
+	t.Parallel()
+	a := New()
+	var event domain.Event
+	assert.ErrorIs(t, a.Unmarshal([]byte{0x02}, &event), ErrUnmarshaling)
+	assert.ErrorIs(t, a.Unmarshal(nil, &domain.Record{}), ErrUnmarshaling)
+	assert.Equal(t, ContentType, a.ContentType())
+}
+
+func Test_UnmarshalResolved_Unit(t *testing.T) {
+	t.Parallel()
+	writer := `{
+		"type": "record",
+		"name": "Event",
+		"namespace": "taskmaster",
+		"fields": [
+			{"name": "record", "type": {
+				"type": "record",
+				"name": "Record",
+				"fields": [
+					{"name": "id", "type": "string"},
+					{"name": "priority", "type": "int"},
+					{"name": "title", "type": "string"},
+					{"name": "created_at", "type": "long"},
+					{"name": "status", "type": "string"}
+				]
+			}}
+		]
+	}`
+	type older struct {
+		ID        string `avro:"id"`
+		Priority  int    `avro:"priority"`
+		Title     string `avro:"title"`
+		CreatedAt int64  `avro:"created_at"`
+		Status    string `avro:"status"`
+	}
+	data, err := avro.Marshal(avro.MustParse(writer), struct {
+		Record older `avro:"record"`
+	}{Record: older{ID: "1", Priority: 3, Title: "Title", CreatedAt: 1700000000, Status: "pending"}})
+	require.NoError(t, err)
+
+	a := New()
+	var event domain.Event
+	require.NoError(t, a.UnmarshalResolved(writer, data, &event))
+	assert.Equal(t, domain.Event{Record: domain.Record{ID: "1", Title: "Title", CreatedAt: 1700000000, Status: domain.StatusPending}}, event)
+	require.NoError(t, a.UnmarshalResolved(writer, data, &event))
+	assert.Len(t, a.resolved, 1)
+
+	assert.ErrorIs(t, a.UnmarshalResolved(`{"type": "string"}`, data, &event), ErrUnmarshaling)
+	assert.ErrorIs(t, a.UnmarshalResolved(writer, data, &domain.Record{}), ErrUnmarshaling)
+}
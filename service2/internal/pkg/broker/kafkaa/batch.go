@@ -0,0 +1,126 @@
+package kafkaa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"service2/internal/pkg/tracing"
+
+	"github.com/segmentio/kafka-go"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// BatchHandler is opt-in: handlers that implement it receive up to BatchSize
+// messages from one lane at a time. Offsets advance only once the whole batch
+// is done. RouteBatch reports a failure part way through as a *BatchError, so
+// retries resume at the failed message; a batch that keeps failing is replayed
+// from there message by message through Route, so a single poison message is
+// dead-lettered instead of the whole batch. Each message carries its own trace
+// and request ID headers, which RouteBatch must extract itself.
+type BatchHandler interface {
+	RouteBatch(ctx context.Context, messages []kafka.Message) error
+}
+
+// BatchError marks the message RouteBatch failed on; the messages before Index
+// are done and are not routed again.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("message %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+func (c *Consumer) batchHandler() (BatchHandler, bool) {
+	handler, ok := c.handler.(BatchHandler)
+	return handler, ok && c.config.BatchSize > 1
+}
+
+func (c *Consumer) collect(lane <-chan kafka.Message) []kafka.Message {
+	message, ok := <-lane
+	if !ok {
+		return nil
+	}
+	batch := []kafka.Message{message}
+	window := time.NewTimer(c.config.BatchWindow)
+	defer window.Stop()
+	for len(batch) < c.config.BatchSize {
+		select {
+		case message, ok := <-lane:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, message)
+		case <-window.C:
+			return batch
+		}
+	}
+	return batch
+}
+
+func (c *Consumer) handleBatch(ctx context.Context, handler BatchHandler, batch []kafka.Message) error {
+	links := make([]trace.Link, 0, len(batch))
+	for _, message := range batch {
+		links = append(links, trace.LinkFromContext(tracing.ExtractHeaders(ctx, message.Headers)))
+	}
+	ctx, span := tracing.Start(ctx, batch[0].Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeProcess,
+			semconv.MessagingDestinationName(batch[0].Topic),
+			semconv.MessagingBatchMessageCount(len(batch)),
+		),
+	)
+	err := c.processBatch(ctx, handler, batch)
+	tracing.End(span, err)
+	return err
+}
+
+func (c *Consumer) processBatch(ctx context.Context, handler BatchHandler, batch []kafka.Message) error {
+	for attempts := 1; attempts <= c.config.HandlerRetryAmount+1; attempts++ {
+		if attempts > 1 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
+			case <-time.After(c.backoff(attempts - 1)):
+			}
+		}
+		routeErr := handler.RouteBatch(ctx, batch)
+		if routeErr == nil {
+			return nil
+		}
+		batch = batch[done(routeErr, len(batch)):]
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
+		}
+		c.logger.WarnContext(ctx, "batch", slog.Int("size", len(batch)), slog.Int("attempt", attempts), slog.Any("error", routeErr))
+		if errors.Is(routeErr, ErrUnprocessable) {
+			break
+		}
+	}
+	for _, message := range batch {
+		if err := c.handle(ctx, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func done(err error, size int) int {
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		return min(max(batchErr.Index, 0), size)
+	}
+	return 0
+}
@@ -0,0 +1,184 @@
+package kafkaa
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockBatchHandler struct {
+	mockHandler
+	errs    []error
+	batches [][]kafka.Message
+}
+
+func (m *mockBatchHandler) RouteBatch(ctx context.Context, messages []kafka.Message) error {
+	m.batches = append(m.batches, messages)
+	if len(m.errs) == 0 {
+		return nil
+	}
+	err := m.errs[0]
+	m.errs = m.errs[1:]
+	return err
+}
+
+func Test_collect_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name   string
+		queued int
+		closed bool
+		sizes  []int
+	}{
+		{
+			name:   "full batches",
+			queued: 6,
+			closed: true,
+			sizes:  []int{3, 3},
+		},
+		{
+			name:   "partial batch on close",
+			queued: 4,
+			closed: true,
+			sizes:  []int{3, 1},
+		},
+		{
+			name:   "partial batch on window",
+			queued: 2,
+			sizes:  []int{2},
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			t.Parallel()
+			consumer := &Consumer{config: Config{BatchSize: 3, BatchWindow: 10 * time.Millisecond}}
+			lane := make(chan kafka.Message, cs.queued)
+			for i := range cs.queued {
+				lane <- kafka.Message{Offset: int64(i)}
+			}
+			if cs.closed {
+				close(lane)
+			}
+			var sizes []int
+			for range cs.sizes {
+				sizes = append(sizes, len(consumer.collect(lane)))
+			}
+			assert.Equal(t, cs.sizes, sizes)
+			if cs.closed {
+				assert.Nil(t, consumer.collect(lane))
+			}
+		})
+	}
+}
+
+func Test_processBatch_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		handler *mockBatchHandler
+		batches int
+		routed  int
+		dead    int
+	}{
+		{
+			name:    "success",
+			handler: &mockBatchHandler{},
+			batches: 1,
+		},
+		{
+			name:    "success after retry",
+			handler: &mockBatchHandler{errs: []error{errors.New("")}},
+			batches: 2,
+		},
+		{
+			name:    "retries exhausted falls back to single messages",
+			handler: &mockBatchHandler{errs: []error{errors.New("a"), errors.New("b"), errors.New("c")}},
+			batches: 3,
+			routed:  2,
+		},
+		{
+			name:    "unprocessable isolates the poison message",
+			handler: &mockBatchHandler{errs: []error{ErrUnprocessable}, mockHandler: mockHandler{errs: []error{ErrUnprocessable}}},
+			batches: 1,
+			routed:  2,
+			dead:    1,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			t.Parallel()
+			writer := &mockWriter{}
+			consumer := &Consumer{
+				config:     Config{HandlerRetryAmount: 2, BatchSize: 2},
+				deadLetter: writer,
+				handler:    cs.handler,
+				logger:     discard,
+			}
+			handler, ok := consumer.batchHandler()
+			assert.True(t, ok)
+			batch := []kafka.Message{{Topic: "tasks", Offset: 1}, {Topic: "tasks", Offset: 2}}
+			assert.NoError(t, consumer.processBatch(context.Background(), handler, batch))
+			assert.Len(t, cs.handler.batches, cs.batches)
+			assert.Equal(t, cs.routed, cs.handler.routed)
+			assert.Len(t, writer.messages, cs.dead)
+		})
+	}
+}
+
+func Test_processBatch_Resume_Unit(t *testing.T) {
+	t.Parallel()
+	handler := &mockBatchHandler{errs: []error{
+		&BatchError{Index: 1, Err: errors.New("")},
+		&BatchError{Index: 0, Err: errors.New("")},
+		&BatchError{Index: 0, Err: errors.New("")},
+	}}
+	consumer := &Consumer{
+		config:     Config{HandlerRetryAmount: 2, BatchSize: 3},
+		deadLetter: &mockWriter{},
+		handler:    handler,
+		logger:     discard,
+	}
+	batch := []kafka.Message{{Topic: "tasks", Offset: 1}, {Topic: "tasks", Offset: 2}, {Topic: "tasks", Offset: 3}}
+	assert.NoError(t, consumer.processBatch(context.Background(), handler, batch))
+	assert.Equal(t, [][]kafka.Message{batch, batch[1:], batch[1:]}, handler.batches)
+	assert.Equal(t, 2, handler.routed)
+}
+
+func Test_batchHandler_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		handler Handler
+		size    int
+		result  bool
+	}{
+		{
+			name:    "batch handler",
+			handler: &mockBatchHandler{},
+			size:    10,
+			result:  true,
+		},
+		{
+			name:    "single message handler",
+			handler: &mockHandler{},
+			size:    10,
+			result:  false,
+		},
+		{
+			name:    "batching disabled",
+			handler: &mockBatchHandler{},
+			size:    1,
+			result:  false,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			_, ok := (&Consumer{config: Config{BatchSize: cs.size}, handler: cs.handler}).batchHandler()
+			assert.Equal(t, cs.result, ok)
+		})
+	}
+}
@@ -4,11 +4,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"service2/internal/pkg/logger"
+	"service2/internal/pkg/tracing"
+
 	"github.com/segmentio/kafka-go"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -19,6 +27,19 @@ var (
 	ErrCommitting       = errors.New("kafka: failed to commit offset")
 	ErrClosingConsumer  = errors.New("kafka: failed to close consumer")
 	ErrTooManyRetries   = errors.New("kafka: too many retries")
+	ErrUnprocessable    = errors.New("kafka: message can't be processed")
+	ErrDeadLettering    = errors.New("kafka: failed to forward message to dead-letter topic")
+)
+
+const (
+	HeaderFailureReason     = "x-failure-reason"
+	HeaderAttempts          = "x-attempts"
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderRequestID         = "x-request-id"
+	HeaderContentType       = "content-type"
+	HeaderAction            = "action"
 )
 
 type Config struct {
@@ -33,8 +54,18 @@ type Config struct {
 	RetryAmount     int           `yaml:"retry_amount"`
 	WorkerCount     int           `yaml:"worker_count"`
 	JobsMultiplier  int           `yaml:"jobs_multiplier"`
+	Dispatch        string        `yaml:"dispatch"`
+
+	BatchSize   int           `yaml:"batch_size"`
+	BatchWindow time.Duration `yaml:"batch_window"`
+
+	HandlerRetryAmount int           `yaml:"handler_retry_amount"`
+	HandlerBackoff     time.Duration `yaml:"handler_backoff"`
+	HandlerMaxBackoff  time.Duration `yaml:"handler_max_backoff"`
+	DeadLetterTopic    string        `yaml:"dead_letter_topic"`
 
 	Handler Handler
+	Logger  *slog.Logger
 }
 
 type Reader interface {
@@ -52,14 +83,34 @@ type Reader interface {
 }
 
 type Handler interface {
-	Route(ctx context.Context, message kafka.Message)
+	Route(ctx context.Context, message kafka.Message) error
+}
+
+// Preempter lets a handler act on a message before it waits behind earlier
+// messages in its lane, e.g. to cancel work a delete makes obsolete. It runs
+// on the fetch loop and must not block.
+type Preempter interface {
+	Preempt(ctx context.Context, message kafka.Message)
+}
+
+type WorkerStats struct {
+	Workers int
+	Busy    int
+	Queued  int
 }
 
 type Consumer struct {
 	config Config
 
-	reader  Reader
-	handler Handler
+	reader     Reader
+	deadLetter Writer
+	cluster    Cluster
+	handler    Handler
+	logger     *slog.Logger
+
+	running atomic.Bool
+	busy    atomic.Int64
+	queued  atomic.Int64
 }
 
 func New(c Config) *Consumer {
@@ -77,50 +128,69 @@ func New(c Config) *Consumer {
 		SessionTimeout: c.SessionTimeout,
 		StartOffset:    int64(c.StartOffset),
 	})
+	deadLetter := &kafka.Writer{
+		Addr:                   kafka.TCP(c.Brokers...),
+		Topic:                  c.DeadLetterTopic,
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+	}
 	return &Consumer{
-		config:  c,
-		reader:  reader,
-		handler: c.Handler,
+		config:     c,
+		reader:     reader,
+		deadLetter: deadLetter,
+		cluster:    newCluster(c.Brokers),
+		handler:    c.Handler,
+		logger:     c.Logger,
 	}
 }
 
 func (c *Consumer) Run(ctx context.Context) error {
-	jobs := make(chan kafka.Message, c.config.WorkerCount*c.config.JobsMultiplier)
+	c.running.Store(true)
+	defer c.running.Store(false)
+
+	jobs := newDispatcher(c.config.Dispatch, c.config.WorkerCount, c.config.JobsMultiplier)
+	commits := make(chan kafka.Message, c.config.WorkerCount*c.config.JobsMultiplier)
 	done := make(chan struct{})
 	var wg sync.WaitGroup
 	workerCtx, workerCancel := context.WithCancel(context.Background())
+	tracker := newOffsetTracker()
 
 	defer func() {
-		if jobs != nil {
-			close(jobs)
-		}
+		jobs.close()
 		select {
 		case <-time.After(c.config.ShutdownTimeout):
 			workerCancel()
 		case <-done:
-			// WORKERS DRAINED
+			// WORKERS DRAINED, OFFSETS COMMITTED
+			workerCancel()
 		}
 	}()
 
+	committed := make(chan struct{})
+	go func() {
+		defer close(committed)
+		c.commit(workerCtx, commits)
+	}()
+
 	go func() {
 		defer func() {
 			wg.Wait()
+			close(commits)
+			<-committed
 			close(done)
 		}()
-		for w := 0; w < c.config.WorkerCount; w++ {
+		for _, lane := range jobs.lanes {
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
-				for message := range jobs {
-					c.handler.Route(workerCtx, message)
-				}
+				c.work(workerCtx, lane, tracker, commits)
 			}()
 		}
 	}()
 
 	backoff := time.Second * 0
 	for a := 0; a <= c.config.RetryAmount; a++ {
-		if consErr := c.consume(ctx, jobs, backoff); consErr != nil {
+		if consErr := c.consume(ctx, jobs, tracker, backoff); consErr != nil {
 			if errors.Is(consErr, ErrOperationCanceled) {
 				return consErr
 			}
@@ -133,7 +203,7 @@ func (c *Consumer) Run(ctx context.Context) error {
 			default:
 				backoff *= 2
 			}
-			log.Println(consErr)
+			c.logger.WarnContext(ctx, "consume", slog.Int("attempt", a+1), slog.Duration("backoff", backoff), slog.Any("error", consErr))
 			continue
 		}
 		a = 0
@@ -143,7 +213,39 @@ func (c *Consumer) Run(ctx context.Context) error {
 	return nil
 }
 
-func (c *Consumer) consume(ctx context.Context, jobs chan kafka.Message, backoff time.Duration) error {
+func (c *Consumer) work(ctx context.Context, lane <-chan kafka.Message, tracker *offsetTracker, commits chan<- kafka.Message) {
+	if handler, ok := c.batchHandler(); ok {
+		for batch := c.collect(lane); batch != nil; batch = c.collect(lane) {
+			c.queued.Add(-int64(len(batch)))
+			c.busy.Add(1)
+			handleErr := c.handleBatch(ctx, handler, batch)
+			c.busy.Add(-1)
+			if handleErr != nil {
+				continue
+			}
+			for _, message := range batch {
+				if commit, ok := tracker.complete(message); ok {
+					commits <- commit
+				}
+			}
+		}
+		return
+	}
+	for message := range lane {
+		c.queued.Add(-1)
+		c.busy.Add(1)
+		handleErr := c.handle(ctx, message)
+		c.busy.Add(-1)
+		if handleErr != nil {
+			continue
+		}
+		if commit, ok := tracker.complete(message); ok {
+			commits <- commit
+		}
+	}
+}
+
+func (c *Consumer) consume(ctx context.Context, jobs *dispatcher, tracker *offsetTracker, backoff time.Duration) error {
 	select {
 	case <-ctx.Done():
 		return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
@@ -157,26 +259,164 @@ func (c *Consumer) consume(ctx context.Context, jobs chan kafka.Message, backoff
 				return fmt.Errorf("%w: %v", ErrFetchingMessages, fetchErr)
 			}
 		}
+		tracker.track(message)
+		if preempter, ok := c.handler.(Preempter); ok {
+			preempter.Preempt(ctx, message)
+		}
 		select {
 		case <-ctx.Done():
 			return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
-		case jobs <- message:
-			if commitErr := c.reader.CommitMessages(ctx, message); commitErr != nil {
-				switch {
-				case errors.Is(commitErr, context.Canceled):
-					return fmt.Errorf("%w: %v", ErrOperationCanceled, commitErr)
-				default:
-					return fmt.Errorf("%w: %v", ErrCommitting, commitErr)
-				}
-			}
+		case jobs.lane(message) <- message:
+			c.queued.Add(1)
 		}
 		return nil
 	}
 }
 
+func (c *Consumer) handle(ctx context.Context, message kafka.Message) error {
+	ctx = tracing.ExtractHeaders(ctx, message.Headers)
+	ctx, span := tracing.Start(ctx, message.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeProcess,
+			semconv.MessagingDestinationName(message.Topic),
+			semconv.MessagingDestinationPartitionID(strconv.Itoa(message.Partition)),
+			semconv.MessagingKafkaOffset(int(message.Offset)),
+			semconv.MessagingKafkaMessageKey(string(message.Key)),
+		),
+	)
+	err := c.process(ctx, message)
+	tracing.End(span, err)
+	return err
+}
+
+func (c *Consumer) process(ctx context.Context, message kafka.Message) error {
+	var routeErr error
+	var attempts int
+	for attempts < c.config.HandlerRetryAmount+1 {
+		if attempts > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
+			case <-time.After(c.backoff(attempts)):
+			}
+		}
+		attempts++
+		routeErr = c.handler.Route(ctx, message)
+		if routeErr == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
+		}
+		if errors.Is(routeErr, ErrUnprocessable) {
+			break
+		}
+	}
+	return c.forward(ctx, message, routeErr, attempts)
+}
+
+func (c *Consumer) forward(ctx context.Context, message kafka.Message, reason error, attempts int) error {
+	headers := append(slices.Clone(message.Headers),
+		kafka.Header{Key: HeaderFailureReason, Value: []byte(reason.Error())},
+		kafka.Header{Key: HeaderAttempts, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: HeaderOriginalTopic, Value: []byte(message.Topic)},
+		kafka.Header{Key: HeaderOriginalPartition, Value: []byte(strconv.Itoa(message.Partition))},
+		kafka.Header{Key: HeaderOriginalOffset, Value: []byte(strconv.FormatInt(message.Offset, 10))},
+	)
+	dead := kafka.Message{
+		Key:     message.Key,
+		Value:   message.Value,
+		Headers: headers,
+		Time:    time.Now(),
+	}
+	for a := 1; ; a++ {
+		writeErr := c.deadLetter.WriteMessages(ctx, dead)
+		if writeErr == nil {
+			c.logger.WarnContext(ctx, "message dead-lettered",
+				slog.String("topic", message.Topic),
+				slog.Int("partition", message.Partition),
+				slog.Int64("offset", message.Offset),
+				slog.String(logger.KeyRequestID, Header(message, HeaderRequestID)),
+				slog.Int("attempts", attempts),
+				slog.Any("error", fmt.Errorf("%w: %v", ErrUnprocessable, reason)),
+			)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
+		}
+		c.logger.ErrorContext(ctx, "dead-letter", slog.Int64("offset", message.Offset), slog.Any("error", fmt.Errorf("%w: %v", ErrDeadLettering, writeErr)))
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
+		case <-time.After(c.backoff(a)):
+		}
+	}
+}
+
+func (c *Consumer) backoff(attempts int) time.Duration {
+	backoff := c.config.HandlerBackoff
+	for a := 1; a < attempts && backoff < c.config.HandlerMaxBackoff; a++ {
+		backoff *= 2
+	}
+	return min(backoff, c.config.HandlerMaxBackoff)
+}
+
+func (c *Consumer) commit(ctx context.Context, commits <-chan kafka.Message) {
+	last := make(map[partition]int64)
+	for message := range commits {
+		p := partitionOf(message)
+		if offset, ok := last[p]; ok && message.Offset <= offset {
+			continue
+		}
+		if commitErr := c.reader.CommitMessages(ctx, message); commitErr != nil {
+			c.logger.ErrorContext(ctx, "commit", slog.Int64("offset", message.Offset), slog.Any("error", fmt.Errorf("%w: %v", ErrCommitting, commitErr)))
+			continue
+		}
+		last[p] = message.Offset
+	}
+}
+
+func (c *Consumer) Stats() kafka.ReaderStats {
+	return c.reader.Stats()
+}
+
+func (c *Consumer) DeadLetterStats() kafka.WriterStats {
+	return c.deadLetter.Stats()
+}
+
+func (c *Consumer) WorkerStats() WorkerStats {
+	return WorkerStats{
+		Workers: c.config.WorkerCount,
+		Busy:    int(c.busy.Load()),
+		Queued:  int(max(c.queued.Load(), 0)),
+	}
+}
+
+func (c *Consumer) Ping(ctx context.Context) error {
+	if !c.running.Load() {
+		return fmt.Errorf("%w", ErrNotStarted)
+	}
+	return joined(ctx, c.cluster, c.config.GroupID)
+}
+
 func (c *Consumer) Shutdown() error {
 	if err := c.reader.Close(); err != nil {
 		return fmt.Errorf("%w: %v", ErrClosingConsumer, err)
 	}
+	if err := c.deadLetter.Close(); err != nil {
+		return fmt.Errorf("%w: %v", ErrClosingConsumer, err)
+	}
 	return nil
 }
+
+func Header(message kafka.Message, key string) string {
+	for _, h := range message.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,228 @@
+package kafkaa
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+var discard = slog.New(slog.DiscardHandler)
+
+type mockHandler struct {
+	errs   []error
+	routed int
+}
+
+func (m *mockHandler) Route(ctx context.Context, message kafka.Message) error {
+	m.routed++
+	if len(m.errs) == 0 {
+		return nil
+	}
+	err := m.errs[0]
+	m.errs = m.errs[1:]
+	return err
+}
+
+type mockWriter struct {
+	messages []kafka.Message
+	err      error
+}
+
+func (m *mockWriter) Close() error {
+	return nil
+}
+
+func (m *mockWriter) Stats() kafka.WriterStats {
+	return kafka.WriterStats{}
+}
+
+func (m *mockWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.messages = append(m.messages, msgs...)
+	return nil
+}
+
+type mockReader struct {
+	Reader
+	messages chan kafka.Message
+}
+
+func (m *mockReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	case message := <-m.messages:
+		return message, nil
+	}
+}
+
+func (m *mockReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	return nil
+}
+
+type mockPreemptHandler struct {
+	mu       sync.Mutex
+	inflight map[string]context.CancelFunc
+	started  chan struct{}
+	canceled chan struct{}
+}
+
+func (m *mockPreemptHandler) Route(ctx context.Context, message kafka.Message) error {
+	if Header(message, HeaderAction) != "update" {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	m.mu.Lock()
+	m.inflight[string(message.Key)] = cancel
+	m.mu.Unlock()
+	close(m.started)
+	select {
+	case <-ctx.Done():
+		close(m.canceled)
+	case <-time.After(time.Second):
+	}
+	m.mu.Lock()
+	delete(m.inflight, string(message.Key))
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *mockPreemptHandler) Preempt(ctx context.Context, message kafka.Message) {
+	if Header(message, HeaderAction) != "delete" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cancel, ok := m.inflight[string(message.Key)]; ok {
+		cancel()
+	}
+}
+
+func header(message kafka.Message, key string) string {
+	for _, h := range message.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func Test_handle_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		ctx      context.Context
+		handler  *mockHandler
+		routed   int
+		attempts string
+		err      error
+	}{
+		{
+			name:     "success",
+			ctx:      context.Background(),
+			handler:  &mockHandler{},
+			routed:   1,
+			attempts: "",
+			err:      nil,
+		},
+		{
+			name:     "success after retry",
+			ctx:      context.Background(),
+			handler:  &mockHandler{errs: []error{errors.New("")}},
+			routed:   2,
+			attempts: "",
+			err:      nil,
+		},
+		{
+			name:     "retries exhausted",
+			ctx:      context.Background(),
+			handler:  &mockHandler{errs: []error{errors.New("a"), errors.New("b"), errors.New("c")}},
+			routed:   3,
+			attempts: "3",
+			err:      nil,
+		},
+		{
+			name:     "unprocessable skips retries",
+			ctx:      context.Background(),
+			handler:  &mockHandler{errs: []error{ErrUnprocessable}},
+			routed:   1,
+			attempts: "1",
+			err:      nil,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			writer := &mockWriter{}
+			consumer := &Consumer{
+				config:     Config{HandlerRetryAmount: 2},
+				deadLetter: writer,
+				handler:    cs.handler,
+				logger:     discard,
+			}
+			err := consumer.handle(cs.ctx, kafka.Message{Topic: "tasks", Partition: 1, Offset: 42})
+			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.routed, cs.handler.routed)
+			if cs.attempts == "" {
+				assert.Empty(t, writer.messages)
+				return
+			}
+			assert.Len(t, writer.messages, 1)
+			dead := writer.messages[0]
+			assert.Equal(t, cs.attempts, header(dead, HeaderAttempts))
+			assert.Equal(t, "tasks", header(dead, HeaderOriginalTopic))
+			assert.Equal(t, "1", header(dead, HeaderOriginalPartition))
+			assert.Equal(t, "42", header(dead, HeaderOriginalOffset))
+			assert.NotEmpty(t, header(dead, HeaderFailureReason))
+		})
+	}
+}
+
+func Test_forward_Unit(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	consumer := &Consumer{deadLetter: &mockWriter{err: errors.New("")}, logger: discard}
+	err := consumer.forward(ctx, kafka.Message{}, errors.New(""), 1)
+	assert.ErrorIs(t, err, ErrOperationCanceled)
+}
+
+func Test_Run_Preempt_Unit(t *testing.T) {
+	t.Parallel()
+	reader := &mockReader{messages: make(chan kafka.Message)}
+	handler := &mockPreemptHandler{
+		inflight: make(map[string]context.CancelFunc),
+		started:  make(chan struct{}),
+		canceled: make(chan struct{}),
+	}
+	consumer := &Consumer{
+		config:  Config{RetryAmount: 1, WorkerCount: 4, JobsMultiplier: 1, Dispatch: DispatchKey, ShutdownTimeout: time.Second},
+		reader:  reader,
+		handler: handler,
+		logger:  discard,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go consumer.Run(ctx)
+
+	action := func(a string) []kafka.Header {
+		return []kafka.Header{{Key: HeaderAction, Value: []byte(a)}}
+	}
+	reader.messages <- kafka.Message{Key: []byte("task-1"), Offset: 0, Headers: action("update")}
+	<-handler.started
+	reader.messages <- kafka.Message{Key: []byte("task-1"), Offset: 1, Headers: action("delete")}
+
+	select {
+	case <-handler.canceled:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("update was not canceled by the delete queued behind it")
+	}
+}
@@ -0,0 +1,49 @@
+package kafkaa
+
+import (
+	"hash/fnv"
+	"strconv"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	DispatchKey       = "key"
+	DispatchPartition = "partition"
+)
+
+// dispatcher hands each lane to one worker, so messages sharing a key stay in
+// fetch order; keyless messages fall back to their partition's lane.
+type dispatcher struct {
+	mode  string
+	lanes []chan kafka.Message
+}
+
+func newDispatcher(mode string, workers, depth int) *dispatcher {
+	lanes := make([]chan kafka.Message, workers)
+	for i := range lanes {
+		lanes[i] = make(chan kafka.Message, depth)
+	}
+	return &dispatcher{
+		mode:  mode,
+		lanes: lanes,
+	}
+}
+
+func (d *dispatcher) lane(message kafka.Message) chan kafka.Message {
+	h := fnv.New32a()
+	switch {
+	case d.mode == DispatchPartition || len(message.Key) == 0:
+		h.Write([]byte(message.Topic))
+		h.Write([]byte(strconv.Itoa(message.Partition)))
+	default:
+		h.Write(message.Key)
+	}
+	return d.lanes[h.Sum32()%uint32(len(d.lanes))]
+}
+
+func (d *dispatcher) close() {
+	for _, lane := range d.lanes {
+		close(lane)
+	}
+}
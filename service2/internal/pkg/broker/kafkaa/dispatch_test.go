@@ -0,0 +1,65 @@
+package kafkaa
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_dispatcher_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name   string
+		mode   string
+		a      kafka.Message
+		b      kafka.Message
+		shared bool
+	}{
+		{
+			name:   "same key across partitions",
+			mode:   DispatchKey,
+			a:      kafka.Message{Key: []byte("task-1"), Partition: 0},
+			b:      kafka.Message{Key: []byte("task-1"), Partition: 3},
+			shared: true,
+		},
+		{
+			name:   "no key falls back to partition",
+			mode:   DispatchKey,
+			a:      kafka.Message{Partition: 2},
+			b:      kafka.Message{Partition: 2, Offset: 9},
+			shared: true,
+		},
+		{
+			name:   "partition mode ignores key",
+			mode:   DispatchPartition,
+			a:      kafka.Message{Key: []byte("task-1"), Partition: 1},
+			b:      kafka.Message{Key: []byte("task-2"), Partition: 1},
+			shared: true,
+		},
+		{
+			name:   "partition mode splits partitions",
+			mode:   DispatchPartition,
+			a:      kafka.Message{Key: []byte("task-1"), Partition: 0},
+			b:      kafka.Message{Key: []byte("task-1"), Partition: 1},
+			shared: false,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			d := newDispatcher(cs.mode, 8, 1)
+			assert.Equal(t, cs.shared, d.lane(cs.a) == d.lane(cs.b))
+		})
+	}
+}
+
+func Test_dispatcher_Spread_Unit(t *testing.T) {
+	t.Parallel()
+	d := newDispatcher(DispatchKey, 4, 1)
+	used := make(map[chan kafka.Message]bool)
+	for i := range 64 {
+		used[d.lane(kafka.Message{Key: fmt.Appendf(nil, "task-%d", i)})] = true
+	}
+	assert.Len(t, used, 4)
+}
@@ -0,0 +1,30 @@
+package kafkaa
+
+import "service2/internal/domain"
+
+const (
+	KeyTaskID = "task_id"
+	KeyAction = "action"
+)
+
+type KeyExtractor func(envelope domain.Envelope) []byte
+
+func TaskID(envelope domain.Envelope) []byte {
+	return []byte(envelope.Payload.Record.ID)
+}
+
+func Action(envelope domain.Envelope) []byte {
+	return []byte(envelope.Type)
+}
+
+func extractor(override KeyExtractor, name string) KeyExtractor {
+	if override != nil {
+		return override
+	}
+	switch name {
+	case KeyAction:
+		return Action
+	default:
+		return TaskID
+	}
+}
@@ -0,0 +1,64 @@
+package kafkaa
+
+import (
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type partition struct {
+	topic string
+	id    int
+}
+
+type inflight struct {
+	message kafka.Message
+	done    bool
+}
+
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[partition][]*inflight
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{
+		partitions: make(map[partition][]*inflight),
+	}
+}
+
+func partitionOf(message kafka.Message) partition {
+	return partition{topic: message.Topic, id: message.Partition}
+}
+
+func (t *offsetTracker) track(message kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := partitionOf(message)
+	t.partitions[p] = append(t.partitions[p], &inflight{message: message})
+}
+
+func (t *offsetTracker) complete(message kafka.Message) (kafka.Message, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := partitionOf(message)
+	queue := t.partitions[p]
+	for _, f := range queue {
+		if f.message.Offset == message.Offset {
+			f.done = true
+			break
+		}
+	}
+	var commit kafka.Message
+	var ok bool
+	for len(queue) > 0 && queue[0].done {
+		commit, ok = queue[0].message, true
+		queue = queue[1:]
+	}
+	if len(queue) == 0 {
+		delete(t.partitions, p)
+	} else {
+		t.partitions[p] = queue
+	}
+	return commit, ok
+}
@@ -0,0 +1,61 @@
+package kafkaa
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_offsetTracker_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name      string
+		tracked   []kafka.Message
+		completed []kafka.Message
+		result    []int64
+	}{
+		{
+			name:      "in order",
+			tracked:   []kafka.Message{{Offset: 0}, {Offset: 1}, {Offset: 2}},
+			completed: []kafka.Message{{Offset: 0}, {Offset: 1}, {Offset: 2}},
+			result:    []int64{0, 1, 2},
+		},
+		{
+			name:      "out of order waits for the gap",
+			tracked:   []kafka.Message{{Offset: 0}, {Offset: 1}, {Offset: 2}},
+			completed: []kafka.Message{{Offset: 2}, {Offset: 1}, {Offset: 0}},
+			result:    []int64{-1, -1, 2},
+		},
+		{
+			name:      "unfinished message blocks commit",
+			tracked:   []kafka.Message{{Offset: 0}, {Offset: 1}, {Offset: 2}},
+			completed: []kafka.Message{{Offset: 1}, {Offset: 2}},
+			result:    []int64{-1, -1},
+		},
+		{
+			name:      "partitions are independent",
+			tracked:   []kafka.Message{{Partition: 0, Offset: 0}, {Partition: 1, Offset: 0}},
+			completed: []kafka.Message{{Partition: 1, Offset: 0}, {Partition: 0, Offset: 0}},
+			result:    []int64{0, 0},
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			tracker := newOffsetTracker()
+			for _, message := range cs.tracked {
+				tracker.track(message)
+			}
+			var result []int64
+			for _, message := range cs.completed {
+				commit, ok := tracker.complete(message)
+				if !ok {
+					result = append(result, -1)
+					continue
+				}
+				result = append(result, commit.Offset)
+			}
+			assert.Equal(t, cs.result, result)
+		})
+	}
+}
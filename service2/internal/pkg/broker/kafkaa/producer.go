@@ -0,0 +1,144 @@
+package kafkaa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"service2/internal/domain"
+	"service2/internal/pkg/logger"
+	"service2/internal/pkg/tracing"
+
+	"github.com/segmentio/kafka-go"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrClosingProducer = errors.New("kafka: failed to close producer")
+	ErrMarshalingEvent = errors.New("kafka: failed to prepare event")
+	ErrProducingEvent  = errors.New("kafka: failed to produce event")
+	ErrClosed          = errors.New("kafka: failed due to closed broker")
+)
+
+type ProducerConfig struct {
+	Address            []string
+	Name               string        `yaml:"name"`
+	Topic              string        `yaml:"topic"`
+	BatchTimeout       time.Duration `yaml:"batch_timeout"`
+	RequiredAcks       int           `yaml:"required_acks"`
+	AllowTopicCreation bool          `yaml:"allow_topic_creation"`
+	Key                string        `yaml:"key"`
+
+	Encoder   Encoder
+	Extractor KeyExtractor
+}
+
+type Writer interface {
+	Close() error
+	Stats() kafka.WriterStats
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+type Encoder interface {
+	Marshal(data any) ([]byte, error)
+	ContentType() string
+}
+
+type Producer struct {
+	writer  Writer
+	cluster Cluster
+	topic   string
+	name    string
+
+	key     KeyExtractor
+	encoder Encoder
+}
+
+func NewProducer(c ProducerConfig) *Producer {
+	return &Producer{
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(c.Address...),
+			Topic:                  c.Topic,
+			BatchTimeout:           c.BatchTimeout,
+			RequiredAcks:           kafka.RequiredAcks(c.RequiredAcks),
+			AllowAutoTopicCreation: c.AllowTopicCreation,
+			Balancer:               &kafka.Hash{},
+		},
+		cluster: newCluster(c.Address),
+		topic:   c.Topic,
+		name:    c.Name,
+
+		key:     extractor(c.Extractor, c.Key),
+		encoder: c.Encoder,
+	}
+}
+
+func (p *Producer) Close() error {
+	if err := p.writer.Close(); err != nil {
+		return fmt.Errorf("%w: %v", ErrClosingProducer, err)
+	}
+	return nil
+}
+
+func (p *Producer) Stats() kafka.WriterStats {
+	return p.writer.Stats()
+}
+
+func (p *Producer) Ping(ctx context.Context) error {
+	return ping(ctx, p.cluster)
+}
+
+func (p *Producer) PublishEvent(ctx context.Context, envelope domain.Envelope) error {
+	ctx, span := tracing.Start(ctx, p.topic+" send",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeSend,
+			semconv.MessagingDestinationName(p.topic),
+			semconv.MessagingKafkaMessageKey(string(p.key(envelope))),
+			semconv.MessagingMessageID(envelope.ID),
+		),
+	)
+	err := p.publish(ctx, envelope)
+	tracing.End(span, err)
+	return err
+}
+
+func (p *Producer) publish(ctx context.Context, envelope domain.Envelope) error {
+	envelope.Producer = p.name
+	eventByte, marshalErr := p.encoder.Marshal(envelope)
+	if marshalErr != nil {
+		return fmt.Errorf("%w: %v", ErrMarshalingEvent, marshalErr)
+	}
+	message := kafka.Message{
+		Key:     p.key(envelope),
+		Value:   eventByte,
+		Headers: headers(ctx, p.encoder.ContentType(), envelope.Type),
+		Time:    time.Now(),
+	}
+	tracing.InjectHeaders(ctx, &message.Headers)
+	if writeErr := p.writer.WriteMessages(ctx, message); writeErr != nil {
+		switch {
+		case errors.Is(writeErr, context.Canceled):
+			return fmt.Errorf("%w: %v", ErrOperationCanceled, writeErr)
+		case errors.Is(writeErr, kafka.ErrGroupClosed):
+			return fmt.Errorf("%w: %v", ErrClosed, writeErr)
+		default:
+			return fmt.Errorf("%w: %v", ErrProducingEvent, writeErr)
+		}
+	}
+	return nil
+}
+
+func headers(ctx context.Context, contentType string, action domain.Action) []kafka.Header {
+	h := []kafka.Header{
+		{Key: HeaderContentType, Value: []byte(contentType)},
+		{Key: HeaderAction, Value: []byte(action)},
+	}
+	if id := logger.RequestID(ctx); id != "" {
+		h = append(h, kafka.Header{Key: HeaderRequestID, Value: []byte(id)})
+	}
+	return h
+}
@@ -0,0 +1,180 @@
+package dedup
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrOperationCanceled = errors.New("dedup: operation canceled")
+
+	ErrOpening = errors.New("dedup: failed to open seen-set")
+	ErrWriting = errors.New("dedup: failed to write seen-set")
+)
+
+type Config struct {
+	Enabled       bool          `yaml:"enabled"`
+	TTL           time.Duration `yaml:"ttl"`
+	MaxEntries    int           `yaml:"max_entries"`
+	Path          string        `yaml:"path"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+
+	Logger *slog.Logger
+}
+
+type Stats struct {
+	Entries    int
+	Duplicates int64
+}
+
+type entry struct {
+	ID      string    `json:"id"`
+	Expires time.Time `json:"expires"`
+}
+
+// Set recognises an ID only within its TTL, evicting oldest first once the
+// set grows past MaxEntries.
+type Set struct {
+	config Config
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	now     func() time.Time
+
+	duplicates atomic.Int64
+}
+
+func New(c Config) (*Set, error) {
+	s := &Set{
+		config:  c,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+	if !c.Enabled || c.Path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(c.Path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return s, nil
+	case err != nil:
+		return nil, fmt.Errorf("%w: %v", ErrOpening, err)
+	}
+	var snapshot []entry
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOpening, err)
+	}
+	for _, e := range snapshot {
+		s.insert(e)
+	}
+	s.evict(s.now())
+	return s, nil
+}
+
+func (s *Set) Seen(id string) bool {
+	if !s.config.Enabled || id == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evict(s.now())
+	if _, ok := s.entries[id]; !ok {
+		return false
+	}
+	s.duplicates.Add(1)
+	return true
+}
+
+func (s *Set) Mark(id string) {
+	if !s.config.Enabled || id == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.insert(entry{ID: id, Expires: now.Add(s.config.TTL)})
+	s.evict(now)
+}
+
+func (s *Set) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Stats{
+		Entries:    s.order.Len(),
+		Duplicates: s.duplicates.Load(),
+	}
+}
+
+func (s *Set) Run(ctx context.Context) error {
+	if !s.config.Enabled || s.config.Path == "" || s.config.FlushInterval <= 0 {
+		return nil
+	}
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				s.config.Logger.WarnContext(ctx, "flush seen-set", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+func (s *Set) Flush() error {
+	if !s.config.Enabled || s.config.Path == "" {
+		return nil
+	}
+	s.mu.Lock()
+	snapshot := make([]entry, 0, s.order.Len())
+	for e := s.order.Front(); e != nil; e = e.Next() {
+		snapshot = append(snapshot, e.Value.(entry))
+	}
+	s.mu.Unlock()
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrWriting, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.config.Path), 0o755); err != nil {
+		return fmt.Errorf("%w: %v", ErrWriting, err)
+	}
+	tmp := s.config.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("%w: %v", ErrWriting, err)
+	}
+	if err := os.Rename(tmp, s.config.Path); err != nil {
+		return fmt.Errorf("%w: %v", ErrWriting, err)
+	}
+	return nil
+}
+
+func (s *Set) insert(e entry) {
+	if el, ok := s.entries[e.ID]; ok {
+		s.order.Remove(el)
+	}
+	s.entries[e.ID] = s.order.PushBack(e)
+}
+
+func (s *Set) evict(now time.Time) {
+	for el := s.order.Front(); el != nil; el = s.order.Front() {
+		e := el.Value.(entry)
+		if !now.After(e.Expires) && (s.config.MaxEntries <= 0 || s.order.Len() <= s.config.MaxEntries) {
+			return
+		}
+		s.order.Remove(el)
+		delete(s.entries, e.ID)
+	}
+}
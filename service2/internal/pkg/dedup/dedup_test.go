@@ -0,0 +1,86 @@
+package dedup
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func Test_Seen_Unit(t *testing.T) {
+	t.Parallel()
+	c := &clock{t: time.Unix(1700000000, 0)}
+	s, err := New(Config{Enabled: true, TTL: time.Minute, MaxEntries: 2})
+	require.NoError(t, err)
+	s.now = c.now
+
+	assert.False(t, s.Seen("a"))
+	s.Mark("a")
+	assert.True(t, s.Seen("a"))
+	assert.False(t, s.Seen(""))
+
+	s.Mark("b")
+	s.Mark("c")
+	assert.False(t, s.Seen("a"))
+	assert.True(t, s.Seen("b"))
+
+	c.t = c.t.Add(2 * time.Minute)
+	assert.False(t, s.Seen("c"))
+	assert.Equal(t, Stats{Entries: 0, Duplicates: 2}, s.Stats())
+
+	disabled, err := New(Config{TTL: time.Minute})
+	require.NoError(t, err)
+	disabled.Mark("a")
+	assert.False(t, disabled.Seen("a"))
+}
+
+func Test_Flush_Unit(t *testing.T) {
+	t.Parallel()
+	c := &clock{t: time.Now()}
+	config := Config{Enabled: true, TTL: time.Hour, Path: filepath.Join(t.TempDir(), "dedup", "seen.json")}
+	s, err := New(config)
+	require.NoError(t, err)
+	s.now = c.now
+	s.Mark("a")
+	s.Mark("b")
+	require.NoError(t, s.Flush())
+
+	restored, err := New(config)
+	require.NoError(t, err)
+	assert.True(t, restored.Seen("a"))
+	assert.True(t, restored.Seen("b"))
+	assert.False(t, restored.Seen("c"))
+}
+
+func Test_Run_Unit(t *testing.T) {
+	t.Parallel()
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	s, err := New(Config{
+		Enabled:       true,
+		TTL:           time.Hour,
+		Path:          filepath.Join(blocker, "seen.json"),
+		FlushInterval: time.Millisecond,
+		Logger:        slog.New(slog.DiscardHandler),
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(blocker, nil, 0o644))
+	s.Mark("a")
+	assert.ErrorIs(t, s.Flush(), ErrWriting)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Run(ctx), ErrOperationCanceled)
+}
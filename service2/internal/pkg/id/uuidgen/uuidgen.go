@@ -0,0 +1,26 @@
+package uuidgen
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrGenerating = errors.New("gen: failed to generate")
+)
+
+type Generator struct{}
+
+func New() *Generator {
+	return &Generator{}
+}
+
+func (g *Generator) Gen() (string, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrGenerating, err)
+	}
+	return id.String(), nil
+}
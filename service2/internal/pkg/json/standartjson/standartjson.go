@@ -6,6 +6,8 @@ import (
 	"fmt"
 )
 
+const ContentType = "application/json"
+
 var (
 	ErrMarshaling   = errors.New("standartjson: failed to marshal")
 	ErrUnmarshaling = errors.New("standartjson: failed to unmarshal")
@@ -17,6 +19,10 @@ func New() *JSON {
 	return &JSON{}
 }
 
+func (j *JSON) ContentType() string {
+	return ContentType
+}
+
 func (j *JSON) Marshal(data any) ([]byte, error) {
 	d, err := json.Marshal(data)
 	if err != nil {
@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name   string
+		config Config
+		err    error
+	}{
+		{
+			name:   "json",
+			config: Config{Level: "info", Format: FormatJSON},
+			err:    nil,
+		},
+		{
+			name:   "text",
+			config: Config{Level: "debug", Format: FormatText},
+			err:    nil,
+		},
+		{
+			name:   "unknown level",
+			config: Config{Level: "loud", Format: FormatJSON},
+			err:    ErrUnknownLevel,
+		},
+		{
+			name:   "unknown format",
+			config: Config{Level: "info", Format: "xml"},
+			err:    ErrUnknownFormat,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			_, err := New(cs.config, &bytes.Buffer{})
+			assert.ErrorIs(t, err, cs.err)
+		})
+	}
+}
+
+func Test_RequestID_Unit(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "", RequestID(context.Background()))
+	assert.Equal(t, "id", RequestID(WithRequestID(context.Background(), "id")))
+	assert.Equal(t, "", RequestID(WithRequestID(context.Background(), "")))
+}
@@ -0,0 +1,24 @@
+package logger
+
+import (
+	"context"
+)
+
+const (
+	KeyRequestID = "request_id"
+	KeyTraceID   = "trace_id"
+)
+
+type requestIDKey struct{}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"service2/internal/pkg/dedup"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type DedupStatser interface {
+	Stats() dedup.Stats
+}
+
+type DedupCollector struct {
+	set DedupStatser
+
+	entriesDesc, duplicatesDesc *prometheus.Desc
+}
+
+func NewDedupCollector(name string, s DedupStatser) *DedupCollector {
+	labels := prometheus.Labels{"consumer": name}
+	desc := func(n, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "consumer", n), help, nil, labels)
+	}
+	return &DedupCollector{
+		set:            s,
+		entriesDesc:    desc("dedup_entries", "Event IDs held in the deduplication seen-set."),
+		duplicatesDesc: desc("duplicates_dropped_total", "Duplicate events dropped before dispatch."),
+	}
+}
+
+func (c *DedupCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.entriesDesc
+	ch <- c.duplicatesDesc
+}
+
+func (c *DedupCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.set.Stats()
+	ch <- prometheus.MustNewConstMetric(c.entriesDesc, prometheus.GaugeValue, float64(s.Entries))
+	ch <- prometheus.MustNewConstMetric(c.duplicatesDesc, prometheus.CounterValue, float64(s.Duplicates))
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"service2/internal/pkg/broker/kafkaa"
+	"service2/internal/pkg/dedup"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockReader struct {
+	stats kafka.ReaderStats
+}
+
+func (m *mockReader) Stats() kafka.ReaderStats {
+	return m.stats
+}
+
+type mockPool struct {
+	stats kafkaa.WorkerStats
+}
+
+func (m *mockPool) WorkerStats() kafkaa.WorkerStats {
+	return m.stats
+}
+
+type mockSet struct {
+	stats dedup.Stats
+}
+
+func (m *mockSet) Stats() dedup.Stats {
+	return m.stats
+}
+
+func Test_ReaderCollector_Unit(t *testing.T) {
+	t.Parallel()
+	r := &mockReader{stats: kafka.ReaderStats{Messages: 4, Lag: 7}}
+	c := NewReaderCollector("tasks", r)
+	expected := `
+		# HELP taskmaster_kafka_reader_messages_total Messages read.
+		# TYPE taskmaster_kafka_reader_messages_total counter
+		taskmaster_kafka_reader_messages_total{reader="tasks"} 4
+		# HELP taskmaster_kafka_reader_lag Consumer lag in messages.
+		# TYPE taskmaster_kafka_reader_lag gauge
+		taskmaster_kafka_reader_lag{reader="tasks"} 7
+	`
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected), "taskmaster_kafka_reader_messages_total", "taskmaster_kafka_reader_lag"))
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(strings.Replace(expected, "} 4", "} 8", 1)), "taskmaster_kafka_reader_messages_total", "taskmaster_kafka_reader_lag"))
+}
+
+func Test_WorkerCollector_Unit(t *testing.T) {
+	t.Parallel()
+	m := New()
+	require.NoError(t, m.Register(NewWorkerCollector("tasks", &mockPool{stats: kafkaa.WorkerStats{Workers: 50, Busy: 3, Queued: 12}})))
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `taskmaster_consumer_workers{consumer="tasks"} 50`)
+	assert.Contains(t, rec.Body.String(), `taskmaster_consumer_workers_busy{consumer="tasks"} 3`)
+	assert.Contains(t, rec.Body.String(), `taskmaster_consumer_jobs_queued{consumer="tasks"} 12`)
+}
+
+func Test_DedupCollector_Unit(t *testing.T) {
+	t.Parallel()
+	c := NewDedupCollector("tasks", &mockSet{stats: dedup.Stats{Entries: 10, Duplicates: 2}})
+	expected := `
+		# HELP taskmaster_consumer_dedup_entries Event IDs held in the deduplication seen-set.
+		# TYPE taskmaster_consumer_dedup_entries gauge
+		taskmaster_consumer_dedup_entries{consumer="tasks"} 10
+		# HELP taskmaster_consumer_duplicates_dropped_total Duplicate events dropped before dispatch.
+		# TYPE taskmaster_consumer_duplicates_dropped_total counter
+		taskmaster_consumer_duplicates_dropped_total{consumer="tasks"} 2
+	`
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected)))
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"service2/internal/pkg/broker/kafkaa"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type WorkerStatser interface {
+	WorkerStats() kafkaa.WorkerStats
+}
+
+type WorkerCollector struct {
+	pool WorkerStatser
+
+	workersDesc, busyDesc, queuedDesc *prometheus.Desc
+}
+
+func NewWorkerCollector(name string, p WorkerStatser) *WorkerCollector {
+	labels := prometheus.Labels{"consumer": name}
+	desc := func(n, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "consumer", n), help, nil, labels)
+	}
+	return &WorkerCollector{
+		pool:        p,
+		workersDesc: desc("workers", "Workers in the pool."),
+		busyDesc:    desc("workers_busy", "Workers currently handling a message."),
+		queuedDesc:  desc("jobs_queued", "Messages waiting for a free worker."),
+	}
+}
+
+func (c *WorkerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.workersDesc
+	ch <- c.busyDesc
+	ch <- c.queuedDesc
+}
+
+func (c *WorkerCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.WorkerStats()
+	ch <- prometheus.MustNewConstMetric(c.workersDesc, prometheus.GaugeValue, float64(s.Workers))
+	ch <- prometheus.MustNewConstMetric(c.busyDesc, prometheus.GaugeValue, float64(s.Busy))
+	ch <- prometheus.MustNewConstMetric(c.queuedDesc, prometheus.GaugeValue, float64(s.Queued))
+}
@@ -0,0 +1,215 @@
+package protoevent
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+
+	"service2/internal/domain"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const ContentType = "application/x-protobuf"
+
+const EnvelopeIndex = 2
+
+//go:embed event.proto
+var Schema string
+
+var (
+	ErrMarshaling   = errors.New("protoevent: failed to marshal")
+	ErrUnmarshaling = errors.New("protoevent: failed to unmarshal")
+	ErrUnsupported  = errors.New("protoevent: unsupported type")
+)
+
+const (
+	fieldEnvelopeID            protowire.Number = 2
+	fieldEnvelopeType          protowire.Number = 3
+	fieldEnvelopeSchemaVersion protowire.Number = 4
+	fieldEnvelopeOccurredAt    protowire.Number = 5
+	fieldEnvelopeProducer      protowire.Number = 6
+	fieldEnvelopePayload       protowire.Number = 7
+
+	fieldEventRecord protowire.Number = 1
+
+	fieldRecordID        protowire.Number = 1
+	fieldRecordTitle     protowire.Number = 2
+	fieldRecordCreatedAt protowire.Number = 3
+	fieldRecordStatus    protowire.Number = 4
+	fieldRecordOwner     protowire.Number = 5
+)
+
+type Protobuf struct{}
+
+func New() *Protobuf {
+	return &Protobuf{}
+}
+
+func (p *Protobuf) ContentType() string {
+	return ContentType
+}
+
+func (p *Protobuf) Marshal(data any) ([]byte, error) {
+	switch d := data.(type) {
+	case domain.Envelope:
+		return appendEnvelope(nil, d), nil
+	case *domain.Envelope:
+		return appendEnvelope(nil, *d), nil
+	case domain.Event:
+		return appendEvent(nil, d), nil
+	case *domain.Event:
+		return appendEvent(nil, *d), nil
+	default:
+		return nil, fmt.Errorf("%w: %v: %T", ErrMarshaling, ErrUnsupported, data)
+	}
+}
+
+func (p *Protobuf) Unmarshal(data []byte, v any) error {
+	switch target := v.(type) {
+	case *domain.Envelope:
+		var e domain.Envelope
+		if err := consumeEnvelope(data, &e); err != nil {
+			return fmt.Errorf("%w: %v", ErrUnmarshaling, err)
+		}
+		*target = e
+	case *domain.Event:
+		var e domain.Event
+		if err := consumeEvent(data, &e); err != nil {
+			return fmt.Errorf("%w: %v", ErrUnmarshaling, err)
+		}
+		*target = e
+	default:
+		return fmt.Errorf("%w: %v: %T", ErrUnmarshaling, ErrUnsupported, v)
+	}
+	return nil
+}
+
+func appendEnvelope(b []byte, envelope domain.Envelope) []byte {
+	b = appendString(b, fieldEnvelopeID, envelope.ID)
+	b = appendString(b, fieldEnvelopeType, string(envelope.Type))
+	b = appendVarint(b, fieldEnvelopeSchemaVersion, int64(envelope.SchemaVersion))
+	b = appendVarint(b, fieldEnvelopeOccurredAt, envelope.OccurredAt)
+	b = appendString(b, fieldEnvelopeProducer, envelope.Producer)
+	b = protowire.AppendTag(b, fieldEnvelopePayload, protowire.BytesType)
+	return protowire.AppendBytes(b, appendEvent(nil, envelope.Payload))
+}
+
+func appendEvent(b []byte, event domain.Event) []byte {
+	b = protowire.AppendTag(b, fieldEventRecord, protowire.BytesType)
+	return protowire.AppendBytes(b, appendRecord(nil, event.Record))
+}
+
+func appendRecord(b []byte, record domain.Record) []byte {
+	b = appendString(b, fieldRecordID, string(record.ID))
+	b = appendString(b, fieldRecordTitle, record.Title)
+	b = appendVarint(b, fieldRecordCreatedAt, record.CreatedAt)
+	b = appendString(b, fieldRecordStatus, string(record.Status))
+	return appendString(b, fieldRecordOwner, record.Owner)
+}
+
+func appendVarint(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func consumeEnvelope(b []byte, envelope *domain.Envelope) error {
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == fieldEnvelopeSchemaVersion && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			envelope.SchemaVersion = int(int32(v))
+			return n, nil
+		case num == fieldEnvelopeOccurredAt && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			envelope.OccurredAt = int64(v)
+			return n, nil
+		case num == fieldEnvelopePayload && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n, nil
+			}
+			return n, consumeEvent(v, &envelope.Payload)
+		case typ != protowire.BytesType:
+			return protowire.ConsumeFieldValue(num, typ, b), nil
+		}
+		v, n := protowire.ConsumeString(b)
+		switch num {
+		case fieldEnvelopeID:
+			envelope.ID = v
+		case fieldEnvelopeType:
+			envelope.Type = domain.Action(v)
+		case fieldEnvelopeProducer:
+			envelope.Producer = v
+		}
+		return n, nil
+	})
+}
+
+func consumeEvent(b []byte, event *domain.Event) error {
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if num == fieldEventRecord && typ == protowire.BytesType {
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n, nil
+			}
+			return n, consumeRecord(v, &event.Record)
+		}
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+}
+
+func consumeRecord(b []byte, record *domain.Record) error {
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == fieldRecordCreatedAt && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			record.CreatedAt = int64(v)
+			return n, nil
+		case typ != protowire.BytesType:
+			return protowire.ConsumeFieldValue(num, typ, b), nil
+		}
+		v, n := protowire.ConsumeString(b)
+		switch num {
+		case fieldRecordID:
+			record.ID = domain.ID(v)
+		case fieldRecordTitle:
+			record.Title = v
+		case fieldRecordStatus:
+			record.Status = domain.Status(v)
+		case fieldRecordOwner:
+			record.Owner = v
+		}
+		return n, nil
+	})
+}
+
+func consumeFields(b []byte, field func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		n, err := field(num, typ, b)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package protoevent
+
+import (
+	"testing"
+
+	"service2/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func Test_Protobuf_Unit(t *testing.T) {
+	t.Parallel()
+	event := domain.Event{Record: domain.Record{ID: "1", Title: "Title", CreatedAt: 1700000000, Status: domain.StatusPending, Owner: "alice"}}
+	envelope := domain.Envelope{
+		ID:            "1-1",
+		Type:          domain.ActionUpdate,
+		SchemaVersion: domain.EventSchemaVersion,
+		OccurredAt:    1700000000,
+		Producer:      "service1",
+		Payload:       event,
+	}
+	cases := []struct {
+		name   string
+		data   any
+		target any
+		result any
+		err    error
+	}{
+		{
+			name:   "envelope",
+			data:   envelope,
+			target: &domain.Envelope{},
+			result: &envelope,
+		},
+		{
+			name:   "envelope pointer",
+			data:   &envelope,
+			target: &domain.Envelope{},
+			result: &envelope,
+		},
+		{
+			name:   "legacy event",
+			data:   event,
+			target: &domain.Event{},
+			result: &event,
+		},
+		{
+			name:   "legacy event pointer",
+			data:   &event,
+			target: &domain.Event{},
+			result: &event,
+		},
+		{
+			name: "unsupported",
+			data: domain.Record{ID: "1"},
+			err:  ErrMarshaling,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			t.Parallel()
+			p := New()
+			data, err := p.Marshal(cs.data)
+			if cs.err != nil {
+				assert.ErrorIs(t, err, cs.err)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, p.Unmarshal(data, cs.target))
+			assert.Equal(t, cs.result, cs.target)
+		})
+	}
+}
+
+func Test_Unmarshal_Unit(t *testing.T) {
+	t.Parallel()
+	p := New()
+
+	record := protowire.AppendTag(nil, fieldRecordID, protowire.BytesType)
+	record = protowire.AppendString(record, "1")
+	record = protowire.AppendTag(record, 9, protowire.VarintType)
+	record = protowire.AppendVarint(record, 42)
+	data := protowire.AppendTag(nil, fieldEventRecord, protowire.BytesType)
+	data = protowire.AppendBytes(data, record)
+	var event domain.Event
+	require.NoError(t, p.Unmarshal(data, &event))
+	assert.Equal(t, domain.ID("1"), event.Record.ID)
+
+	var legacy domain.Envelope
+	require.NoError(t, p.Unmarshal(data, &legacy))
+	assert.Equal(t, 0, legacy.SchemaVersion)
+
+	assert.ErrorIs(t, p.Unmarshal([]byte{0x0a, 0x05, 0x0a}, &event), ErrUnmarshaling)
+	assert.ErrorIs(t, p.Unmarshal(data, &domain.Record{}), ErrUnmarshaling)
+	assert.Equal(t, ContentType, p.ContentType())
+}
@@ -0,0 +1,78 @@
+package confluent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"service2/internal/pkg/registry/serde"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Client_Unit(t *testing.T) {
+	t.Parallel()
+	var registered schemaRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /subjects/tasks-value/versions", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, mediaType, r.Header.Get("Content-Type"))
+		registered = schemaRequest{}
+		json.NewDecoder(r.Body).Decode(&registered)
+		w.Write([]byte(`{"id":5}`))
+	})
+	mux.HandleFunc("GET /schemas/ids/5", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"schema":"syntax = \"proto3\";","schemaType":"PROTOBUF"}`))
+	})
+	mux.HandleFunc("GET /schemas/ids/6", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"schema":"\"string\""}`))
+	})
+	mux.HandleFunc("GET /subjects/tasks-value/versions/latest", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"subject":"tasks-value","id":5,"version":3,"schema":"syntax = \"proto3\";","schemaType":"PROTOBUF"}`))
+	})
+	mux.HandleFunc("POST /compatibility/subjects/tasks-value/versions/latest", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"is_compatible":false,"messages":["field removed"]}`))
+	})
+	mux.HandleFunc("POST /compatibility/subjects/new-value/versions/latest", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error_code":40401,"message":"Subject not found."}`))
+	})
+	mux.HandleFunc("POST /subjects/broken-value/versions", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error_code":50001,"message":"Error in the backend data store"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	ctx := context.Background()
+	c := New(Config{URL: server.URL + "/"})
+
+	schema, err := c.Register(ctx, "tasks-value", serde.Schema{Type: serde.TypeProtobuf, Schema: "syntax"})
+	require.NoError(t, err)
+	assert.Equal(t, serde.Schema{ID: 5, Subject: "tasks-value", Type: serde.TypeProtobuf, Schema: "syntax"}, schema)
+	assert.Equal(t, schemaRequest{Schema: "syntax", SchemaType: serde.TypeProtobuf}, registered)
+
+	_, err = c.Register(ctx, "tasks-value", serde.Schema{Type: serde.TypeAvro, Schema: `"string"`})
+	require.NoError(t, err)
+	assert.Equal(t, schemaRequest{Schema: `"string"`}, registered)
+
+	byID, err := c.SchemaByID(ctx, 5)
+	require.NoError(t, err)
+	assert.Equal(t, serde.Schema{ID: 5, Type: serde.TypeProtobuf, Schema: `syntax = "proto3";`}, byID)
+	byID, err = c.SchemaByID(ctx, 6)
+	require.NoError(t, err)
+	assert.Equal(t, serde.TypeAvro, byID.Type)
+	_, err = c.SchemaByID(ctx, 7)
+	assert.ErrorIs(t, err, serde.ErrNotFound)
+
+	latest, err := c.Latest(ctx, "tasks-value")
+	require.NoError(t, err)
+	assert.Equal(t, 3, latest.Version)
+
+	assert.ErrorIs(t, c.Compatible(ctx, "tasks-value", serde.Schema{Type: serde.TypeAvro}), serde.ErrIncompatible)
+	assert.NoError(t, c.Compatible(ctx, "new-value", serde.Schema{Type: serde.TypeAvro}))
+
+	_, err = c.Register(ctx, "broken-value", serde.Schema{Type: serde.TypeAvro})
+	assert.ErrorIs(t, err, serde.ErrRegistry)
+}
@@ -0,0 +1,277 @@
+package fileregistry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"service2/internal/pkg/registry/serde"
+
+	"github.com/hamba/avro/v2"
+)
+
+const (
+	CompatibilityNone     = "NONE"
+	CompatibilityBackward = "BACKWARD"
+	CompatibilityForward  = "FORWARD"
+	CompatibilityFull     = "FULL"
+)
+
+// Several processes may share one registry file, so schema IDs mean the same
+// thing to every producer and consumer. Registrations hold a lock file while
+// they re-read, extend and rewrite the registry; a lock older than staleLock
+// is taken to belong to a crashed process and is broken.
+const (
+	lockPoll  = 20 * time.Millisecond
+	staleLock = 30 * time.Second
+)
+
+var (
+	ErrOpening              = errors.New("fileregistry: failed to open registry")
+	ErrLocking              = errors.New("fileregistry: failed to lock registry")
+	ErrWriting              = errors.New("fileregistry: failed to write registry")
+	ErrInvalidSchema        = errors.New("fileregistry: invalid schema")
+	ErrUnknownCompatibility = errors.New("fileregistry: unknown compatibility level")
+)
+
+type Config struct {
+	Path          string `yaml:"path"`
+	Compatibility string `yaml:"compatibility"`
+}
+
+type Registry struct {
+	mu sync.RWMutex
+
+	path          string
+	compatibility string
+
+	schemas []serde.Schema
+}
+
+func New(c Config) (*Registry, error) {
+	switch c.Compatibility {
+	case CompatibilityNone, CompatibilityBackward, CompatibilityForward, CompatibilityFull:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCompatibility, c.Compatibility)
+	}
+	r := &Registry{
+		path:          c.Path,
+		compatibility: c.Compatibility,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Registry) load() error {
+	data, err := os.ReadFile(r.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return fmt.Errorf("%w: %v", ErrOpening, err)
+	}
+	var schemas []serde.Schema
+	if err := json.Unmarshal(data, &schemas); err != nil {
+		return fmt.Errorf("%w: %v", ErrOpening, err)
+	}
+	r.schemas = schemas
+	return nil
+}
+
+func (r *Registry) lock(ctx context.Context) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLocking, err)
+	}
+	path := r.path + ".lock"
+	for {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("%w: %v", ErrLocking, err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(path)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %v", ErrLocking, ctx.Err())
+		case <-time.After(lockPoll):
+		}
+	}
+}
+
+func (r *Registry) Register(ctx context.Context, subject string, schema serde.Schema) (serde.Schema, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return serde.Schema{}, err
+	}
+	defer unlock()
+	if err := r.load(); err != nil {
+		return serde.Schema{}, err
+	}
+	schema = normalize(schema)
+	var (
+		latest  serde.Schema
+		version int
+		id      int
+	)
+	for _, s := range r.schemas {
+		id = max(id, s.ID)
+		if s.Subject != subject {
+			continue
+		}
+		if s.Type == schema.Type && s.Schema == schema.Schema {
+			return s, nil
+		}
+		if s.Version > version {
+			latest, version = s, s.Version
+		}
+	}
+	if version > 0 {
+		if err := r.check(latest, schema); err != nil {
+			return serde.Schema{}, err
+		}
+	}
+	schema.ID = id + 1
+	schema.Subject = subject
+	schema.Version = version + 1
+	schemas := append(r.schemas[:len(r.schemas):len(r.schemas)], schema)
+	if err := r.persist(schemas); err != nil {
+		return serde.Schema{}, err
+	}
+	r.schemas = schemas
+	return schema, nil
+}
+
+// SchemaByID re-reads the file on a miss, picking up schemas another process
+// registered since.
+func (r *Registry) SchemaByID(ctx context.Context, id int) (serde.Schema, error) {
+	r.mu.RLock()
+	schema, ok := r.byID(id)
+	r.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.load(); err != nil {
+		return serde.Schema{}, err
+	}
+	if schema, ok := r.byID(id); ok {
+		return schema, nil
+	}
+	return serde.Schema{}, fmt.Errorf("%w: id %d", serde.ErrNotFound, id)
+}
+
+func (r *Registry) byID(id int) (serde.Schema, bool) {
+	for _, s := range r.schemas {
+		if s.ID == id {
+			return s, true
+		}
+	}
+	return serde.Schema{}, false
+}
+
+func (r *Registry) Latest(ctx context.Context, subject string) (serde.Schema, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.load(); err != nil {
+		return serde.Schema{}, err
+	}
+	return r.latest(subject)
+}
+
+func (r *Registry) Compatible(ctx context.Context, subject string, schema serde.Schema) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.load(); err != nil {
+		return err
+	}
+	latest, err := r.latest(subject)
+	if errors.Is(err, serde.ErrNotFound) {
+		return nil
+	}
+	return r.check(latest, normalize(schema))
+}
+
+func (r *Registry) latest(subject string) (serde.Schema, error) {
+	var latest serde.Schema
+	for _, s := range r.schemas {
+		if s.Subject == subject && s.Version > latest.Version {
+			latest = s
+		}
+	}
+	if latest.Version == 0 {
+		return serde.Schema{}, fmt.Errorf("%w: subject %q", serde.ErrNotFound, subject)
+	}
+	return latest, nil
+}
+
+// Only Avro schemas are checked locally; Protobuf and JSON schemas are
+// accepted as long as the type does not change.
+func (r *Registry) check(latest serde.Schema, schema serde.Schema) error {
+	if r.compatibility == CompatibilityNone {
+		return nil
+	}
+	if latest.Type != schema.Type {
+		return fmt.Errorf("%w: type %s -> %s", serde.ErrIncompatible, latest.Type, schema.Type)
+	}
+	if schema.Type != serde.TypeAvro {
+		return nil
+	}
+	previous, err := avro.Parse(latest.Schema)
+	if err != nil {
+		return fmt.Errorf("%w: version %d: %v", ErrInvalidSchema, latest.Version, err)
+	}
+	next, err := avro.Parse(schema.Schema)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	compat := avro.NewSchemaCompatibility()
+	if r.compatibility == CompatibilityBackward || r.compatibility == CompatibilityFull {
+		if err := compat.Compatible(next, previous); err != nil {
+			return fmt.Errorf("%w: backward: %v", serde.ErrIncompatible, err)
+		}
+	}
+	if r.compatibility == CompatibilityForward || r.compatibility == CompatibilityFull {
+		if err := compat.Compatible(previous, next); err != nil {
+			return fmt.Errorf("%w: forward: %v", serde.ErrIncompatible, err)
+		}
+	}
+	return nil
+}
+
+func (r *Registry) persist(schemas []serde.Schema) error {
+	data, err := json.MarshalIndent(schemas, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrWriting, err)
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("%w: %v", ErrWriting, err)
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return fmt.Errorf("%w: %v", ErrWriting, err)
+	}
+	return nil
+}
+
+func normalize(schema serde.Schema) serde.Schema {
+	if schema.Type == "" {
+		schema.Type = serde.TypeAvro
+	}
+	return serde.Schema{Type: schema.Type, Schema: schema.Schema}
+}
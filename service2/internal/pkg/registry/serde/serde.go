@@ -0,0 +1,168 @@
+package serde
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	TypeAvro     = "AVRO"
+	TypeProtobuf = "PROTOBUF"
+	TypeJSON     = "JSON"
+)
+
+const ContentTypeParam = "; registry=confluent"
+
+var (
+	ErrNotFound       = errors.New("serde: schema not found")
+	ErrIncompatible   = errors.New("serde: schema incompatible")
+	ErrNotRegistered  = errors.New("serde: schema not registered")
+	ErrRegistry       = errors.New("serde: registry failed")
+	ErrSchemaMismatch = errors.New("serde: schema type mismatch")
+	ErrMarshaling     = errors.New("serde: failed to marshal")
+	ErrUnmarshaling   = errors.New("serde: failed to unmarshal")
+)
+
+type Schema struct {
+	ID      int    `json:"id"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+	Type    string `json:"schema_type"`
+	Schema  string `json:"schema"`
+}
+
+type Registry interface {
+	Register(ctx context.Context, subject string, schema Schema) (Schema, error)
+	SchemaByID(ctx context.Context, id int) (Schema, error)
+	Latest(ctx context.Context, subject string) (Schema, error)
+	Compatible(ctx context.Context, subject string, schema Schema) error
+}
+
+type Codec interface {
+	Marshal(data any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+	ContentType() string
+}
+
+// Resolver is implemented by codecs that can decode a payload written with a
+// different schema than their own, such as Avro, whose wire format carries no
+// field names.
+type Resolver interface {
+	UnmarshalResolved(writer string, data []byte, v any) error
+}
+
+type Config struct {
+	Registry Registry
+	Codec    Codec
+	Subject  string
+	Schema   Schema
+	Indexes  []int
+	Timeout  time.Duration
+}
+
+type Serde struct {
+	registry Registry
+	codec    Codec
+	subject  string
+	schema   Schema
+	indexes  []int
+	timeout  time.Duration
+
+	mu    sync.RWMutex
+	id    int
+	known map[int]Schema
+}
+
+func New(c Config) *Serde {
+	return &Serde{
+		registry: c.Registry,
+		codec:    c.Codec,
+		subject:  c.Subject,
+		schema:   c.Schema,
+		indexes:  c.Indexes,
+		timeout:  c.Timeout,
+		known:    make(map[int]Schema),
+	}
+}
+
+func (s *Serde) Register(ctx context.Context) error {
+	if err := s.registry.Compatible(ctx, s.subject, s.schema); err != nil {
+		return err
+	}
+	schema, err := s.registry.Register(ctx, s.subject, s.schema)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.id = schema.ID
+	s.known[schema.ID] = schema
+	return nil
+}
+
+func (s *Serde) ContentType() string {
+	return s.codec.ContentType() + ContentTypeParam
+}
+
+func (s *Serde) Marshal(data any) ([]byte, error) {
+	s.mu.RLock()
+	id := s.id
+	s.mu.RUnlock()
+	if id == 0 {
+		return nil, fmt.Errorf("%w: subject %q", ErrNotRegistered, s.subject)
+	}
+	payload, err := s.codec.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMarshaling, err)
+	}
+	return Encode(id, s.indexes, payload), nil
+}
+
+func (s *Serde) Unmarshal(data []byte, v any) error {
+	id, payload, err := Decode(data, s.schema.Type == TypeProtobuf)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnmarshaling, err)
+	}
+	schema, err := s.lookup(id)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnmarshaling, err)
+	}
+	if schema.Type != s.schema.Type {
+		return fmt.Errorf("%w: schema %d is %s, want %s", ErrSchemaMismatch, id, schema.Type, s.schema.Type)
+	}
+	if resolver, ok := s.codec.(Resolver); ok && schema.Schema != s.schema.Schema {
+		if err := resolver.UnmarshalResolved(schema.Schema, payload, v); err != nil {
+			return fmt.Errorf("%w: %v", ErrUnmarshaling, err)
+		}
+		return nil
+	}
+	if err := s.codec.Unmarshal(payload, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnmarshaling, err)
+	}
+	return nil
+}
+
+func (s *Serde) lookup(id int) (Schema, error) {
+	s.mu.RLock()
+	schema, ok := s.known[id]
+	s.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	schema, err := s.registry.SchemaByID(ctx, id)
+	if err != nil {
+		return Schema{}, err
+	}
+	if schema.Type == "" {
+		schema.Type = TypeAvro
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.known[id] = schema
+	return schema, nil
+}
@@ -0,0 +1,72 @@
+package serde
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	MagicByte  byte = 0x0
+	headerSize      = 5
+)
+
+var (
+	ErrWireFormat = errors.New("serde: invalid wire format")
+)
+
+func Encode(id int, indexes []int, payload []byte) []byte {
+	b := make([]byte, headerSize, headerSize+len(payload)+binary.MaxVarintLen64)
+	b[0] = MagicByte
+	binary.BigEndian.PutUint32(b[1:headerSize], uint32(id))
+	if indexes != nil {
+		b = appendIndexes(b, indexes)
+	}
+	return append(b, payload...)
+}
+
+func Decode(data []byte, protobuf bool) (int, []byte, error) {
+	if len(data) < headerSize {
+		return 0, nil, fmt.Errorf("%w: %d bytes", ErrWireFormat, len(data))
+	}
+	if data[0] != MagicByte {
+		return 0, nil, fmt.Errorf("%w: magic byte %#x", ErrWireFormat, data[0])
+	}
+	id := int(binary.BigEndian.Uint32(data[1:headerSize]))
+	payload := data[headerSize:]
+	if protobuf {
+		n, err := skipIndexes(payload)
+		if err != nil {
+			return 0, nil, err
+		}
+		payload = payload[n:]
+	}
+	return id, payload, nil
+}
+
+func appendIndexes(b []byte, indexes []int) []byte {
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return append(b, 0)
+	}
+	b = binary.AppendVarint(b, int64(len(indexes)))
+	for _, i := range indexes {
+		b = binary.AppendVarint(b, int64(i))
+	}
+	return b
+}
+
+func skipIndexes(b []byte) (int, error) {
+	count, n := binary.Varint(b)
+	if n <= 0 || count < 0 {
+		return 0, fmt.Errorf("%w: message indexes", ErrWireFormat)
+	}
+	offset := n
+	for range count {
+		_, n := binary.Varint(b[offset:])
+		if n <= 0 {
+			return 0, fmt.Errorf("%w: message indexes", ErrWireFormat)
+		}
+		offset += n
+	}
+	return offset, nil
+}
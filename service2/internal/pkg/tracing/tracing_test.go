@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	once      sync.Once
+	collected *tracetest.SpanRecorder
+)
+
+func collector() *tracetest.SpanRecorder {
+	once.Do(func() {
+		collected = tracetest.NewSpanRecorder()
+		Install(sdktrace.WithSpanProcessor(collected))
+	})
+	return collected
+}
+
+func Test_New_Unit(t *testing.T) {
+	t.Parallel()
+	_, err := New(context.Background(), Config{Exporter: "zipkin"})
+	assert.ErrorIs(t, err, ErrUnknownExporter)
+}
+
+func Test_KafkaHeaders_Unit(t *testing.T) {
+	t.Parallel()
+	collector()
+	ctx, span := Start(context.Background(), "kafka-headers-test")
+	defer span.End()
+
+	headers := []kafka.Header{{Key: "x-request-id", Value: []byte("req-1")}}
+	InjectHeaders(ctx, &headers)
+	require.Len(t, headers, 2)
+
+	extracted := trace.SpanContextFromContext(ExtractHeaders(context.Background(), headers))
+	assert.Equal(t, span.SpanContext().TraceID(), extracted.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), extracted.SpanID())
+	assert.True(t, extracted.IsRemote())
+}
+
+func Test_Inject_Unit(t *testing.T) {
+	t.Parallel()
+	collector()
+	assert.Nil(t, Inject(context.Background()))
+
+	ctx, span := Start(context.Background(), "inject-test")
+	defer span.End()
+	carrier := Inject(ctx)
+	require.Contains(t, carrier, "traceparent")
+
+	extracted := trace.SpanContextFromContext(Extract(context.Background(), carrier))
+	assert.Equal(t, span.SpanContext().TraceID(), extracted.TraceID())
+}
@@ -0,0 +1,68 @@
+package upcaster
+
+import (
+	"errors"
+	"fmt"
+
+	"service2/internal/domain"
+)
+
+const ProducerUnknown = "unknown"
+
+var (
+	ErrUnsupportedVersion = errors.New("upcaster: unsupported schema version")
+	ErrUpcasting          = errors.New("upcaster: failed to upcast event")
+	ErrMissingType        = errors.New("upcaster: event type missing")
+)
+
+type Func func(envelope domain.Envelope) (domain.Envelope, error)
+
+type Upcaster struct {
+	target int
+	steps  map[int]Func
+}
+
+func New() *Upcaster {
+	return &Upcaster{
+		target: domain.EventSchemaVersion,
+		steps: map[int]Func{
+			domain.EventSchemaLegacy: fromLegacy,
+		},
+	}
+}
+
+func (u *Upcaster) Upcast(envelope domain.Envelope) (domain.Envelope, error) {
+	if envelope.SchemaVersion < domain.EventSchemaLegacy || envelope.SchemaVersion > u.target {
+		return domain.Envelope{}, fmt.Errorf("%w: %d", ErrUnsupportedVersion, envelope.SchemaVersion)
+	}
+	for envelope.SchemaVersion < u.target {
+		from := envelope.SchemaVersion
+		step, ok := u.steps[from]
+		if !ok {
+			return domain.Envelope{}, fmt.Errorf("%w: no upcaster from version %d", ErrUnsupportedVersion, from)
+		}
+		next, err := step(envelope)
+		if err != nil {
+			return domain.Envelope{}, fmt.Errorf("%w: from version %d: %v", ErrUpcasting, from, err)
+		}
+		if next.SchemaVersion <= from {
+			return domain.Envelope{}, fmt.Errorf("%w: version %d did not advance", ErrUpcasting, from)
+		}
+		envelope = next
+	}
+	return envelope, nil
+}
+
+func fromLegacy(envelope domain.Envelope) (domain.Envelope, error) {
+	if envelope.Type == "" {
+		return domain.Envelope{}, fmt.Errorf("%w", ErrMissingType)
+	}
+	if envelope.OccurredAt == 0 {
+		envelope.OccurredAt = envelope.Payload.Record.CreatedAt
+	}
+	if envelope.Producer == "" {
+		envelope.Producer = ProducerUnknown
+	}
+	envelope.SchemaVersion = domain.EventSchemaEnvelope
+	return envelope, nil
+}
@@ -0,0 +1,98 @@
+package upcaster
+
+import (
+	"errors"
+	"testing"
+
+	"service2/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Upcast_Unit(t *testing.T) {
+	t.Parallel()
+	record := domain.Record{ID: "1", Title: "Title", CreatedAt: 100, Status: domain.StatusPending}
+	cases := []struct {
+		name     string
+		upcaster *Upcaster
+		envelope domain.Envelope
+		result   domain.Envelope
+		err      error
+	}{
+		{
+			name:     "current version untouched",
+			upcaster: New(),
+			envelope: domain.Envelope{ID: "e", Type: domain.ActionUpdate, SchemaVersion: domain.EventSchemaVersion, OccurredAt: 200, Producer: "service1", Payload: domain.Event{Record: record}},
+			result:   domain.Envelope{ID: "e", Type: domain.ActionUpdate, SchemaVersion: domain.EventSchemaVersion, OccurredAt: 200, Producer: "service1", Payload: domain.Event{Record: record}},
+		},
+		{
+			name:     "legacy",
+			upcaster: New(),
+			envelope: domain.Envelope{ID: "e", Type: domain.ActionEdit, SchemaVersion: domain.EventSchemaLegacy, Payload: domain.Event{Record: record}},
+			result:   domain.Envelope{ID: "e", Type: domain.ActionEdit, SchemaVersion: domain.EventSchemaEnvelope, OccurredAt: 100, Producer: ProducerUnknown, Payload: domain.Event{Record: record}},
+		},
+		{
+			name:     "legacy without type",
+			upcaster: New(),
+			envelope: domain.Envelope{SchemaVersion: domain.EventSchemaLegacy},
+			err:      ErrUpcasting,
+		},
+		{
+			name:     "newer than supported",
+			upcaster: New(),
+			envelope: domain.Envelope{SchemaVersion: domain.EventSchemaVersion + 1},
+			err:      ErrUnsupportedVersion,
+		},
+		{
+			name:     "unversioned",
+			upcaster: New(),
+			envelope: domain.Envelope{},
+			err:      ErrUnsupportedVersion,
+		},
+		{
+			name: "chain",
+			upcaster: &Upcaster{target: 3, steps: map[int]Func{
+				1: fromLegacy,
+				2: func(e domain.Envelope) (domain.Envelope, error) {
+					e.Payload.Record.Title += " (v3)"
+					e.SchemaVersion = 3
+					return e, nil
+				},
+			}},
+			envelope: domain.Envelope{Type: domain.ActionUpdate, SchemaVersion: 1, OccurredAt: 5, Payload: domain.Event{Record: record}},
+			result: domain.Envelope{Type: domain.ActionUpdate, SchemaVersion: 3, OccurredAt: 5, Producer: ProducerUnknown, Payload: domain.Event{Record: domain.Record{
+				ID: "1", Title: "Title (v3)", CreatedAt: 100, Status: domain.StatusPending,
+			}}},
+		},
+		{
+			name:     "missing step",
+			upcaster: &Upcaster{target: 3, steps: map[int]Func{1: fromLegacy}},
+			envelope: domain.Envelope{Type: domain.ActionUpdate, SchemaVersion: 1},
+			err:      ErrUnsupportedVersion,
+		},
+		{
+			name: "step not advancing",
+			upcaster: &Upcaster{target: 2, steps: map[int]Func{1: func(e domain.Envelope) (domain.Envelope, error) {
+				return e, nil
+			}}},
+			envelope: domain.Envelope{SchemaVersion: 1},
+			err:      ErrUpcasting,
+		},
+		{
+			name: "step failing",
+			upcaster: &Upcaster{target: 2, steps: map[int]Func{1: func(e domain.Envelope) (domain.Envelope, error) {
+				return domain.Envelope{}, errors.New("")
+			}}},
+			envelope: domain.Envelope{SchemaVersion: 1},
+			err:      ErrUpcasting,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := cs.upcaster.Upcast(cs.envelope)
+			assert.ErrorIs(t, err, cs.err)
+			assert.Equal(t, cs.result, result)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package change
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"service2/internal/domain"
+)
+
+var (
+	ErrUnknownAction = errors.New("change: unknown action")
+)
+
+type Config struct{}
+
+type Processor interface {
+	Update(ctx context.Context, event domain.Event) error
+	Cancel(id domain.ID) bool
+}
+
+type Usecase struct {
+	Config Config
+
+	Processor Processor
+
+	Logger *slog.Logger
+}
+
+func (u *Usecase) Change(ctx context.Context, action domain.Action, event domain.Event) error {
+	switch action {
+	case domain.ActionEdit:
+		if event.Record.Status != domain.StatusPending {
+			return nil
+		}
+		return u.Processor.Update(ctx, event)
+	case domain.ActionDelete:
+		if u.Processor.Cancel(event.Record.ID) {
+			u.Logger.InfoContext(ctx, "task deleted, processing canceled", slog.String("id", string(event.Record.ID)))
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownAction, action)
+	}
+}
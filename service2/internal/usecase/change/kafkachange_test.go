@@ -0,0 +1,115 @@
+package change
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"service2/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var discard = slog.New(slog.DiscardHandler)
+
+type mockProcessor struct {
+	u        mockUpdate
+	canceled []domain.ID
+}
+
+type mockUpdate struct {
+	events []domain.Event
+	err    error
+}
+
+func (m *mockProcessor) Update(ctx context.Context, event domain.Event) error {
+	m.u.events = append(m.u.events, event)
+	return m.u.err
+}
+
+func (m *mockProcessor) Cancel(id domain.ID) bool {
+	m.canceled = append(m.canceled, id)
+	return true
+}
+
+func Test_Change_Unit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name      string
+		ctx       context.Context
+		action    domain.Action
+		event     domain.Event
+		processor *mockProcessor
+		updated   int
+		canceled  []domain.ID
+		err       error
+	}{
+		{
+			name:      "retry pending task",
+			ctx:       context.Background(),
+			action:    domain.ActionEdit,
+			event:     domain.Event{Record: domain.Record{ID: "1", Status: domain.StatusPending}},
+			processor: &mockProcessor{},
+			updated:   1,
+			canceled:  nil,
+			err:       nil,
+		},
+		{
+			name:      "title edit is ignored",
+			ctx:       context.Background(),
+			action:    domain.ActionEdit,
+			event:     domain.Event{Record: domain.Record{ID: "1", Status: domain.StatusCompleted}},
+			processor: &mockProcessor{},
+			updated:   0,
+			canceled:  nil,
+			err:       nil,
+		},
+		{
+			name:      "retry failure",
+			ctx:       context.Background(),
+			action:    domain.ActionEdit,
+			event:     domain.Event{Record: domain.Record{ID: "1", Status: domain.StatusPending}},
+			processor: &mockProcessor{u: mockUpdate{err: errors.New("")}},
+			updated:   1,
+			canceled:  nil,
+			err:       errors.New(""),
+		},
+		{
+			name:      "delete cancels processing",
+			ctx:       context.Background(),
+			action:    domain.ActionDelete,
+			event:     domain.Event{Record: domain.Record{ID: "1"}},
+			processor: &mockProcessor{},
+			updated:   0,
+			canceled:  []domain.ID{"1"},
+			err:       nil,
+		},
+		{
+			name:      "unknown action",
+			ctx:       context.Background(),
+			action:    domain.Action("rename"),
+			event:     domain.Event{},
+			processor: &mockProcessor{},
+			updated:   0,
+			canceled:  nil,
+			err:       ErrUnknownAction,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			u := &Usecase{Processor: cs.processor, Logger: discard}
+			err := u.Change(cs.ctx, cs.action, cs.event)
+			if cs.err != nil {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			if errors.Is(cs.err, ErrUnknownAction) {
+				assert.ErrorIs(t, err, cs.err)
+			}
+			assert.Len(t, cs.processor.u.events, cs.updated)
+			assert.Equal(t, cs.canceled, cs.processor.canceled)
+		})
+	}
+}
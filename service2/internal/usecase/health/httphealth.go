@@ -0,0 +1,109 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"service2/internal/domain"
+)
+
+var (
+	ErrDraining = errors.New("health: service is shutting down")
+	ErrNotReady = errors.New("health: dependency not ready")
+)
+
+type Config struct {
+	CheckTimeout time.Duration `yaml:"check_timeout"`
+	DrainDelay   time.Duration `yaml:"drain_delay"`
+}
+
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type Encoder interface {
+	Marshal(data any) ([]byte, error)
+}
+
+type Usecase struct {
+	Config Config
+
+	Checks map[string]Pinger
+
+	Encoder Encoder
+
+	draining atomic.Bool
+}
+
+func (u *Usecase) LiveHandler(w http.ResponseWriter, r *http.Request) {
+	u.sendJSON(w, domain.Health{Status: domain.HealthOK}, http.StatusOK)
+}
+
+func (u *Usecase) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	report, err := u.Ready(r.Context())
+	if err != nil {
+		u.sendJSON(w, report, http.StatusServiceUnavailable)
+		return
+	}
+	u.sendJSON(w, report, http.StatusOK)
+}
+
+func (u *Usecase) sendJSON(w http.ResponseWriter, data any, code int) {
+	d, err := u.Encoder.Marshal(data)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	w.Write(d)
+}
+
+func (u *Usecase) Drain() {
+	u.draining.Store(true)
+}
+
+func (u *Usecase) Ready(ctx context.Context) (domain.Health, error) {
+	if u.draining.Load() {
+		return domain.Health{Status: domain.HealthDraining}, fmt.Errorf("%w", ErrDraining)
+	}
+	if u.Config.CheckTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, u.Config.CheckTimeout)
+		defer cancel()
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	checks := make(map[string]string, len(u.Checks))
+	failed := make([]string, 0)
+	for name, check := range u.Checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := check.Ping(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				checks[name] = err.Error()
+				failed = append(failed, name)
+				return
+			}
+			checks[name] = string(domain.HealthOK)
+		}()
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return domain.Health{Status: domain.HealthUnavailable, Checks: checks}, fmt.Errorf("%w: %v", ErrNotReady, failed)
+	}
+	return domain.Health{Status: domain.HealthOK, Checks: checks}, nil
+}
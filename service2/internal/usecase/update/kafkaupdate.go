@@ -4,50 +4,185 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"sync"
 	"time"
 
 	"service2/internal/domain"
-
-	"github.com/segmentio/kafka-go"
+	"service2/internal/pkg/broker/kafkaa"
 )
 
 var (
 	ErrOperationCanceled = errors.New("update: operation canceled")
 
-	ErrUnmarshalingMessage = errors.New("update: failed while unmarshaling message")
+	ErrEmptyTitle        = errors.New("update: invalid event: empty task title")
+	ErrBrokerUnavailable = errors.New("update: broker unavailable")
+	ErrBrokerFailure     = errors.New("update: broker failed")
+	ErrGeneratingID      = errors.New("update: failed to generate event id")
+	ErrTaskDeleted       = errors.New("update: task deleted mid processing")
 )
 
-type Config struct{}
+type Config struct {
+	ProcessingTime time.Duration `yaml:"processing_time"`
+	FailTimeout    time.Duration `yaml:"fail_timeout"`
+	DeletedTTL     time.Duration `yaml:"deleted_ttl"`
+}
+
+type Publisher interface {
+	PublishEvent(ctx context.Context, envelope domain.Envelope) error
+}
+
+type Generator interface {
+	Gen() (string, error)
+}
 
-type Decoder interface {
-	Unmarshal(data []byte, v any) error
+type Timer interface {
+	TimeNow() int64
 }
 
 type Usecase struct {
 	Config Config
 
-	Decoder Decoder
+	Publisher Publisher
+
+	Generator Generator
+	Timer     Timer
+	Logger    *slog.Logger
+
+	mu       sync.Mutex
+	inflight map[domain.ID]context.CancelCauseFunc
+	removed  map[domain.ID]int64
 }
 
-func (u *Usecase) EventHandler(ctx context.Context, message kafka.Message) {
-	var event domain.Event
-	if umErr := u.Decoder.Unmarshal(message.Value, &event); umErr != nil {
-		log.Println(fmt.Errorf("%v: %v", ErrUnmarshalingMessage, umErr))
-		return
-	}
-	if upErr := u.Update(ctx, event); upErr != nil && !errors.Is(upErr, ErrOperationCanceled) {
-		log.Println(upErr)
-		return
+func (u *Usecase) EventHandler(ctx context.Context, event domain.Event) error {
+	if upErr := u.Update(ctx, event); upErr != nil {
+		if errors.Is(upErr, ErrEmptyTitle) {
+			u.Logger.WarnContext(ctx, "task skipped", slog.String("id", string(event.Record.ID)), slog.Any("error", upErr))
+			return nil
+		}
+		return upErr
 	}
+	return nil
 }
 
 func (u *Usecase) Update(ctx context.Context, event domain.Event) error {
+	record := event.Record
+	if u.isDeleted(record.ID) {
+		u.Logger.InfoContext(ctx, "task deleted, processing skipped", slog.String("id", string(record.ID)))
+		return nil
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	u.track(record.ID, cancel)
+	defer u.untrack(record.ID)
+	if err := u.report(ctx, record, domain.StatusProcessing); err != nil {
+		return u.deleted(ctx, err)
+	}
+	if err := u.process(ctx, record); err != nil {
+		if errors.Is(err, ErrOperationCanceled) {
+			return u.deleted(ctx, err)
+		}
+		c, cancel := context.WithTimeout(context.WithoutCancel(ctx), u.Config.FailTimeout)
+		defer cancel()
+		if repErr := u.report(c, record, domain.StatusFailed); repErr != nil {
+			return repErr
+		}
+		return err
+	}
+	return u.deleted(ctx, u.report(ctx, record, domain.StatusCompleted))
+}
+
+// Cancel stops the running update for id and remembers id for DeletedTTL, so
+// an update still queued behind the delete is skipped once it is dequeued.
+func (u *Usecase) Cancel(id domain.ID) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	now := u.Timer.TimeNow()
+	for removed, expiry := range u.removed {
+		if expiry <= now {
+			delete(u.removed, removed)
+		}
+	}
+	if u.Config.DeletedTTL > 0 {
+		if u.removed == nil {
+			u.removed = make(map[domain.ID]int64)
+		}
+		u.removed[id] = now + int64(u.Config.DeletedTTL.Seconds())
+	}
+	cancel, ok := u.inflight[id]
+	if ok {
+		cancel(ErrTaskDeleted)
+	}
+	return ok
+}
+
+func (u *Usecase) isDeleted(id domain.ID) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	expiry, ok := u.removed[id]
+	return ok && expiry > u.Timer.TimeNow()
+}
+
+func (u *Usecase) track(id domain.ID, cancel context.CancelCauseFunc) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.inflight == nil {
+		u.inflight = make(map[domain.ID]context.CancelCauseFunc)
+	}
+	u.inflight[id] = cancel
+}
+
+func (u *Usecase) untrack(id domain.ID) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if cancel, ok := u.inflight[id]; ok {
+		cancel(nil)
+		delete(u.inflight, id)
+	}
+}
+
+func (u *Usecase) deleted(ctx context.Context, err error) error {
+	if err != nil && errors.Is(context.Cause(ctx), ErrTaskDeleted) {
+		u.Logger.InfoContext(ctx, "processing canceled", slog.Any("error", fmt.Errorf("%w: %v", ErrTaskDeleted, err)))
+		return nil
+	}
+	return err
+}
+
+func (u *Usecase) process(ctx context.Context, record domain.Record) error {
+	if record.Title == "" {
+		return fmt.Errorf("%w", ErrEmptyTitle)
+	}
 	select {
 	case <-ctx.Done():
 		return fmt.Errorf("%w: %v", ErrOperationCanceled, ctx.Err())
-	case <-time.After(time.Second * 7):
-		log.Println(event)
+	case <-time.After(u.Config.ProcessingTime):
+		u.Logger.InfoContext(ctx, "task processed", slog.String("id", string(record.ID)))
+	}
+	return nil
+}
+
+func (u *Usecase) report(ctx context.Context, record domain.Record, status domain.Status) error {
+	record.Status = status
+	id, genErr := u.Generator.Gen()
+	if genErr != nil {
+		return fmt.Errorf("%w: %v", ErrGeneratingID, genErr)
+	}
+	envelope := domain.Envelope{
+		ID:            id,
+		Type:          domain.ActionStatus,
+		SchemaVersion: domain.EventSchemaVersion,
+		OccurredAt:    u.Timer.TimeNow(),
+		Payload:       domain.Event{Record: record},
+	}
+	if err := u.Publisher.PublishEvent(ctx, envelope); err != nil {
+		switch {
+		case errors.Is(err, kafkaa.ErrOperationCanceled):
+			return fmt.Errorf("%w: %v", ErrOperationCanceled, err)
+		case errors.Is(err, kafkaa.ErrClosed):
+			return fmt.Errorf("%w: %v", ErrBrokerUnavailable, err)
+		default:
+			return fmt.Errorf("%w: %v", ErrBrokerFailure, err)
+		}
 	}
 	return nil
 }
@@ -2,38 +2,115 @@ package update
 
 import (
 	"context"
+	"errors"
+	"log/slog"
 	"testing"
+	"time"
 
 	"service2/internal/domain"
+	"service2/internal/pkg/broker/kafkaa"
 
 	"github.com/stretchr/testify/assert"
 )
 
+var discard = slog.New(slog.DiscardHandler)
+
+type mockPublisher struct {
+	statuses []domain.Status
+	err      error
+}
+
+func (m *mockPublisher) PublishEvent(ctx context.Context, envelope domain.Envelope) error {
+	m.statuses = append(m.statuses, envelope.Payload.Record.Status)
+	return m.err
+}
+
+type mockGenerator struct {
+	err error
+}
+
+func (m *mockGenerator) Gen() (string, error) {
+	return "1", m.err
+}
+
+type mockTimer struct{}
+
+func (m *mockTimer) TimeNow() int64 {
+	return 0
+}
+
 func Test_Update_Unit(t *testing.T) {
 	t.Parallel()
 	cases := []struct {
-		name    string
-		ctx     context.Context
-		event   domain.Event
-		usecase *Usecase
-		cancel  bool
-		err     error
+		name     string
+		ctx      context.Context
+		event    domain.Event
+		usecase  *Usecase
+		cancel   bool
+		statuses []domain.Status
+		err      error
 	}{
 		{
-			name:    "success",
+			name:     "success",
+			ctx:      context.Background(),
+			event:    domain.Event{Record: domain.Record{Title: "Title"}},
+			usecase:  &Usecase{Publisher: &mockPublisher{}, Generator: &mockGenerator{}, Timer: &mockTimer{}, Logger: discard},
+			cancel:   false,
+			statuses: []domain.Status{domain.StatusProcessing, domain.StatusCompleted},
+			err:      nil,
+		},
+		{
+			name:     "empty title",
+			ctx:      context.Background(),
+			event:    domain.Event{},
+			usecase:  &Usecase{Publisher: &mockPublisher{}, Generator: &mockGenerator{}, Timer: &mockTimer{}, Logger: discard},
+			cancel:   false,
+			statuses: []domain.Status{domain.StatusProcessing, domain.StatusFailed},
+			err:      ErrEmptyTitle,
+		},
+		{
+			name:     "broker unavailable",
+			ctx:      context.Background(),
+			event:    domain.Event{Record: domain.Record{Title: "Title"}},
+			usecase:  &Usecase{Publisher: &mockPublisher{err: kafkaa.ErrClosed}, Generator: &mockGenerator{}, Timer: &mockTimer{}, Logger: discard},
+			cancel:   false,
+			statuses: []domain.Status{domain.StatusProcessing},
+			err:      ErrBrokerUnavailable,
+		},
+		{
+			name:     "broker failure",
+			ctx:      context.Background(),
+			event:    domain.Event{Record: domain.Record{Title: "Title"}},
+			usecase:  &Usecase{Publisher: &mockPublisher{err: errors.New("")}, Generator: &mockGenerator{}, Timer: &mockTimer{}, Logger: discard},
+			cancel:   false,
+			statuses: []domain.Status{domain.StatusProcessing},
+			err:      ErrBrokerFailure,
+		},
+		{
+			name:    "id generation failure",
 			ctx:     context.Background(),
-			event:   domain.Event{},
-			usecase: &Usecase{},
+			event:   domain.Event{Record: domain.Record{Title: "Title"}},
+			usecase: &Usecase{Publisher: &mockPublisher{}, Generator: &mockGenerator{err: errors.New("")}, Timer: &mockTimer{}, Logger: discard},
 			cancel:  false,
-			err:     nil,
+			err:     ErrGeneratingID,
 		},
 		{
-			name:    "context closed mid work",
-			ctx:     context.Background(),
-			event:   domain.Event{},
-			usecase: &Usecase{},
-			cancel:  true,
-			err:     ErrOperationCanceled,
+			name:     "context closed mid kafka call",
+			ctx:      context.Background(),
+			event:    domain.Event{Record: domain.Record{Title: "Title"}},
+			usecase:  &Usecase{Publisher: &mockPublisher{err: kafkaa.ErrOperationCanceled}, Generator: &mockGenerator{}, Timer: &mockTimer{}, Logger: discard},
+			cancel:   false,
+			statuses: []domain.Status{domain.StatusProcessing},
+			err:      ErrOperationCanceled,
+		},
+		{
+			name:     "context closed mid work",
+			ctx:      context.Background(),
+			event:    domain.Event{Record: domain.Record{Title: "Title"}},
+			usecase:  &Usecase{Publisher: &mockPublisher{}, Generator: &mockGenerator{}, Timer: &mockTimer{}, Logger: discard},
+			cancel:   true,
+			statuses: []domain.Status{domain.StatusProcessing},
+			err:      ErrOperationCanceled,
 		},
 	}
 	for _, cs := range cases {
@@ -44,9 +121,36 @@ func Test_Update_Unit(t *testing.T) {
 			} else {
 				ctx, cancel := context.WithCancel(cs.ctx)
 				cancel()
+				cs.usecase.Config.ProcessingTime = time.Hour
 				err := cs.usecase.Update(ctx, cs.event)
 				assert.ErrorIs(t, err, cs.err)
 			}
+			assert.Equal(t, cs.statuses, cs.usecase.Publisher.(*mockPublisher).statuses)
 		})
 	}
 }
+
+func Test_Cancel_Unit(t *testing.T) {
+	t.Parallel()
+	publisher := &mockPublisher{}
+	u := &Usecase{Config: Config{ProcessingTime: time.Hour}, Publisher: publisher, Generator: &mockGenerator{}, Timer: &mockTimer{}, Logger: discard}
+	assert.False(t, u.Cancel("1"))
+	done := make(chan error)
+	go func() {
+		done <- u.Update(context.Background(), domain.Event{Record: domain.Record{ID: "1", Title: "Title"}})
+	}()
+	assert.Eventually(t, func() bool { return u.Cancel("1") }, time.Second, time.Millisecond)
+	assert.NoError(t, <-done)
+	assert.False(t, u.Cancel("1"))
+}
+
+func Test_Cancel_Queued_Unit(t *testing.T) {
+	t.Parallel()
+	publisher := &mockPublisher{}
+	u := &Usecase{Config: Config{DeletedTTL: time.Minute}, Publisher: publisher, Generator: &mockGenerator{}, Timer: &mockTimer{}, Logger: discard}
+	assert.False(t, u.Cancel("1"))
+	assert.NoError(t, u.Update(context.Background(), domain.Event{Record: domain.Record{ID: "1", Title: "Title"}}))
+	assert.Empty(t, publisher.statuses)
+	assert.NoError(t, u.Update(context.Background(), domain.Event{Record: domain.Record{ID: "2", Title: "Title"}}))
+	assert.Equal(t, []domain.Status{domain.StatusProcessing, domain.StatusCompleted}, publisher.statuses)
+}